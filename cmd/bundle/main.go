@@ -0,0 +1,171 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/internal/auth"
+	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/internal/bundle"
+	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/internal/crypto"
+	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/internal/keyfile"
+	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/pkg/utils"
+)
+
+// This command never touches the database: a printing centre needs only a
+// bundle exported by the Exam Cell and its own private key file.
+const usage = `usage: bundle <command> [flags]
+
+commands:
+  keygen  -out FILE -pub FILE [-algorithm NAME]
+          create a recipient key pair; send the public key to the Exam Cell
+  inspect -in BUNDLE
+          print the bundle's metadata without decrypting it
+  verify  -in BUNDLE -key FILE [-signer FINGERPRINT]
+          decrypt in memory and check the ciphertext hash and faculty signature
+  open    -in BUNDLE -key FILE -out FILE [-signer FINGERPRINT]
+          verify and write the paper; nothing is written unless it verifies
+
+-signer pins the faculty key to a fingerprint obtained out of band.`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Println(usage)
+		os.Exit(2)
+	}
+
+	switch os.Args[1] {
+	case "keygen":
+		keygen(os.Args[2:])
+	case "inspect":
+		inspect(os.Args[2:])
+	case "verify", "open":
+		open(os.Args[1], os.Args[2:])
+	default:
+		fmt.Println(usage)
+		os.Exit(2)
+	}
+}
+
+func keygen(args []string) {
+	flags := flag.NewFlagSet("keygen", flag.ExitOnError)
+	out := flags.String("out", "", "file for the passphrase-encrypted private key")
+	pub := flags.String("pub", "", "file for the public key")
+	algorithm := flags.String("algorithm", "", "key suite (default "+crypto.DefaultKeyAlgorithm+")")
+	flags.Parse(args)
+	if *out == "" || *pub == "" {
+		log.Fatal("keygen needs -out and -pub")
+	}
+
+	passphrase, err := utils.GetPassword("Passphrase: ")
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := auth.ValidatePassword(passphrase); err != nil {
+		log.Fatal(err)
+	}
+	confirm, err := utils.GetPassword("Repeat passphrase: ")
+	if err != nil {
+		log.Fatal(err)
+	}
+	if confirm != passphrase {
+		log.Fatal("passphrases do not match")
+	}
+
+	fingerprint, err := keyfile.Generate(*out, *pub, *algorithm, passphrase)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println("recipient key pair created")
+	fmt.Printf("fingerprint: %s\n", fingerprint)
+}
+
+func inspect(args []string) {
+	flags := flag.NewFlagSet("inspect", flag.ExitOnError)
+	in := flags.String("in", "", "bundle file")
+	flags.Parse(args)
+	if *in == "" {
+		log.Fatal("inspect needs -in")
+	}
+
+	file, err := os.Open(*in)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer file.Close()
+
+	br, err := bundle.NewReader(file)
+	if err != nil {
+		log.Fatal(err)
+	}
+	printManifest(br.Manifest)
+}
+
+func open(command string, args []string) {
+	flags := flag.NewFlagSet(command, flag.ExitOnError)
+	in := flags.String("in", "", "bundle file")
+	keyFile := flags.String("key", "", "recipient private key file")
+	out := flags.String("out", "", "file to write the paper to")
+	signer := flags.String("signer", "", "expected faculty key fingerprint")
+	flags.Parse(args)
+	if *in == "" || *keyFile == "" {
+		log.Fatalf("%s needs -in and -key", command)
+	}
+	if command == "open" && *out == "" {
+		log.Fatal("open needs -out")
+	}
+
+	passphrase, err := utils.GetPassword("Passphrase: ")
+	if err != nil {
+		log.Fatal(err)
+	}
+	privateKey, err := keyfile.LoadPrivateKey(*keyFile, passphrase)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	file, err := os.Open(*in)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer file.Close()
+
+	var manifest *bundle.Manifest
+	if command == "open" {
+		manifest, err = bundle.OpenToFile(file, privateKey, *signer, *out)
+	} else {
+		manifest, err = bundle.Open(file, privateKey, *signer, io.Discard)
+	}
+	if manifest != nil {
+		printManifest(manifest)
+	}
+	if err != nil {
+		log.Fatal("VERIFICATION FAILED: ", err)
+	}
+
+	if manifest.Signature.Scheme == crypto.SignatureSchemeContent {
+		fmt.Println("\nciphertext hash and faculty signature verified")
+		fmt.Println("WARNING: this paper predates signed metadata; the details above are not covered by the signature")
+	} else {
+		fmt.Println("\nciphertext hash, faculty signature and paper details verified")
+	}
+	if command == "open" {
+		fmt.Printf("paper written to %s\n", *out)
+	}
+}
+
+func printManifest(manifest *bundle.Manifest) {
+	paper := manifest.Paper
+	fmt.Printf("Paper #%d: %s (%s)\n", paper.ID, paper.Title, paper.Subject)
+	fmt.Printf("    Exam Date: %s\n", paper.ExamDate)
+	fmt.Printf("    Faculty: %s (#%d)\n", paper.Faculty, paper.FacultyID)
+	fmt.Printf("    Uploaded: %s\n", paper.UploadDate.Format("2006-01-02 15:04"))
+	fmt.Printf("    Status: %s\n", paper.Status)
+	fmt.Printf("    Exported: %s by %s\n", manifest.ExportedAt.Format("2006-01-02 15:04"), manifest.ExportedBy)
+	fmt.Printf("    Ciphertext: %s, %d bytes, sha256 %s\n", manifest.Ciphertext.Format, manifest.Ciphertext.Size, manifest.Ciphertext.SHA256)
+	fmt.Printf("    Recipient: %s (%s)\n", manifest.Recipient.Fingerprint, manifest.Recipient.KeyWrap)
+	fmt.Printf("    Signer: %s (%s, %s)\n", manifest.Signature.SignerFingerprint, manifest.Signature.Algorithm,
+		manifest.Signature.Scheme)
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+
+	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/internal/auth"
+	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/internal/crypto"
+	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/internal/database"
+	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/internal/keyfile"
+	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/internal/services"
+	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/pkg/utils"
+)
+
+const usage = `usage: escrow <command> [flags]
+
+commands:
+  keygen  -out FILE -pub FILE [-algorithm NAME]
+          create the offline escrow key pair; the private key is encrypted
+          with a passphrase, the public key goes in ESCROW_PUBLIC_KEY_FILE
+  status  show which papers have an escrowed key
+  recover -key FILE -user NAME [-paper ID]
+          restore an ExamCell member's access to escrowed papers using the
+          escrow private key (audited against that member's account)`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Println(usage)
+		os.Exit(2)
+	}
+
+	switch os.Args[1] {
+	case "keygen":
+		keygen(os.Args[2:])
+	case "status":
+		status()
+	case "recover":
+		recoverAccess(os.Args[2:])
+	default:
+		fmt.Println(usage)
+		os.Exit(2)
+	}
+}
+
+func keygen(args []string) {
+	flags := flag.NewFlagSet("keygen", flag.ExitOnError)
+	out := flags.String("out", "", "file for the passphrase-encrypted private key")
+	pub := flags.String("pub", "", "file for the public key")
+	algorithm := flags.String("algorithm", "", "key suite (default "+crypto.DefaultKeyAlgorithm+")")
+	flags.Parse(args)
+	if *out == "" || *pub == "" {
+		log.Fatal("keygen needs -out and -pub")
+	}
+
+	passphrase, err := utils.GetPassword("Escrow passphrase: ")
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := auth.ValidatePassword(passphrase); err != nil {
+		log.Fatal(err)
+	}
+	confirm, err := utils.GetPassword("Repeat passphrase: ")
+	if err != nil {
+		log.Fatal(err)
+	}
+	if confirm != passphrase {
+		log.Fatal("passphrases do not match")
+	}
+
+	// Never overwrite an existing escrow key: papers may already be wrapped to it
+	fingerprint, err := keyfile.Generate(*out, *pub, *algorithm, passphrase)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Println("escrow key pair created")
+	fmt.Printf("fingerprint: %s\n", fingerprint)
+	fmt.Printf("set ESCROW_PUBLIC_KEY_FILE=%s and move %s offline\n", *pub, *out)
+}
+
+func status() {
+	db, err := database.Connect()
+	if err != nil {
+		log.Fatal("Database connection failed:", err)
+	}
+	defer db.Close()
+
+	escrow, err := services.InitEscrowFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if escrow != nil {
+		fmt.Printf("configured escrow key: %s\n", escrow.Fingerprint)
+	} else {
+		fmt.Println("configured escrow key: none (ESCROW_PUBLIC_KEY_FILE unset)")
+	}
+
+	state, err := services.GetEscrowStatus(db)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("papers: %d\n", state.Papers)
+	fingerprints := make([]string, 0, len(state.Escrowed))
+	for fingerprint := range state.Escrowed {
+		fingerprints = append(fingerprints, fingerprint)
+	}
+	sort.Strings(fingerprints)
+	for _, fingerprint := range fingerprints {
+		fmt.Printf("  escrowed to %s: %d\n", fingerprint, state.Escrowed[fingerprint])
+	}
+	if len(state.Missing) > 0 {
+		fmt.Printf("  not escrowed: %d %v\n", len(state.Missing), state.Missing)
+	}
+}
+
+func recoverAccess(args []string) {
+	flags := flag.NewFlagSet("recover", flag.ExitOnError)
+	keyFile := flags.String("key", "", "escrow private key file")
+	username := flags.String("user", "", "ExamCell member to restore access for")
+	paperID := flags.Int("paper", 0, "restore a single paper (default: all)")
+	flags.Parse(args)
+	if *keyFile == "" || *username == "" {
+		log.Fatal("recover needs -key and -user")
+	}
+
+	passphrase, err := utils.GetPassword("Escrow passphrase: ")
+	if err != nil {
+		log.Fatal(err)
+	}
+	escrowKey, err := keyfile.LoadPrivateKey(*keyFile, passphrase)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	db, err := database.Connect()
+	if err != nil {
+		log.Fatal("Database connection failed:", err)
+	}
+	defer db.Close()
+
+	recovery, err := services.RecoverFromEscrow(db, escrowKey, *username, *paperID)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Printf("escrow key %s\n", recovery.Fingerprint)
+	fmt.Printf("restored for %s: %d paper(s) %v\n", recovery.Username, len(recovery.Restored), recovery.Restored)
+	fmt.Printf("already accessible: %d\n", recovery.Skipped)
+	for id, reason := range recovery.Failed {
+		fmt.Printf("paper %d failed: %s\n", id, reason)
+	}
+	if len(recovery.Failed) > 0 {
+		os.Exit(1)
+	}
+}
@@ -1,18 +1,24 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/internal/acl"
 	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/internal/auth"
 	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/internal/database"
+	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/internal/keyfile"
 	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/internal/models"
 	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/internal/services"
+	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/pkg/blobstore"
+	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/pkg/email"
 	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/pkg/utils"
 )
 
@@ -29,6 +35,29 @@ func main() {
 	if err := database.InitSchema(db); err != nil {
 		log.Fatal("Schema initialization failed:", err)
 	}
+	if _, err := services.ThresholdFromEnv(); err != nil {
+		log.Fatal("Key release setup failed:", err)
+	}
+
+	if err := blobstore.InitFromEnv(); err != nil {
+		log.Fatal("Blob store setup failed:", err)
+	}
+	if moved, err := services.MoveInlinePapers(db, blobstore.Default()); err != nil {
+		log.Fatal("Moving papers to the blob store failed:", err)
+	} else if moved > 0 {
+		log.Printf("Moved %d inline paper(s) to the blob store", moved)
+	}
+	if escrow, err := services.InitEscrowFromEnv(); err != nil {
+		log.Fatal("Key escrow setup failed:", err)
+	} else if escrow != nil {
+		log.Printf("Paper keys are escrowed to %s", escrow.Fingerprint)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := email.StartOutbox(ctx, db); err != nil {
+		log.Fatal("Email setup failed:", err)
+	}
 
 	for {
 		showMainMenu()
@@ -107,6 +136,22 @@ func handleRegistration(db *sql.DB) {
 	fmt.Printf("Email: %s\n", user.Email)
 	fmt.Printf("Role: %s\n", user.Role)
 	fmt.Println("\nYour password has been securely hashed with bcrypt + salt")
+
+	codes, err := auth.GenerateRecoveryCodes(db, user.ID)
+	if err != nil {
+		fmt.Println("Warning: Failed to generate recovery codes:", err)
+		return
+	}
+	printRecoveryCodes(codes)
+}
+
+// printRecoveryCodes shows freshly generated recovery codes; they cannot be displayed again
+func printRecoveryCodes(codes []string) {
+	fmt.Println("\n Recovery codes (each works once in place of an OTP):")
+	for i, code := range codes {
+		fmt.Printf("    %2d. %s\n", i+1, code)
+	}
+	fmt.Println(" Store them somewhere safe; they will not be shown again.")
 }
 
 func handleLogin(db *sql.DB) {
@@ -120,7 +165,7 @@ func handleLogin(db *sql.DB) {
 		return
 	}
 
-	user, err := auth.AuthenticateUser(db, username, password)
+	user, err := auth.AuthenticateUser(db, username, password, "cli")
 	if err != nil {
 		fmt.Println("Authentication failed:", err)
 		return
@@ -129,44 +174,72 @@ func handleLogin(db *sql.DB) {
 	fmt.Println("Password verified!")
 	fmt.Println("\nInitiating Multi-Factor Authentication...")
 
-	_, err = auth.InitiateMFA(db, user)
+	method, err := auth.InitiateMFA(db, user)
 	if err != nil {
 		fmt.Println("MFA initiation failed:", err)
 		return
 	}
 
-	otp := utils.GetInput("\nEnter OTP: ")
+	prompt := "\nEnter OTP (or a recovery code): "
+	if method == auth.MFATOTP {
+		prompt = "\nEnter code from your authenticator app (or a recovery code): "
+	}
+	otp := utils.GetInput(prompt)
 
-	err = auth.CompleteLogin(db, user, otp)
+	token, err := auth.CompleteLogin(db, user, otp, "cli")
 	if err != nil {
 		fmt.Println("Login failed:", err)
 		return
 	}
 
+	err = auth.UnlockPrivateKey(db, user, password)
+	if err != nil {
+		fmt.Println("Login failed:", err)
+		return
+	}
+	services.StartKeyRewrap(db, user)
+
 	fmt.Println("\nLogin successful!")
 	fmt.Println(strings.Repeat("=", 50))
 
-	showDashboard(db, user)
+	showDashboard(db, user, token)
 }
 
-func showDashboard(db *sql.DB, user *models.User) {
+func showDashboard(db *sql.DB, user *models.User, token string) {
+	// Leaving the dashboard for any reason ends the session
+	defer auth.RevokeSession(db, token)
+
 	fmt.Printf("\n Welcome, %s!\n", user.Username)
 	fmt.Printf(" Role: %s\n", user.Role)
 
 	switch user.Role {
 	case "Faculty":
-		facultyDashboard(db, user)
+		facultyDashboard(db, user, token)
 	case "ExamCell":
-		examCellDashboard(db, user)
+		examCellDashboard(db, user, token)
 	case "Student":
-		studentDashboard(db, user)
+		studentDashboard(db, user, token)
 	}
 }
 
-func facultyDashboard(db *sql.DB, user *models.User) {
-	paperService := &services.PaperService{DB: db}
+// sessionActive checks the session token before each dashboard action
+func sessionActive(db *sql.DB, token string) bool {
+	if _, _, err := auth.ValidateSession(db, token); err != nil {
+		fmt.Println("\n Session ended:", err)
+		fmt.Println(" Please log in again.")
+		return false
+	}
+	return true
+}
+
+func facultyDashboard(db *sql.DB, user *models.User, token string) {
+	paperService := services.NewPaperService(db)
 
 	for {
+		if !sessionActive(db, token) {
+			return
+		}
+
 		fmt.Println("\n" + strings.Repeat("=", 50))
 		fmt.Println("           FACULTY DASHBOARD")
 		fmt.Println(strings.Repeat("=", 50))
@@ -174,10 +247,14 @@ func facultyDashboard(db *sql.DB, user *models.User) {
 		fmt.Println("2. View My Papers")
 		fmt.Println("3. View My Permissions")
 		fmt.Println("4. View Audit Log")
-		fmt.Println("5. Logout")
+		fmt.Println("5. Active Sessions")
+		fmt.Println("6. MFA & Recovery Codes")
+		fmt.Println("7. Rotate Key Pair")
+		fmt.Println("8. Re-sign Paper")
+		fmt.Println("9. Logout")
 		fmt.Println(strings.Repeat("=", 50))
 
-		choice := utils.GetChoice("Enter your choice : ", 1, 5)
+		choice := utils.GetChoice("Enter your choice : ", 1, 9)
 
 		switch choice {
 		case 1:
@@ -189,6 +266,14 @@ func facultyDashboard(db *sql.DB, user *models.User) {
 		case 4:
 			showAuditLog(db, user)
 		case 5:
+			handleActiveSessions(db, user, token)
+		case 6:
+			handleMFASettings(db, user)
+		case 7:
+			handleRotateKeys(db, user)
+		case 8:
+			handleResignPaper(user, paperService)
+		case 9:
 			return
 		}
 	}
@@ -265,43 +350,361 @@ func handleViewPapers(user *models.User, paperService *services.PaperService) {
 		fmt.Printf("    Exam Date: %s\n", paper.ExamDate.Format("2006-01-02"))
 		fmt.Printf("    Uploaded: %s\n", paper.UploadDate.Format("2006-01-02 15:04"))
 		fmt.Printf("    Status: %s\n", paper.Status)
+		if paper.ReviewComments != "" {
+			fmt.Printf("    Reviewer Comments: %s\n", paper.ReviewComments)
+		}
+		if paper.RevisionOf != 0 {
+			fmt.Printf("    Revision of: Paper %d\n", paper.RevisionOf)
+		}
+		if paper.Status == services.StatusRejected {
+			fmt.Printf("    Paper ID: %d (upload a revision to resubmit)\n", paper.ID)
+		}
 		fmt.Printf("    Encrypted: Yes\n")
+		if paper.SignerCompromised {
+			fmt.Printf("    WARNING: signed with a compromised key; re-sign paper %d\n", paper.ID)
+		}
+	}
+
+	rejected := false
+	for _, paper := range papers {
+		if paper.Status == services.StatusRejected {
+			rejected = true
+		}
+	}
+	if rejected && utils.Confirm("\nResubmit a rejected paper with a revised file") {
+		handleResubmitPaper(user, paperService)
+		return
 	}
 
 	utils.GetInput("\nPress Enter to continue...")
 }
 
-func examCellDashboard(db *sql.DB, user *models.User) {
-	paperService := &services.PaperService{DB: db}
+func handleResubmitPaper(user *models.User, paperService *services.PaperService) {
+	paperID := utils.GetChoice("Enter Paper ID : ", 1, 9999)
+	filePath := utils.GetInput("Revised File Path (PDF/TXT): ")
+	if filePath == "" {
+		fmt.Println(" File path cannot be empty")
+		return
+	}
+	comments := utils.GetInput("Notes for the reviewer (optional): ")
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		fmt.Printf(" File not found: %s\n", filePath)
+		utils.GetInput("\nPress Enter to continue...")
+		return
+	}
+	defer file.Close()
+
+	revisionID, err := paperService.ResubmitPaper(user, paperID, file, comments)
+	if err != nil {
+		fmt.Println(" Resubmission failed:", err)
+	} else {
+		fmt.Printf(" Revision stored as Paper %d and submitted for review\n", revisionID)
+	}
+	utils.GetInput("\nPress Enter to continue...")
+}
+
+func examCellDashboard(db *sql.DB, user *models.User, token string) {
+	paperService := services.NewPaperService(db)
 
 	for {
+		if !sessionActive(db, token) {
+			return
+		}
+
 		fmt.Println("\n" + strings.Repeat("=", 50))
 		fmt.Println("           EXAM CELL DASHBOARD")
 		fmt.Println(strings.Repeat("=", 50))
 		fmt.Println("1. View All Papers")
-		fmt.Println("2. Decrypt & View Paper")
-		fmt.Println("3. View My Permissions")
-		fmt.Println("4. View Audit Log")
-		fmt.Println("5. Logout")
+		fmt.Println("2. Review / Approve / Publish Paper")
+		fmt.Println("3. Manage Exam Sessions")
+		fmt.Println("4. Decrypt & View Paper")
+		fmt.Println("5. Submit Key Share")
+		fmt.Println("6. Request Emergency Override")
+		fmt.Println("7. Approve Emergency Override")
+		fmt.Println("8. View My Permissions")
+		fmt.Println("9. View Audit Log")
+		fmt.Println("10. Active Sessions")
+		fmt.Println("11. MFA & Recovery Codes")
+		fmt.Println("12. Locked Accounts")
+		fmt.Println("13. Email Queue")
+		fmt.Println("14. Rotate Key Pair")
+		fmt.Println("15. Key Compromise Response")
+		fmt.Println("16. Export Paper Bundle")
+		fmt.Println("17. Logout")
 		fmt.Println(strings.Repeat("=", 50))
 
-		choice := utils.GetChoice("Enter your choice : ", 1, 5)
+		choice := utils.GetChoice("Enter your choice : ", 1, 17)
 
 		switch choice {
 		case 1:
 			handleViewAllPapers(paperService)
 		case 2:
-			handleDecryptPaper(user, paperService)
+			handlePaperReview(db, user)
 		case 3:
-			showPermissions(db, user)
+			handleManageSessions(db, user)
 		case 4:
-			showAuditLog(db, user)
+			handleDecryptPaper(user, paperService)
 		case 5:
+			handleSubmitKeyShare(user, paperService)
+		case 6:
+			handleRequestOverride(user, paperService)
+		case 7:
+			handleApproveOverride(user, paperService)
+		case 8:
+			showPermissions(db, user)
+		case 9:
+			showAuditLog(db, user)
+		case 10:
+			handleActiveSessions(db, user, token)
+		case 11:
+			handleMFASettings(db, user)
+		case 12:
+			handleLockedAccounts(db, user)
+		case 13:
+			handleEmailQueue(db, user)
+		case 14:
+			handleRotateKeys(db, user)
+		case 15:
+			handleKeyCompromise(db, user, paperService)
+		case 16:
+			handleExportBundle(user, paperService)
+		case 17:
 			return
 		}
 	}
 }
 
+func handleLockedAccounts(db *sql.DB, user *models.User) {
+	fmt.Println("\n" + strings.Repeat("=", 50))
+	fmt.Println(" LOCKED ACCOUNTS")
+	fmt.Println(strings.Repeat("=", 50))
+
+	accounts, err := auth.GetLockedAccounts(db, user)
+	if err != nil {
+		fmt.Println("", err)
+		return
+	}
+
+	if len(accounts) == 0 {
+		fmt.Println("No accounts are locked")
+		utils.GetInput("\nPress Enter to continue...")
+		return
+	}
+
+	for _, account := range accounts {
+		fmt.Printf("\nUser #%d %s (%s)\n", account.UserID, account.Username, account.Role)
+		fmt.Printf("    Locked until: %s\n", account.LockedUntil.Local().Format("2006-01-02 15:04"))
+	}
+
+	if !utils.Confirm("\nUnlock an account") {
+		return
+	}
+
+	userID := utils.GetChoice("User # : ", 1, 99999999)
+	if err := auth.UnlockAccount(db, user, userID); err != nil {
+		fmt.Println(" Failed to unlock account:", err)
+		return
+	}
+	fmt.Println(" Account unlocked")
+}
+
+func handleEmailQueue(db *sql.DB, user *models.User) {
+	fmt.Println("\n" + strings.Repeat("=", 50))
+	fmt.Println(" EMAIL QUEUE")
+	fmt.Println(strings.Repeat("=", 50))
+
+	outboxService := services.NewOutboxService(db, user)
+	counts, err := outboxService.GetQueueCounts()
+	if err != nil {
+		fmt.Println("", err)
+		return
+	}
+	fmt.Printf(" Pending: %d   Sent: %d   Dead: %d\n", counts[email.OutboxPending], counts[email.OutboxSent], counts[email.OutboxDead])
+
+	messages, err := outboxService.GetUndelivered(50)
+	if err != nil {
+		fmt.Println("", err)
+		return
+	}
+
+	for _, msg := range messages {
+		fmt.Printf("\n#%d [%s] %s -> %s\n", msg.ID, msg.Status, msg.Subject, msg.Recipient)
+		fmt.Printf("    Attempts: %d\n", msg.Attempts)
+		if msg.Status == email.OutboxPending {
+			fmt.Printf("    Next attempt: %s\n", msg.NextAttemptAt.Local().Format("2006-01-02 15:04:05"))
+		}
+		if msg.LastError != "" {
+			fmt.Printf("    Last error: %s\n", msg.LastError)
+		}
+	}
+}
+
+func handleRotateKeys(db *sql.DB, user *models.User) {
+	fmt.Println("\n" + strings.Repeat("=", 50))
+	fmt.Println(" ROTATE KEY PAIR")
+	fmt.Println(strings.Repeat("=", 50))
+	fmt.Printf(" Current key suite: %s\n", user.KeyAlgorithm)
+	fmt.Println(" A new key pair replaces the current one and every paper key")
+	fmt.Println(" wrapped for you is re-wrapped to it. The old key is retired.")
+
+	algorithm := utils.GetInput("New key suite (leave empty to keep the current one): ")
+	password, err := utils.GetPassword("Password: ")
+	if err != nil {
+		fmt.Println("Error reading password:", err)
+		return
+	}
+	if !utils.Confirm("Rotate your key pair now") {
+		return
+	}
+
+	rotation, err := services.RotateUserKeys(db, user, password, algorithm)
+	if err != nil {
+		fmt.Println(" Key rotation failed:", err)
+		utils.GetInput("\nPress Enter to continue...")
+		return
+	}
+
+	fmt.Printf(" Key pair rotated (%s -> %s)\n", rotation.OldAlgorithm, rotation.NewAlgorithm)
+	fmt.Printf(" Wrapped keys re-wrapped: %d\n", rotation.Rewrapped+rotation.Adopted)
+	fmt.Println(" Log out of other sessions: they still hold the retired key")
+	utils.GetInput("\nPress Enter to continue...")
+}
+
+func handleResignPaper(user *models.User, paperService *services.PaperService) {
+	fmt.Println("\n" + strings.Repeat("=", 50))
+	fmt.Println(" RE-SIGN PAPER")
+	fmt.Println(strings.Repeat("=", 50))
+	fmt.Println(" Papers signed with a key reported compromised cannot be")
+	fmt.Println(" decrypted until re-signed. Rotate your key pair first, then")
+	fmt.Println(" supply the file exactly as it was uploaded.")
+
+	paperID := utils.GetChoice("Enter Paper ID : ", 1, 9999)
+	filePath := utils.GetInput("File Path (PDF/TXT): ")
+	file, err := os.Open(filePath)
+	if err != nil {
+		fmt.Println(" Failed to open file:", err)
+		return
+	}
+	defer file.Close()
+
+	if err := paperService.ResignPaper(paperID, user, file); err != nil {
+		fmt.Println(" Re-signing failed:", err)
+	} else {
+		fmt.Println(" Paper re-signed with your current key")
+	}
+	utils.GetInput("\nPress Enter to continue...")
+}
+
+func handleKeyCompromise(db *sql.DB, user *models.User, paperService *services.PaperService) {
+	compromiseService := services.NewKeyCompromiseService(db, user)
+
+	for {
+		fmt.Println("\n" + strings.Repeat("=", 50))
+		fmt.Println(" KEY COMPROMISE RESPONSE")
+		fmt.Println(strings.Repeat("=", 50))
+		fmt.Println("1. Report Compromised Key")
+		fmt.Println("2. Re-encrypt Queued Papers")
+		fmt.Println("3. Back")
+
+		switch utils.GetChoice("Enter your choice : ", 1, 3) {
+		case 1:
+			handleReportCompromise(compromiseService)
+		case 2:
+			handleReencryptPapers(user, compromiseService, paperService)
+		case 3:
+			return
+		}
+	}
+}
+
+func handleReportCompromise(compromiseService *services.KeyCompromiseService) {
+	userID := utils.GetChoice("User # : ", 1, 99999999)
+	keys, err := compromiseService.GetUserKeys(userID)
+	if err != nil {
+		fmt.Println("", err)
+		return
+	}
+	if len(keys) == 0 {
+		fmt.Println("No key pairs on record for this user")
+		return
+	}
+
+	for _, key := range keys {
+		fmt.Printf("\nKey #%d %s [%s]\n", key.ID, key.KeyAlgorithm, key.Status)
+		fmt.Printf("    Created: %s\n", key.CreatedAt.Local().Format("2006-01-02 15:04"))
+		if key.RetiredAt != nil {
+			fmt.Printf("    Retired: %s\n", key.RetiredAt.Local().Format("2006-01-02 15:04"))
+		}
+		if key.CompromisedAt != nil {
+			fmt.Printf("    Compromised: %s (%s)\n", key.CompromisedAt.Local().Format("2006-01-02 15:04"), key.CompromiseReason)
+		}
+	}
+
+	keyID := utils.GetChoice("\nKey # : ", 1, 99999999)
+	reason := utils.GetInput("Reason: ")
+	if !utils.Confirm("Report this key as compromised") {
+		return
+	}
+
+	report, err := compromiseService.ReportCompromise(keyID, reason)
+	if err != nil {
+		fmt.Println(" Report failed:", err)
+		utils.GetInput("\nPress Enter to continue...")
+		return
+	}
+
+	fmt.Printf("\n Key #%d of %s reported compromised\n", report.Key.ID, report.Username)
+	if report.KeyActive {
+		fmt.Printf(" %s must rotate their key pair; until then it cannot sign or receive paper keys\n", report.Username)
+	}
+
+	fmt.Printf("\n Papers signed with the key (decryption refused until re-signed): %d\n", len(report.SignedPapers))
+	for _, paper := range report.SignedPapers {
+		fmt.Printf("    #%d %s (%s) [%s] exam %s\n", paper.ID, paper.Title, paper.Subject, paper.Status, paper.ExamDate.Format("2006-01-02"))
+	}
+	fmt.Printf("\n Papers whose key it could open (queued for re-encryption): %d\n", len(report.WrappedPapers))
+	for _, paper := range report.WrappedPapers {
+		fmt.Printf("    #%d %s (%s) [%s] exam %s\n", paper.ID, paper.Title, paper.Subject, paper.Status, paper.ExamDate.Format("2006-01-02"))
+	}
+	fmt.Printf("\n Upcoming or running exam sessions affected: %d\n", len(report.Sessions))
+	for _, session := range report.Sessions {
+		fmt.Printf("    #%d %s - %s at %s [%s]\n", session.ID, session.SessionName, session.PaperTitle,
+			session.ScheduledTime.Local().Format("2006-01-02 15:04"), session.Status)
+	}
+	utils.GetInput("\nPress Enter to continue...")
+}
+
+func handleReencryptPapers(user *models.User, compromiseService *services.KeyCompromiseService, paperService *services.PaperService) {
+	papers, err := compromiseService.GetPendingReencryptions()
+	if err != nil {
+		fmt.Println("", err)
+		return
+	}
+	if len(papers) == 0 {
+		fmt.Println("No papers are queued for re-encryption")
+		utils.GetInput("\nPress Enter to continue...")
+		return
+	}
+
+	for _, paper := range papers {
+		fmt.Printf("\n#%d %s (%s) [%s]\n", paper.ID, paper.Title, paper.Subject, paper.Status)
+	}
+	if !utils.Confirm("\nRe-encrypt all queued papers now") {
+		return
+	}
+
+	for _, paper := range papers {
+		if err := paperService.ReencryptPaper(paper.ID, user); err != nil {
+			fmt.Printf(" Paper %d: re-encryption failed: %v\n", paper.ID, err)
+		} else {
+			fmt.Printf(" Paper %d: re-encrypted under a fresh key\n", paper.ID)
+		}
+	}
+	utils.GetInput("\nPress Enter to continue...")
+}
+
 func handleViewAllPapers(paperService *services.PaperService) {
 	fmt.Println("\n" + strings.Repeat("=", 50))
 	fmt.Println(" ALL QUESTION PAPERS")
@@ -327,17 +730,236 @@ func handleViewAllPapers(paperService *services.PaperService) {
 		fmt.Printf("    Uploaded: %s\n", paper.UploadDate.Format("2006-01-02 15:04"))
 		fmt.Printf("    Status: %s\n", paper.Status)
 		fmt.Printf("    Encrypted: Yes\n")
+		if paper.ReleaseThreshold > 0 {
+			fmt.Printf("    Key Release: %d ExamCell shares required\n", paper.ReleaseThreshold)
+		}
+		if paper.SignerCompromised {
+			fmt.Printf("    WARNING: signed with a compromised key; awaiting re-signing\n")
+		}
+		if paper.ReencryptionPending {
+			fmt.Printf("    WARNING: queued for re-encryption\n")
+		}
 		fmt.Printf("    Paper ID: %d\n", paper.ID)
 	}
 
 	utils.GetInput("\nPress Enter to continue...")
 }
 
+func handlePaperReview(db *sql.DB, user *models.User) {
+	workflow := services.NewWorkflowService(db, user)
+
+	fmt.Println("\n" + strings.Repeat("=", 50))
+	fmt.Println(" PAPER REVIEW")
+	fmt.Println(strings.Repeat("=", 50))
+	paperID := utils.GetChoice("Enter Paper ID : ", 1, 9999)
+
+	history, err := workflow.GetHistory(paperID)
+	if err != nil {
+		fmt.Println(" Failed to fetch history:", err)
+		utils.GetInput("\nPress Enter to continue...")
+		return
+	}
+
+	fmt.Println("\n Status History:")
+	for _, change := range history {
+		from := change.FromStatus
+		if from == "" {
+			from = "submitted"
+		}
+		fmt.Printf("    %s  %s -> %s by %s\n", change.ChangedAt.Format("2006-01-02 15:04"), from, change.ToStatus, change.ChangedByName)
+		if change.Comments != "" {
+			fmt.Printf("        Comments: %s\n", change.Comments)
+		}
+	}
+
+	fmt.Println("\n1. Start Review")
+	fmt.Println("2. Approve")
+	fmt.Println("3. Reject with Comments")
+	fmt.Println("4. Publish")
+	fmt.Println("5. Back")
+
+	switch utils.GetChoice("Enter your choice : ", 1, 5) {
+	case 1:
+		err = workflow.StartReview(paperID)
+	case 2:
+		err = workflow.Approve(paperID, utils.GetInput("Comments (optional): "))
+	case 3:
+		err = workflow.Reject(paperID, utils.GetInput("Comments: "))
+	case 4:
+		err = workflow.Publish(paperID)
+	case 5:
+		return
+	}
+
+	if err != nil {
+		fmt.Println(" Status change failed:", err)
+	} else {
+		fmt.Println(" Paper status updated")
+	}
+	utils.GetInput("\nPress Enter to continue...")
+}
+
+func handleManageSessions(db *sql.DB, user *models.User) {
+	sessionService := services.NewExamSessionService(db, user)
+
+	for {
+		fmt.Println("\n" + strings.Repeat("=", 50))
+		fmt.Println(" EXAM SESSIONS")
+		fmt.Println(strings.Repeat("=", 50))
+
+		sessions, err := sessionService.GetSessions()
+		if err != nil {
+			fmt.Println(" Failed to fetch sessions:", err)
+			utils.GetInput("\nPress Enter to continue...")
+			return
+		}
+
+		if len(sessions) == 0 {
+			fmt.Println("No sessions scheduled")
+		}
+		for _, session := range sessions {
+			fmt.Printf("\n#%d %s (%s)\n", session.ID, session.SessionName, session.Status)
+			fmt.Printf("    Paper: %s (ID %d)\n", session.PaperTitle, session.PaperID)
+			fmt.Printf("    Starts: %s for %d minutes\n", session.ScheduledTime.Local().Format("2006-01-02 15:04"), session.DurationMinutes)
+		}
+
+		fmt.Println("\n1. Schedule Session")
+		fmt.Println("2. Reschedule Session")
+		fmt.Println("3. Start Session")
+		fmt.Println("4. Cancel Session")
+		fmt.Println("5. Approve Schedule Change")
+		fmt.Println("6. Back")
+
+		switch utils.GetChoice("Enter your choice : ", 1, 6) {
+		case 1:
+			paperID := utils.GetChoice("Paper ID : ", 1, 9999)
+			name := utils.GetInput("Session Name: ")
+			scheduledTime, durationMins, ok := readSessionTiming()
+			if !ok {
+				continue
+			}
+			var sessionID int
+			sessionID, err = sessionService.ScheduleSession(paperID, name, scheduledTime, durationMins)
+			if err == nil {
+				fmt.Printf(" Session #%d scheduled\n", sessionID)
+			} else if errors.Is(err, services.ErrReleaseMovedEarlier) {
+				err = requestScheduleChange(err, func(reason string) (int, error) {
+					return sessionService.RequestNewSession(paperID, name, scheduledTime, durationMins, reason)
+				})
+			}
+		case 2:
+			sessionID := utils.GetChoice("Session ID : ", 1, 999999)
+			scheduledTime, durationMins, ok := readSessionTiming()
+			if !ok {
+				continue
+			}
+			err = sessionService.RescheduleSession(sessionID, scheduledTime, durationMins)
+			if errors.Is(err, services.ErrReleaseMovedEarlier) {
+				err = requestScheduleChange(err, func(reason string) (int, error) {
+					return sessionService.RequestReschedule(sessionID, scheduledTime, durationMins, reason)
+				})
+			}
+		case 3:
+			err = sessionService.StartSession(utils.GetChoice("Session ID : ", 1, 999999))
+		case 4:
+			sessionID := utils.GetChoice("Session ID : ", 1, 999999)
+			if !utils.Confirm("Cancel this session") {
+				continue
+			}
+			err = sessionService.CancelSession(sessionID)
+		case 5:
+			err = approveScheduleChange(sessionService)
+		case 6:
+			return
+		}
+
+		if err != nil {
+			fmt.Println(" Operation failed:", err)
+		} else {
+			fmt.Println(" Done")
+		}
+	}
+}
+
+// requestScheduleChange offers to hold a refused schedule change for a second
+// ExamCell member, returning the refusal if the user declines
+func requestScheduleChange(refused error, request func(reason string) (int, error)) error {
+	fmt.Println("", refused)
+	if !utils.Confirm("Ask a second ExamCell member to approve it") {
+		return refused
+	}
+
+	requestID, err := request(utils.GetInput("Reason: "))
+	if err != nil {
+		return err
+	}
+	fmt.Printf(" Schedule request #%d recorded. Ask another ExamCell member to approve it.\n", requestID)
+	return nil
+}
+
+func approveScheduleChange(sessionService *services.ExamSessionService) error {
+	requests, err := sessionService.GetPendingScheduleChanges()
+	if err != nil {
+		return err
+	}
+	if len(requests) == 0 {
+		fmt.Println("No pending schedule requests")
+		return nil
+	}
+
+	for _, r := range requests {
+		change := "new session"
+		if r.SessionID > 0 {
+			change = fmt.Sprintf("move session #%d", r.SessionID)
+		}
+		fmt.Printf("\n#%d  Paper %d: %s - %s %q\n", r.ID, r.PaperID, r.PaperTitle, change, r.SessionName)
+		fmt.Printf("    Starts: %s for %d minutes\n", r.ScheduledTime.Local().Format("2006-01-02 15:04"), r.DurationMinutes)
+		fmt.Printf("    Requested by: %s at %s\n", r.RequesterName, r.RequestedAt.Format("2006-01-02 15:04"))
+		fmt.Printf("    Reason: %s\n", r.Reason)
+	}
+
+	requestID := utils.GetChoice("\nEnter request # to approve : ", 1, 999999)
+	if !utils.Confirm("Approve opening this paper earlier") {
+		return nil
+	}
+
+	sessionID, err := sessionService.ApproveScheduleChange(requestID)
+	if err != nil {
+		return err
+	}
+	fmt.Printf(" Session #%d scheduled\n", sessionID)
+	return nil
+}
+
+func readSessionTiming() (time.Time, int, bool) {
+	scheduledStr := utils.GetInput("Start Time (YYYY-MM-DD HH:MM): ")
+	scheduledTime, err := time.ParseInLocation("2006-01-02 15:04", scheduledStr, time.Local)
+	if err != nil {
+		fmt.Println(" Invalid time format. Use YYYY-MM-DD HH:MM")
+		return time.Time{}, 0, false
+	}
+
+	durationMins := utils.GetChoice("Duration (minutes) : ", 1, services.MaxSessionDurationMins)
+	return scheduledTime, durationMins, true
+}
+
 func handleDecryptPaper(user *models.User, paperService *services.PaperService) {
 	fmt.Println("\n" + strings.Repeat("=", 50))
 	fmt.Println(" DECRYPT QUESTION PAPER")
 	fmt.Println(strings.Repeat("=", 50))
 	paperID := utils.GetChoice("Enter Paper ID to decrypt : ", 1, 9999)
+	outputPath := utils.GetInput("Save to file (leave empty to display): ")
+
+	// Large papers are streamed to disk instead of being held in memory
+	if outputPath != "" {
+		if err := paperService.DecryptPaperToFile(paperID, user, outputPath); err != nil {
+			fmt.Println(" Decryption failed:", err)
+		} else {
+			fmt.Println(" Decrypted paper saved to", outputPath)
+		}
+		utils.GetInput("\nPress Enter to continue...")
+		return
+	}
 
 	decryptedContent, err := paperService.DecryptPaper(paperID, user)
 	if err != nil {
@@ -355,17 +977,151 @@ func handleDecryptPaper(user *models.User, paperService *services.PaperService)
 	utils.GetInput("\nPress Enter to continue...")
 }
 
-func studentDashboard(db *sql.DB, user *models.User) {
+func handleExportBundle(user *models.User, paperService *services.PaperService) {
+	fmt.Println("\n" + strings.Repeat("=", 50))
+	fmt.Println(" EXPORT PAPER BUNDLE")
+	fmt.Println(strings.Repeat("=", 50))
+	fmt.Println(" The bundle holds the encrypted paper, its key wrapped for the")
+	fmt.Println(" recipient and the faculty signature. The recipient opens it")
+	fmt.Println(" offline with: go run ./cmd/bundle open")
+
+	paperID := utils.GetChoice("Enter Paper ID : ", 1, 9999)
+	recipient, err := keyfile.LoadPublicKey(utils.GetInput("Recipient public key file: "))
+	if err != nil {
+		fmt.Println("", err)
+		return
+	}
+	outPath := utils.GetInput("Save bundle as: ")
+	if outPath == "" {
+		fmt.Println(" Output path cannot be empty")
+		return
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(outPath), ".bundle-*")
+	if err != nil {
+		fmt.Println(" Failed to create bundle file:", err)
+		return
+	}
+	defer os.Remove(tmp.Name())
+
+	manifest, err := paperService.ExportPaper(paperID, user, recipient, tmp)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), outPath)
+	}
+	if err != nil {
+		fmt.Println(" Export failed:", err)
+		utils.GetInput("\nPress Enter to continue...")
+		return
+	}
+
+	fmt.Printf(" Bundle saved to %s\n", outPath)
+	fmt.Printf(" Recipient: %s\n", manifest.Recipient.Fingerprint)
+	fmt.Printf(" Signer: %s\n", manifest.Signature.SignerFingerprint)
+	fmt.Println(" Send the signer fingerprint separately so the recipient can pin it")
+	utils.GetInput("\nPress Enter to continue...")
+}
+
+func handleSubmitKeyShare(user *models.User, paperService *services.PaperService) {
+	fmt.Println("\n" + strings.Repeat("=", 50))
+	fmt.Println(" SUBMIT KEY SHARE")
+	fmt.Println(strings.Repeat("=", 50))
+	paperID := utils.GetChoice("Enter Paper ID : ", 1, 9999)
+
+	err := paperService.SubmitKeyShare(paperID, user)
+	if err != nil {
+		fmt.Println(" Share submission failed:", err)
+		utils.GetInput("\nPress Enter to continue...")
+		return
+	}
+
+	submitted, threshold, err := paperService.GetShareStatus(paperID)
+	if err == nil {
+		fmt.Printf(" Key share submitted (%d of %d)\n", submitted, threshold)
+		if submitted >= threshold {
+			fmt.Println(" Threshold reached - the paper can now be decrypted")
+		}
+	}
+	utils.GetInput("\nPress Enter to continue...")
+}
+
+func handleRequestOverride(user *models.User, paperService *services.PaperService) {
+	fmt.Println("\n" + strings.Repeat("=", 50))
+	fmt.Println(" REQUEST EMERGENCY OVERRIDE")
+	fmt.Println(strings.Repeat("=", 50))
+	fmt.Println(" Early decryption needs approval from a second ExamCell member.")
+	paperID := utils.GetChoice("Enter Paper ID : ", 1, 9999)
+	reason := utils.GetInput("Reason: ")
+
+	overrideID, err := paperService.Policy.RequestOverride(paperID, user, reason)
+	if err != nil {
+		fmt.Println(" Override request failed:", err)
+		utils.GetInput("\nPress Enter to continue...")
+		return
+	}
+
+	fmt.Printf(" Override request #%d recorded. Ask another ExamCell member to approve it.\n", overrideID)
+	utils.GetInput("\nPress Enter to continue...")
+}
+
+func handleApproveOverride(user *models.User, paperService *services.PaperService) {
+	fmt.Println("\n" + strings.Repeat("=", 50))
+	fmt.Println(" APPROVE EMERGENCY OVERRIDE")
+	fmt.Println(strings.Repeat("=", 50))
+
+	overrides, err := paperService.Policy.GetPendingOverrides()
+	if err != nil {
+		fmt.Println(" Failed to fetch override requests:", err)
+		utils.GetInput("\nPress Enter to continue...")
+		return
+	}
+
+	if len(overrides) == 0 {
+		fmt.Println("No pending override requests")
+		utils.GetInput("\nPress Enter to continue...")
+		return
+	}
+
+	for _, o := range overrides {
+		fmt.Printf("\n#%d  Paper %d: %s\n", o.ID, o.PaperID, o.PaperTitle)
+		fmt.Printf("    Requested by: %s at %s\n", o.RequesterName, o.RequestedAt.Format("2006-01-02 15:04"))
+		fmt.Printf("    Reason: %s\n", o.Reason)
+	}
+
+	overrideID := utils.GetChoice("\nEnter override # to approve : ", 1, 999999)
+	if !utils.Confirm("Approve early decryption") {
+		return
+	}
+
+	if err := paperService.Policy.ApproveOverride(overrideID, user); err != nil {
+		fmt.Println(" Approval failed:", err)
+		utils.GetInput("\nPress Enter to continue...")
+		return
+	}
+
+	fmt.Printf(" Override approved (valid for %s)\n", services.OverrideValidity)
+	utils.GetInput("\nPress Enter to continue...")
+}
+
+func studentDashboard(db *sql.DB, user *models.User, token string) {
 	for {
+		if !sessionActive(db, token) {
+			return
+		}
+
 		fmt.Println("\n" + strings.Repeat("=", 50))
 		fmt.Println("           STUDENT DASHBOARD")
 		fmt.Println(strings.Repeat("=", 50))
 		fmt.Println("1. View Exam Schedule")
 		fmt.Println("2. Try to Access Papers (Blocked)")
-		fmt.Println("3. Logout")
+		fmt.Println("3. Active Sessions")
+		fmt.Println("4. MFA & Recovery Codes")
+		fmt.Println("5. Logout")
 		fmt.Println(strings.Repeat("=", 50))
 
-		choice := utils.GetChoice("Enter your choice : ", 1, 3)
+		choice := utils.GetChoice("Enter your choice : ", 1, 5)
 
 		switch choice {
 		case 1:
@@ -373,6 +1129,10 @@ func studentDashboard(db *sql.DB, user *models.User) {
 		case 2:
 			handleStudentBlockedAccess()
 		case 3:
+			handleActiveSessions(db, user, token)
+		case 4:
+			handleMFASettings(db, user)
+		case 5:
 			return
 		}
 	}
@@ -386,11 +1146,13 @@ func handleViewExamSchedule(db *sql.DB) {
 	query := `
         SELECT title, subject, exam_date 
         FROM question_papers 
-        WHERE exam_date >= CURDATE() 
+        WHERE exam_date >= ? 
         ORDER BY exam_date
     `
 
-	rows, err := db.Query(query)
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	rows, err := db.Query(query, today)
 	if err != nil {
 		fmt.Println(" Failed to fetch schedule:", err)
 		utils.GetInput("\nPress Enter to continue...")
@@ -430,6 +1192,122 @@ func handleStudentBlockedAccess() {
 	utils.GetInput("\nPress Enter to continue...")
 }
 
+func handleActiveSessions(db *sql.DB, user *models.User, token string) {
+	fmt.Println("\n Active Sessions")
+	fmt.Println(strings.Repeat("=", 50))
+
+	currentID, _ := auth.SessionIDForToken(db, token)
+	sessions, err := auth.ListSessions(db, user.ID)
+	if err != nil {
+		fmt.Println("", err)
+		return
+	}
+
+	for _, session := range sessions {
+		current := ""
+		if session.ID == currentID {
+			current = " (this session)"
+		}
+		fmt.Printf("\n#%d %s%s\n", session.ID, session.Source, current)
+		fmt.Printf("    Started: %s\n", session.CreatedAt.Local().Format("2006-01-02 15:04"))
+		fmt.Printf("    Last active: %s\n", session.LastSeenAt.Local().Format("2006-01-02 15:04"))
+		fmt.Printf("    Expires: %s\n", session.ExpiresAt.Local().Format("2006-01-02 15:04"))
+	}
+
+	if !utils.Confirm("\nEnd another session") {
+		return
+	}
+
+	sessionID := utils.GetChoice("Session # : ", 1, 99999999)
+	if sessionID == currentID {
+		fmt.Println(" Use Logout to end this session")
+		return
+	}
+	if err := auth.RevokeUserSession(db, user.ID, sessionID); err != nil {
+		fmt.Println(" Failed to end session:", err)
+		return
+	}
+	fmt.Println(" Session ended")
+}
+
+func handleMFASettings(db *sql.DB, user *models.User) {
+	fmt.Println("\n MFA & Recovery Codes")
+	fmt.Println(strings.Repeat("=", 50))
+
+	remaining, err := auth.RemainingRecoveryCodes(db, user.ID)
+	if err != nil {
+		fmt.Println("", err)
+		return
+	}
+	fmt.Printf(" Unused recovery codes: %d\n", remaining)
+
+	fmt.Println("\n1. Authenticator App")
+	fmt.Println("2. Generate New Recovery Codes")
+	fmt.Println("3. Back")
+
+	switch utils.GetChoice("Enter your choice : ", 1, 3) {
+	case 1:
+		handleAuthenticatorApp(db, user)
+	case 2:
+		if !utils.Confirm("Replace all existing recovery codes") {
+			return
+		}
+		codes, err := auth.GenerateRecoveryCodes(db, user.ID)
+		if err != nil {
+			fmt.Println(" Failed to generate recovery codes:", err)
+			return
+		}
+		printRecoveryCodes(codes)
+	}
+}
+
+func handleAuthenticatorApp(db *sql.DB, user *models.User) {
+	fmt.Println("\n Authenticator App (TOTP)")
+	fmt.Println(strings.Repeat("=", 50))
+
+	enabled, err := auth.TOTPEnabled(db, user.ID)
+	if err != nil {
+		fmt.Println("", err)
+		return
+	}
+
+	if enabled {
+		fmt.Println(" Login codes come from your authenticator app")
+		if !utils.Confirm("\nSwitch back to emailed OTPs") {
+			return
+		}
+		code := utils.GetInput("Current authenticator code: ")
+		if err := auth.DisableTOTP(db, user, code); err != nil {
+			fmt.Println(" Failed to disable authenticator app:", err)
+			return
+		}
+		fmt.Println(" Authenticator app disabled; OTPs will be emailed again")
+		return
+	}
+
+	fmt.Println(" Login codes are currently sent by email")
+	if !utils.Confirm("\nSet up an authenticator app") {
+		return
+	}
+
+	secret, uri, err := auth.BeginTOTPEnrollment(db, user)
+	if err != nil {
+		fmt.Println(" Failed to start enrolment:", err)
+		return
+	}
+
+	fmt.Println("\n Add this account to your authenticator app:")
+	fmt.Println("    URI:", uri)
+	fmt.Println("    Secret (manual entry):", secret)
+
+	code := utils.GetInput("\nEnter the code shown by the app: ")
+	if err := auth.ConfirmTOTPEnrollment(db, user, code); err != nil {
+		fmt.Println(" Enrolment failed:", err)
+		return
+	}
+	fmt.Println(" Authenticator app enabled; emailed OTPs are no longer used")
+}
+
 func showPermissions(db *sql.DB, user *models.User) {
 	fmt.Println("\n Your Permissions")
 	fmt.Println(strings.Repeat("=", 50))
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/internal/database"
+	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/internal/services"
+	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/pkg/blobstore"
+)
+
+const usage = `usage: migrate <command>
+
+commands:
+  up          apply all pending migrations
+  down [n]    roll back the last n migrations (default 1)
+  status      list migrations and whether they are applied
+  blobs       move paper ciphertext still stored inline into the blob store`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Println(usage)
+		os.Exit(2)
+	}
+
+	db, err := database.Connect()
+	if err != nil {
+		log.Fatal("Database connection failed:", err)
+	}
+	defer db.Close()
+
+	switch os.Args[1] {
+	case "up":
+		applied, err := database.MigrateUp(db)
+		for _, migration := range applied {
+			fmt.Printf("applied  %04d_%s\n", migration.Version, migration.Name)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(applied) == 0 {
+			fmt.Println("database is up to date")
+		}
+
+	case "down":
+		steps := 1
+		if len(os.Args) > 2 {
+			steps, err = strconv.Atoi(os.Args[2])
+			if err != nil || steps < 1 {
+				log.Fatalf("invalid step count %q", os.Args[2])
+			}
+		}
+		reverted, err := database.MigrateDown(db, steps)
+		for _, migration := range reverted {
+			fmt.Printf("reverted %04d_%s\n", migration.Version, migration.Name)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(reverted) == 0 {
+			fmt.Println("no migrations to roll back")
+		}
+
+	case "status":
+		states, err := database.MigrationStatus(db)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("Backend: %s\n", database.CurrentDialect().Name())
+		fmt.Println(strings.Repeat("=", 60))
+		for _, state := range states {
+			status := "pending"
+			if state.Applied {
+				status = "applied " + state.AppliedAt.Format("2006-01-02 15:04")
+			}
+			switch {
+			case state.Unknown:
+				status += "  (not in this build)"
+			case state.Modified:
+				status += "  (MODIFIED since applied)"
+			}
+			fmt.Printf("%04d  %-30s %s\n", state.Version, state.Name, status)
+		}
+
+	case "blobs":
+		if err := blobstore.InitFromEnv(); err != nil {
+			log.Fatal(err)
+		}
+		moved, err := services.MoveInlinePapers(db, blobstore.Default())
+		fmt.Printf("moved %d paper(s) to the blob store\n", moved)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+	default:
+		fmt.Println(usage)
+		os.Exit(2)
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/internal/api"
+	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/internal/database"
+	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/internal/services"
+	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/pkg/blobstore"
+	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/pkg/email"
+)
+
+func main() {
+	db, err := database.Connect()
+	if err != nil {
+		log.Fatal("Database connection failed:", err)
+	}
+	defer db.Close()
+
+	if err := database.InitSchema(db); err != nil {
+		log.Fatal("Schema initialization failed:", err)
+	}
+
+	if err := blobstore.InitFromEnv(); err != nil {
+		log.Fatal("Blob store setup failed:", err)
+	}
+	if moved, err := services.MoveInlinePapers(db, blobstore.Default()); err != nil {
+		log.Fatal("Moving papers to the blob store failed:", err)
+	} else if moved > 0 {
+		log.Printf("Moved %d inline paper(s) to the blob store", moved)
+	}
+	if _, err := services.ThresholdFromEnv(); err != nil {
+		log.Fatal("Key release setup failed:", err)
+	}
+	if escrow, err := services.InitEscrowFromEnv(); err != nil {
+		log.Fatal("Key escrow setup failed:", err)
+	} else if escrow != nil {
+		log.Printf("Paper keys are escrowed to %s", escrow.Fingerprint)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := email.StartOutbox(ctx, db); err != nil {
+		log.Fatal("Email setup failed:", err)
+	}
+
+	addr := os.Getenv("HTTP_ADDR")
+	if addr == "" {
+		addr = ":8080"
+	}
+
+	server := newHTTPServer(db, addr)
+
+	log.Printf("Secure Exam Paper Distribution API listening on %s", addr)
+	if err := server.ListenAndServe(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// newHTTPServer serves the API on addr with timeouts against slow clients
+func newHTTPServer(db *sql.DB, addr string) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           api.NewServer(db).Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       60 * time.Second,
+		WriteTimeout:      60 * time.Second,
+		IdleTimeout:       120 * time.Second,
+	}
+}
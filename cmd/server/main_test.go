@@ -0,0 +1,47 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/internal/database/dbtest"
+)
+
+func TestServerRoutesAPI(t *testing.T) {
+	server := newHTTPServer(dbtest.Open(t), ":0")
+	if server.ReadHeaderTimeout == 0 || server.WriteTimeout == 0 {
+		t.Fatal("server has no timeouts")
+	}
+
+	ts := httptest.NewServer(server.Handler)
+	defer ts.Close()
+
+	tests := []struct {
+		method, path, body string
+		want               int
+	}{
+		{"GET", "/api/papers", "", http.StatusUnauthorized},
+		{"GET", "/api/sessions", "", http.StatusUnauthorized},
+		{"POST", "/api/register", `{"username": "", "role": "Faculty"}`, http.StatusBadRequest},
+		{"POST", "/api/register", `{"username": "", "role": "ExamCell"}`, http.StatusUnauthorized},
+		{"POST", "/api/login", `{"unknown": true}`, http.StatusBadRequest},
+		{"DELETE", "/api/papers", "", http.StatusMethodNotAllowed},
+		{"GET", "/api/nothing", "", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		req, err := http.NewRequest(tt.method, ts.URL+tt.path, strings.NewReader(tt.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.want {
+			t.Errorf("%s %s: status %d, want %d", tt.method, tt.path, resp.StatusCode, tt.want)
+		}
+	}
+}
@@ -43,6 +43,11 @@ func EnforcePermission(db *sql.DB, user *models.User, objectType, action string,
 	return nil
 }
 
+// LogAction records a security-relevant action that is not a permission check
+func LogAction(db *sql.DB, userID int, action, objectType string, objectID *int, success bool, details string) {
+	logAuditEntry(db, userID, action, objectType, objectID, success, details)
+}
+
 // logAuditEntry records access attempts to audit log
 func logAuditEntry(db *sql.DB, userID int, action, objectType string, objectID *int, success bool, details string) {
 	query := `
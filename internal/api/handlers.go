@@ -0,0 +1,1021 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/internal/auth"
+	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/internal/crypto"
+	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/internal/models"
+	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/internal/services"
+)
+
+type userResponse struct {
+	ID       int    `json:"id"`
+	Username string `json:"username"`
+	Email    string `json:"email"`
+	Role     string `json:"role"`
+}
+
+type paperResponse struct {
+	ID               int    `json:"id"`
+	Title            string `json:"title"`
+	Subject          string `json:"subject"`
+	FacultyName      string `json:"faculty_name,omitempty"`
+	UploadDate       string `json:"upload_date"`
+	ExamDate         string `json:"exam_date"`
+	Status           string `json:"status"`
+	ReleaseThreshold int    `json:"release_threshold"`
+	ReviewComments   string `json:"review_comments,omitempty"`
+	RevisionOf       int    `json:"revision_of,omitempty"`
+	// Set after a key compromise until the paper is re-signed or re-encrypted
+	SignerCompromised   bool `json:"signer_compromised,omitempty"`
+	ReencryptionPending bool `json:"reencryption_pending,omitempty"`
+}
+
+type affectedPaperResponse struct {
+	ID       int    `json:"id"`
+	Title    string `json:"title"`
+	Subject  string `json:"subject"`
+	Status   string `json:"status"`
+	ExamDate string `json:"exam_date"`
+}
+
+type sessionResponse struct {
+	ID              int    `json:"id"`
+	PaperID         int    `json:"paper_id"`
+	PaperTitle      string `json:"paper_title"`
+	SessionName     string `json:"session_name"`
+	ScheduledTime   string `json:"scheduled_time"`
+	DurationMinutes int    `json:"duration_minutes"`
+	Status          string `json:"status"`
+}
+
+func toUserResponse(user *models.User) userResponse {
+	return userResponse{ID: user.ID, Username: user.Username, Email: user.Email, Role: user.Role}
+}
+
+func toPaperResponse(paper models.QuestionPaper) paperResponse {
+	return paperResponse{
+		ID:               paper.ID,
+		Title:            paper.Title,
+		Subject:          paper.Subject,
+		FacultyName:      paper.FacultyName,
+		UploadDate:       paper.UploadDate.Format(time.RFC3339),
+		ExamDate:         paper.ExamDate.Format("2006-01-02"),
+		Status:           paper.Status,
+		ReleaseThreshold: paper.ReleaseThreshold,
+		ReviewComments:   paper.ReviewComments,
+		RevisionOf:       paper.RevisionOf,
+
+		SignerCompromised:   paper.SignerCompromised,
+		ReencryptionPending: paper.ReencryptionPending,
+	}
+}
+
+func toAffectedPaperResponses(papers []services.AffectedPaper) []affectedPaperResponse {
+	response := make([]affectedPaperResponse, 0, len(papers))
+	for _, paper := range papers {
+		response = append(response, affectedPaperResponse{
+			ID:       paper.ID,
+			Title:    paper.Title,
+			Subject:  paper.Subject,
+			Status:   paper.Status,
+			ExamDate: paper.ExamDate.Format("2006-01-02"),
+		})
+	}
+	return response
+}
+
+func toSessionResponse(session models.ExamSession) sessionResponse {
+	return sessionResponse{
+		ID:              session.ID,
+		PaperID:         session.PaperID,
+		PaperTitle:      session.PaperTitle,
+		SessionName:     session.SessionName,
+		ScheduledTime:   session.ScheduledTime.Format(time.RFC3339),
+		DurationMinutes: session.DurationMinutes,
+		Status:          session.Status,
+	}
+}
+
+// pathID parses a numeric {id} path parameter
+func pathID(r *http.Request) (int, error) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("invalid id")
+	}
+	return id, nil
+}
+
+func (s *Server) handleRegister(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+		Email    string `json:"email"`
+		Role     string `json:"role"`
+	}
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	// Faculty may sign themselves up; every other role is created by a logged-in ExamCell member
+	if !strings.EqualFold(strings.TrimSpace(req.Role), "Faculty") {
+		caller, _, err := auth.ValidateSession(s.DB, bearerToken(r))
+		if err != nil {
+			writeError(w, http.StatusUnauthorized, fmt.Errorf("only Faculty may self-register; other roles need an ExamCell login"))
+			return
+		}
+		if caller.Role != "ExamCell" {
+			writeError(w, http.StatusForbidden, fmt.Errorf("only ExamCell can create %s accounts", req.Role))
+			return
+		}
+	}
+
+	user, err := auth.RegisterUser(s.DB, req.Username, req.Password, req.Email, req.Role)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	codes, err := auth.GenerateRecoveryCodes(s.DB, user.ID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, map[string]interface{}{
+		"user":           toUserResponse(user),
+		"recovery_codes": codes,
+	})
+}
+
+// handleLogin is step one: verify the password and send an OTP
+func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	user, err := auth.AuthenticateUser(s.DB, req.Username, req.Password, clientSource(r))
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	// The password is only available now, so unlock the key before discarding it
+	if err := auth.UnlockPrivateKey(s.DB, user, req.Password); err != nil {
+		writeError(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	method, err := auth.InitiateMFA(s.DB, user)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Errorf("MFA initiation failed: %w", err))
+		return
+	}
+
+	loginID, err := s.startLogin(user)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	message := "OTP sent to registered email"
+	if method == auth.MFATOTP {
+		message = "Enter the code from your authenticator app"
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{
+		"login_id":   loginID,
+		"mfa_method": method,
+		"message":    message,
+	})
+}
+
+// handleVerifyOTP is step two: verify the OTP and issue a session token
+func (s *Server) handleVerifyOTP(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		LoginID string `json:"login_id"`
+		OTP     string `json:"otp"`
+	}
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	pending, ok := s.takePendingLogin(req.LoginID)
+	if !ok {
+		writeError(w, http.StatusUnauthorized, fmt.Errorf("login expired; please start again"))
+		return
+	}
+	user := pending.User
+
+	token, err := auth.CompleteLogin(s.DB, user, req.OTP, clientSource(r))
+	if err != nil {
+		// Only a plain wrong code may be retried; a lockout or burnt OTP ends the login
+		if errors.Is(err, auth.ErrInvalidOTP) {
+			s.putBackPendingLogin(req.LoginID, pending)
+		}
+		writeError(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	sessionID, err := auth.SessionIDForToken(s.DB, token)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	s.rememberKey(sessionID, user.PrivateKey)
+	services.StartKeyRewrap(s.DB, user)
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"token":      token,
+		"expires_in": int(auth.SessionTTL.Seconds()),
+		"user":       toUserResponse(user),
+	})
+}
+
+func (s *Server) handleLogout(w http.ResponseWriter, r *http.Request, user *models.User) {
+	token := bearerToken(r)
+	if sessionID, err := auth.SessionIDForToken(s.DB, token); err == nil {
+		s.forgetKey(sessionID)
+	}
+
+	if err := auth.RevokeSession(s.DB, token); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleRefresh swaps a valid token for a new one, carrying the unlocked key over
+func (s *Server) handleRefresh(w http.ResponseWriter, r *http.Request) {
+	token := bearerToken(r)
+	oldSessionID, err := auth.SessionIDForToken(s.DB, token)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, fmt.Errorf("invalid or expired session"))
+		return
+	}
+
+	newToken, err := auth.RefreshSession(s.DB, token)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	newSessionID, err := auth.SessionIDForToken(s.DB, newToken)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	s.mu.Lock()
+	key := s.keys[oldSessionID]
+	s.mu.Unlock()
+	s.forgetKey(oldSessionID)
+	s.rememberKey(newSessionID, key)
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"token":      newToken,
+		"expires_in": int(auth.SessionTTL.Seconds()),
+	})
+}
+
+func (s *Server) handleListUserSessions(w http.ResponseWriter, r *http.Request, user *models.User) {
+	currentID, _ := auth.SessionIDForToken(s.DB, bearerToken(r))
+
+	sessions, err := auth.ListSessions(s.DB, user.ID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	response := make([]map[string]interface{}, 0, len(sessions))
+	for _, session := range sessions {
+		response = append(response, map[string]interface{}{
+			"id":           session.ID,
+			"source":       session.Source,
+			"created_at":   session.CreatedAt.Format(time.RFC3339),
+			"last_seen_at": session.LastSeenAt.Format(time.RFC3339),
+			"expires_at":   session.ExpiresAt.Format(time.RFC3339),
+			"current":      session.ID == currentID,
+		})
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+func (s *Server) handleRevokeUserSession(w http.ResponseWriter, r *http.Request, user *models.User) {
+	sessionID, err := pathID(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := auth.RevokeUserSession(s.DB, user.ID, sessionID); err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	s.forgetKey(sessionID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleBeginTOTP starts authenticator app enrolment
+func (s *Server) handleBeginTOTP(w http.ResponseWriter, r *http.Request, user *models.User) {
+	secret, uri, err := auth.BeginTOTPEnrollment(s.DB, user)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{
+		"secret":      secret,
+		"otpauth_uri": uri,
+	})
+}
+
+// handleConfirmTOTP enables the authenticator app once a first code checks out
+func (s *Server) handleConfirmTOTP(w http.ResponseWriter, r *http.Request, user *models.User) {
+	var req struct {
+		Code string `json:"code"`
+	}
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := auth.ConfirmTOTPEnrollment(s.DB, user, req.Code); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleDisableTOTP switches the user back to emailed OTPs
+func (s *Server) handleDisableTOTP(w http.ResponseWriter, r *http.Request, user *models.User) {
+	var req struct {
+		Code string `json:"code"`
+	}
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := auth.DisableTOTP(s.DB, user, req.Code); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleRecoveryCodeStatus(w http.ResponseWriter, r *http.Request, user *models.User) {
+	remaining, err := auth.RemainingRecoveryCodes(s.DB, user.ID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]int{"remaining": remaining})
+}
+
+// handleRegenerateRecoveryCodes replaces all recovery codes; the response is the only copy
+func (s *Server) handleRegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request, user *models.User) {
+	codes, err := auth.GenerateRecoveryCodes(s.DB, user.ID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"recovery_codes": codes})
+}
+
+// handleRotateKeys replaces the caller's key pair and re-wraps their paper keys.
+// This session's cached key is swapped for the new one; other sessions keep
+// the retired key, which can no longer open or sign anything.
+func (s *Server) handleRotateKeys(w http.ResponseWriter, r *http.Request, user *models.User) {
+	var req struct {
+		Password     string `json:"password"`
+		KeyAlgorithm string `json:"key_algorithm"`
+	}
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	rotation, err := services.RotateUserKeys(s.DB, user, req.Password, req.KeyAlgorithm)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	if sessionID, err := auth.SessionIDForToken(s.DB, bearerToken(r)); err == nil {
+		s.rememberKey(sessionID, user.PrivateKey)
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"key_id":        rotation.KeyID,
+		"key_algorithm": rotation.NewAlgorithm,
+		"rewrapped":     rotation.Rewrapped + rotation.Adopted,
+	})
+}
+
+func (s *Server) handleListLockedAccounts(w http.ResponseWriter, r *http.Request, user *models.User) {
+	accounts, err := auth.GetLockedAccounts(s.DB, user)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	response := make([]map[string]interface{}, 0, len(accounts))
+	for _, account := range accounts {
+		response = append(response, map[string]interface{}{
+			"user_id":      account.UserID,
+			"username":     account.Username,
+			"role":         account.Role,
+			"locked_until": account.LockedUntil.Format(time.RFC3339),
+		})
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+func (s *Server) handleUnlockAccount(w http.ResponseWriter, r *http.Request, user *models.User) {
+	userID, err := pathID(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := auth.UnlockAccount(s.DB, user, userID); err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleListUserKeys(w http.ResponseWriter, r *http.Request, user *models.User) {
+	userID, err := pathID(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	keys, err := services.NewKeyCompromiseService(s.DB, user).GetUserKeys(userID)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	response := make([]map[string]interface{}, 0, len(keys))
+	for _, key := range keys {
+		entry := map[string]interface{}{
+			"id":            key.ID,
+			"key_algorithm": key.KeyAlgorithm,
+			"status":        key.Status,
+			"public_key":    key.PublicKey,
+			"created_at":    key.CreatedAt.Format(time.RFC3339),
+		}
+		if key.RetiredAt != nil {
+			entry["retired_at"] = key.RetiredAt.Format(time.RFC3339)
+		}
+		if key.CompromisedAt != nil {
+			entry["compromised_at"] = key.CompromisedAt.Format(time.RFC3339)
+			entry["compromise_reason"] = key.CompromiseReason
+		}
+		response = append(response, entry)
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+func (s *Server) handleReportCompromise(w http.ResponseWriter, r *http.Request, user *models.User) {
+	keyID, err := pathID(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	report, err := services.NewKeyCompromiseService(s.DB, user).ReportCompromise(keyID, req.Reason)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	sessions := make([]sessionResponse, 0, len(report.Sessions))
+	for _, session := range report.Sessions {
+		sessions = append(sessions, toSessionResponse(session))
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"key_id":         report.Key.ID,
+		"user_id":        report.Key.UserID,
+		"username":       report.Username,
+		"key_active":     report.KeyActive,
+		"signed_papers":  toAffectedPaperResponses(report.SignedPapers),
+		"wrapped_papers": toAffectedPaperResponses(report.WrappedPapers),
+		"sessions":       sessions,
+	})
+}
+
+func (s *Server) handleListReencryptions(w http.ResponseWriter, r *http.Request, user *models.User) {
+	papers, err := services.NewKeyCompromiseService(s.DB, user).GetPendingReencryptions()
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, toAffectedPaperResponses(papers))
+}
+
+func (s *Server) handleEmailQueue(w http.ResponseWriter, r *http.Request, user *models.User) {
+	outboxService := services.NewOutboxService(s.DB, user)
+
+	counts, err := outboxService.GetQueueCounts()
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	messages, err := outboxService.GetUndelivered(100)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	undelivered := make([]map[string]interface{}, 0, len(messages))
+	for _, msg := range messages {
+		undelivered = append(undelivered, map[string]interface{}{
+			"id":              msg.ID,
+			"recipient":       msg.Recipient,
+			"subject":         msg.Subject,
+			"template":        msg.Template,
+			"status":          msg.Status,
+			"attempts":        msg.Attempts,
+			"next_attempt_at": msg.NextAttemptAt.Format(time.RFC3339),
+			"last_error":      msg.LastError,
+			"created_at":      msg.CreatedAt.Format(time.RFC3339),
+		})
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"counts":      counts,
+		"undelivered": undelivered,
+	})
+}
+
+func (s *Server) handleListPapers(w http.ResponseWriter, r *http.Request, user *models.User) {
+	paperService := services.NewPaperService(s.DB)
+
+	var papers []models.QuestionPaper
+	var err error
+	switch user.Role {
+	case "Faculty":
+		if err = services.NewFacultyService(s.DB, user).CanViewOwnPapers(); err == nil {
+			papers, err = paperService.GetFacultyPapers(user.ID)
+		}
+	case "ExamCell":
+		if err = services.NewExamCellService(s.DB, user).CanViewAllPapers(); err == nil {
+			papers, err = paperService.GetAllPapers()
+		}
+	default:
+		err = services.NewStudentService(s.DB, user).CanAccessPaper()
+	}
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	response := make([]paperResponse, 0, len(papers))
+	for _, paper := range papers {
+		response = append(response, toPaperResponse(paper))
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+func (s *Server) handleUploadPaper(w http.ResponseWriter, r *http.Request, user *models.User) {
+	var req struct {
+		Title    string `json:"title"`
+		Subject  string `json:"subject"`
+		ExamDate string `json:"exam_date"`
+		Content  string `json:"content"` // base64
+	}
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	facultyService := services.NewFacultyService(s.DB, user)
+	if err := facultyService.CanUploadPaper(); err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	if err := facultyService.CanEncrypt(); err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	if req.Title == "" || req.Subject == "" {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("title and subject are required"))
+		return
+	}
+
+	examDate, err := time.Parse("2006-01-02", req.ExamDate)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid exam_date, use YYYY-MM-DD"))
+		return
+	}
+
+	content, err := crypto.DecodeBase64(req.Content)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("content must be base64 encoded"))
+		return
+	}
+
+	paperID, err := services.NewPaperService(s.DB).UploadPaperContent(user, req.Title, req.Subject, content, examDate)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, map[string]int{"id": paperID})
+}
+
+func (s *Server) handleDecryptPaper(w http.ResponseWriter, r *http.Request, user *models.User) {
+	paperID, err := pathID(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := services.NewExamCellService(s.DB, user).CanDecryptPaper(); err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	content, err := services.NewPaperService(s.DB).DecryptPaper(paperID, user)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"id":      paperID,
+		"content": crypto.EncodeBase64(content),
+	})
+}
+
+// handleShareStatus reports how many key shares a threshold paper has collected
+func (s *Server) handleShareStatus(w http.ResponseWriter, r *http.Request, user *models.User) {
+	paperID, err := pathID(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := services.NewExamCellService(s.DB, user).CanDecryptPaper(); err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	submitted, threshold, err := services.NewPaperService(s.DB).GetShareStatus(paperID)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]int{
+		"submitted": submitted,
+		"threshold": threshold,
+	})
+}
+
+// handleSubmitKeyShare releases the caller's share of a threshold paper key
+func (s *Server) handleSubmitKeyShare(w http.ResponseWriter, r *http.Request, user *models.User) {
+	paperID, err := pathID(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	paperService := services.NewPaperService(s.DB)
+	if err := paperService.SubmitKeyShare(paperID, user); err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	submitted, threshold, err := paperService.GetShareStatus(paperID)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]int{
+		"submitted": submitted,
+		"threshold": threshold,
+	})
+}
+
+func (s *Server) handleReencryptPaper(w http.ResponseWriter, r *http.Request, user *models.User) {
+	paperID, err := pathID(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := services.NewPaperService(s.DB).ReencryptPaper(paperID, user); err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleResignPaper(w http.ResponseWriter, r *http.Request, user *models.User) {
+	paperID, err := pathID(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var req struct {
+		Content string `json:"content"` // base64 of the file as uploaded
+	}
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	content, err := crypto.DecodeBase64(req.Content)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("content must be base64 encoded"))
+		return
+	}
+
+	if err := services.NewPaperService(s.DB).ResignPaper(paperID, user, bytes.NewReader(content)); err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleResubmitPaper uploads the revised content of a rejected paper as a new paper
+func (s *Server) handleResubmitPaper(w http.ResponseWriter, r *http.Request, user *models.User) {
+	paperID, err := pathID(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var req struct {
+		Content  string `json:"content"` // base64 of the revised paper
+		Comments string `json:"comments"`
+	}
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := services.NewFacultyService(s.DB, user).CanEncrypt(); err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	content, err := crypto.DecodeBase64(req.Content)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("content must be base64 encoded"))
+		return
+	}
+	if len(content) == 0 {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("the revised paper content is required"))
+		return
+	}
+
+	revisionID, err := services.NewPaperService(s.DB).ResubmitPaper(user, paperID, bytes.NewReader(content), req.Comments)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, map[string]int{"id": revisionID, "revision_of": paperID})
+}
+
+func (s *Server) handleExportPaper(w http.ResponseWriter, r *http.Request, user *models.User) {
+	paperID, err := pathID(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var req struct {
+		RecipientPublicKey string `json:"recipient_public_key"` // PEM
+	}
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	recipient, err := crypto.ParsePublicKey(req.RecipientPublicKey)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid recipient_public_key: %w", err))
+		return
+	}
+
+	// The bundle is built in memory so a failure can still be reported as JSON
+	var buf bytes.Buffer
+	if _, err := services.NewPaperService(s.DB).ExportPaper(paperID, user, recipient, &buf); err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-tar")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="paper-%d.bundle"`, paperID))
+	w.WriteHeader(http.StatusOK)
+	w.Write(buf.Bytes())
+}
+
+func (s *Server) handleListSessions(w http.ResponseWriter, r *http.Request, user *models.User) {
+	sessions, err := services.NewExamSessionService(s.DB, user).GetSessions()
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	response := make([]sessionResponse, 0, len(sessions))
+	for _, session := range sessions {
+		response = append(response, toSessionResponse(session))
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+type sessionTimingRequest struct {
+	ScheduledTime   string `json:"scheduled_time"` // RFC 3339
+	DurationMinutes int    `json:"duration_minutes"`
+}
+
+func (s *Server) handleScheduleSession(w http.ResponseWriter, r *http.Request, user *models.User) {
+	var req struct {
+		PaperID int    `json:"paper_id"`
+		Name    string `json:"name"`
+		sessionTimingRequest
+	}
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	scheduledTime, err := time.Parse(time.RFC3339, req.ScheduledTime)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid scheduled_time, use RFC 3339"))
+		return
+	}
+
+	sessionID, err := services.NewExamSessionService(s.DB, user).ScheduleSession(req.PaperID, req.Name, scheduledTime, req.DurationMinutes)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, map[string]int{"id": sessionID})
+}
+
+func (s *Server) handleRescheduleSession(w http.ResponseWriter, r *http.Request, user *models.User) {
+	sessionID, err := pathID(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var req sessionTimingRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	scheduledTime, err := time.Parse(time.RFC3339, req.ScheduledTime)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid scheduled_time, use RFC 3339"))
+		return
+	}
+
+	err = services.NewExamSessionService(s.DB, user).RescheduleSession(sessionID, scheduledTime, req.DurationMinutes)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleStartSession(w http.ResponseWriter, r *http.Request, user *models.User) {
+	sessionID, err := pathID(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := services.NewExamSessionService(s.DB, user).StartSession(sessionID); err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleCancelSession(w http.ResponseWriter, r *http.Request, user *models.User) {
+	sessionID, err := pathID(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := services.NewExamSessionService(s.DB, user).CancelSession(sessionID); err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleListScheduleRequests(w http.ResponseWriter, r *http.Request, user *models.User) {
+	requests, err := services.NewExamSessionService(s.DB, user).GetPendingScheduleChanges()
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	response := make([]map[string]interface{}, 0, len(requests))
+	for _, req := range requests {
+		response = append(response, map[string]interface{}{
+			"id":               req.ID,
+			"paper_id":         req.PaperID,
+			"paper_title":      req.PaperTitle,
+			"session_id":       req.SessionID,
+			"session_name":     req.SessionName,
+			"scheduled_time":   req.ScheduledTime.Format(time.RFC3339),
+			"duration_minutes": req.DurationMinutes,
+			"reason":           req.Reason,
+			"requested_by":     req.RequesterName,
+			"requested_at":     req.RequestedAt.Format(time.RFC3339),
+		})
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// handleRequestScheduleChange holds a session change refused with 409 for a
+// second ExamCell member: a new session when session_id is 0, else a reschedule
+func (s *Server) handleRequestScheduleChange(w http.ResponseWriter, r *http.Request, user *models.User) {
+	var req struct {
+		PaperID   int    `json:"paper_id"`
+		SessionID int    `json:"session_id"`
+		Name      string `json:"name"`
+		Reason    string `json:"reason"`
+		sessionTimingRequest
+	}
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	scheduledTime, err := time.Parse(time.RFC3339, req.ScheduledTime)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid scheduled_time, use RFC 3339"))
+		return
+	}
+
+	sessionService := services.NewExamSessionService(s.DB, user)
+	var requestID int
+	if req.SessionID > 0 {
+		requestID, err = sessionService.RequestReschedule(req.SessionID, scheduledTime, req.DurationMinutes, req.Reason)
+	} else {
+		requestID, err = sessionService.RequestNewSession(req.PaperID, req.Name, scheduledTime, req.DurationMinutes, req.Reason)
+	}
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, map[string]int{"id": requestID})
+}
+
+func (s *Server) handleApproveScheduleChange(w http.ResponseWriter, r *http.Request, user *models.User) {
+	requestID, err := pathID(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	sessionID, err := services.NewExamSessionService(s.DB, user).ApproveScheduleChange(requestID)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]int{"session_id": sessionID})
+}
@@ -0,0 +1,262 @@
+package api
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/internal/acl"
+	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/internal/auth"
+	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/internal/crypto"
+	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/internal/models"
+	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/internal/services"
+)
+
+const (
+	MaxRequestBytes = 20 << 20 // 20 MB
+	LoginIDBytes    = 32
+)
+
+// pendingLogin is a password-verified user waiting for their OTP
+type pendingLogin struct {
+	User      *models.User
+	ExpiresAt time.Time
+}
+
+// Server exposes the portal as a JSON API.
+// Identity is carried by signed session tokens; unlocked private keys are
+// cached in memory per session and are lost when the server restarts.
+type Server struct {
+	DB *sql.DB
+
+	mu      sync.Mutex
+	pending map[string]*pendingLogin
+	keys    map[int]crypto.PrivateKey // session ID -> unlocked private key
+}
+
+// NewServer creates a new API server
+func NewServer(db *sql.DB) *Server {
+	return &Server{
+		DB:      db,
+		pending: make(map[string]*pendingLogin),
+		keys:    make(map[int]crypto.PrivateKey),
+	}
+}
+
+// Handler returns the HTTP routes of the API
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("POST /api/register", s.handleRegister)
+	mux.HandleFunc("POST /api/login", s.handleLogin)
+	mux.HandleFunc("POST /api/login/verify", s.handleVerifyOTP)
+	mux.HandleFunc("POST /api/logout", s.requireUser(s.handleLogout))
+	mux.HandleFunc("POST /api/auth/refresh", s.handleRefresh)
+	mux.HandleFunc("GET /api/auth/sessions", s.requireUser(s.handleListUserSessions))
+	mux.HandleFunc("DELETE /api/auth/sessions/{id}", s.requireUser(s.handleRevokeUserSession))
+	mux.HandleFunc("POST /api/auth/totp", s.requireUser(s.handleBeginTOTP))
+	mux.HandleFunc("POST /api/auth/totp/confirm", s.requireUser(s.handleConfirmTOTP))
+	mux.HandleFunc("POST /api/auth/totp/disable", s.requireUser(s.handleDisableTOTP))
+	mux.HandleFunc("GET /api/auth/recovery-codes", s.requireUser(s.handleRecoveryCodeStatus))
+	mux.HandleFunc("POST /api/auth/recovery-codes", s.requireUser(s.handleRegenerateRecoveryCodes))
+	mux.HandleFunc("POST /api/auth/keys/rotate", s.requireUser(s.handleRotateKeys))
+
+	mux.HandleFunc("GET /api/users/locked", s.requireUser(s.handleListLockedAccounts))
+	mux.HandleFunc("POST /api/users/{id}/unlock", s.requireUser(s.handleUnlockAccount))
+	mux.HandleFunc("GET /api/users/{id}/keys", s.requireUser(s.handleListUserKeys))
+	mux.HandleFunc("POST /api/keys/{id}/compromise", s.requireUser(s.handleReportCompromise))
+	mux.HandleFunc("GET /api/reencryptions", s.requireUser(s.handleListReencryptions))
+
+	mux.HandleFunc("GET /api/outbox", s.requireUser(s.handleEmailQueue))
+
+	mux.HandleFunc("GET /api/papers", s.requireUser(s.handleListPapers))
+	mux.HandleFunc("POST /api/papers", s.requireUser(s.handleUploadPaper))
+	mux.HandleFunc("POST /api/papers/{id}/decrypt", s.requireUser(s.handleDecryptPaper))
+	mux.HandleFunc("GET /api/papers/{id}/shares", s.requireUser(s.handleShareStatus))
+	mux.HandleFunc("POST /api/papers/{id}/shares", s.requireUser(s.handleSubmitKeyShare))
+	mux.HandleFunc("POST /api/papers/{id}/reencrypt", s.requireUser(s.handleReencryptPaper))
+	mux.HandleFunc("POST /api/papers/{id}/resign", s.requireUser(s.handleResignPaper))
+	mux.HandleFunc("POST /api/papers/{id}/resubmit", s.requireUser(s.handleResubmitPaper))
+	mux.HandleFunc("POST /api/papers/{id}/export", s.requireUser(s.handleExportPaper))
+
+	mux.HandleFunc("GET /api/sessions", s.requireUser(s.handleListSessions))
+	mux.HandleFunc("POST /api/sessions", s.requireUser(s.handleScheduleSession))
+	mux.HandleFunc("POST /api/sessions/{id}/reschedule", s.requireUser(s.handleRescheduleSession))
+	mux.HandleFunc("POST /api/sessions/{id}/start", s.requireUser(s.handleStartSession))
+	mux.HandleFunc("POST /api/sessions/{id}/cancel", s.requireUser(s.handleCancelSession))
+	mux.HandleFunc("GET /api/sessions/requests", s.requireUser(s.handleListScheduleRequests))
+	mux.HandleFunc("POST /api/sessions/requests", s.requireUser(s.handleRequestScheduleChange))
+	mux.HandleFunc("POST /api/sessions/requests/{id}/approve", s.requireUser(s.handleApproveScheduleChange))
+
+	return mux
+}
+
+// userHandler is an HTTP handler that runs for an authenticated user
+type userHandler func(w http.ResponseWriter, r *http.Request, user *models.User)
+
+// requireUser resolves the bearer token to a logged-in user
+func (s *Server) requireUser(next userHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := bearerToken(r)
+		if token == "" {
+			writeError(w, http.StatusUnauthorized, fmt.Errorf("missing bearer token"))
+			return
+		}
+
+		user, session, err := auth.ValidateSession(s.DB, token)
+		if err != nil {
+			writeError(w, http.StatusUnauthorized, err)
+			return
+		}
+
+		s.mu.Lock()
+		user.PrivateKey = s.keys[session.ID]
+		s.mu.Unlock()
+
+		next(w, r, user)
+	}
+}
+
+// startLogin remembers a password-verified user until the OTP arrives
+func (s *Server) startLogin(user *models.User) (string, error) {
+	loginID, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for id, p := range s.pending {
+		if now.After(p.ExpiresAt) {
+			delete(s.pending, id)
+		}
+	}
+
+	s.pending[loginID] = &pendingLogin{
+		User:      user,
+		ExpiresAt: now.Add(auth.OTPValidityMins * time.Minute),
+	}
+	return loginID, nil
+}
+
+// takePendingLogin removes and returns a pending login
+func (s *Server) takePendingLogin(loginID string) (*pendingLogin, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, ok := s.pending[loginID]
+	if !ok {
+		return nil, false
+	}
+	delete(s.pending, loginID)
+
+	if time.Now().After(p.ExpiresAt) {
+		return nil, false
+	}
+	return p, true
+}
+
+// putBackPendingLogin restores a pending login after a wrong OTP, keeping its
+// original expiry so retries cannot hold the unlocked key in memory indefinitely
+func (s *Server) putBackPendingLogin(loginID string, p *pendingLogin) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.pending[loginID] = p
+}
+
+// rememberKey caches a session's unlocked private key and drops keys of ended sessions
+func (s *Server) rememberKey(sessionID int, key crypto.PrivateKey) {
+	active, err := auth.ActiveSessionIDs(s.DB)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err == nil {
+		for id := range s.keys {
+			if !active[id] {
+				delete(s.keys, id)
+			}
+		}
+	}
+	if key != nil {
+		s.keys[sessionID] = key
+	}
+}
+
+// forgetKey removes a session's unlocked private key
+func (s *Server) forgetKey(sessionID int) {
+	s.mu.Lock()
+	delete(s.keys, sessionID)
+	s.mu.Unlock()
+}
+
+func randomToken() (string, error) {
+	b := make([]byte, LoginIDBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// clientSource identifies the client for session and audit records
+func clientSource(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func bearerToken(r *http.Request) string {
+	header := r.Header.Get("Authorization")
+	if !strings.HasPrefix(header, "Bearer ") {
+		return ""
+	}
+	return strings.TrimSpace(strings.TrimPrefix(header, "Bearer "))
+}
+
+// decodeJSON reads a size-limited JSON request body
+func decodeJSON(w http.ResponseWriter, r *http.Request, v interface{}) error {
+	r.Body = http.MaxBytesReader(w, r.Body, MaxRequestBytes)
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return fmt.Errorf("invalid request body: %w", err)
+	}
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+// writeServiceError maps service errors to HTTP status codes
+func writeServiceError(w http.ResponseWriter, err error) {
+	var denied *acl.AccessDeniedError
+	if errors.As(err, &denied) {
+		writeError(w, http.StatusForbidden, err)
+		return
+	}
+	if errors.Is(err, services.ErrReleaseMovedEarlier) {
+		writeError(w, http.StatusConflict, err)
+		return
+	}
+	writeError(w, http.StatusBadRequest, err)
+}
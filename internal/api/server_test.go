@@ -0,0 +1,534 @@
+package api
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/internal/auth"
+	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/internal/crypto"
+	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/internal/database/dbtest"
+	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/internal/models"
+	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/internal/services"
+	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/pkg/blobstore"
+	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/pkg/email"
+)
+
+const testPassword = "Correct#Horse1"
+
+// mailbox keeps every message sent during a test
+type mailbox struct {
+	mu       sync.Mutex
+	messages []email.Message
+}
+
+func (m *mailbox) Send(msg email.Message) error {
+	m.mu.Lock()
+	m.messages = append(m.messages, msg)
+	m.mu.Unlock()
+	return nil
+}
+
+// lastOTP returns the most recent OTP sent to an address
+func (m *mailbox) lastOTP(t *testing.T, to string) string {
+	t.Helper()
+	code := regexp.MustCompile(fmt.Sprintf(`\b\d{%d}\b`, auth.OTPLength))
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := len(m.messages) - 1; i >= 0; i-- {
+		msg := m.messages[i]
+		if msg.To == to && msg.Template == email.TemplateOTP {
+			if otp := code.FindString(msg.Body); otp != "" {
+				return otp
+			}
+		}
+	}
+	t.Fatalf("no OTP sent to %s", to)
+	return ""
+}
+
+// testAPI is a running API server on a fresh SQLite database
+type testAPI struct {
+	t    *testing.T
+	db   *sql.DB
+	url  string
+	mail *mailbox
+}
+
+func newTestAPI(t *testing.T) *testAPI {
+	t.Helper()
+	db := dbtest.Open(t)
+
+	mail := &mailbox{}
+	email.SetDefault(mail)
+	t.Cleanup(func() { email.SetDefault(nil) })
+
+	store := blobstore.Default()
+	blobstore.SetDefault(blobstore.NewFileStore(t.TempDir()))
+	t.Cleanup(func() { blobstore.SetDefault(store) })
+
+	server := httptest.NewServer(NewServer(db).Handler())
+	t.Cleanup(server.Close)
+	return &testAPI{t: t, db: db, url: server.URL, mail: mail}
+}
+
+// do sends a JSON request and decodes a successful response into out, if
+// given, returning the status and any error message
+func (a *testAPI) do(method, path, token string, body, out interface{}) (int, string) {
+	a.t.Helper()
+	var payload bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&payload).Encode(body); err != nil {
+			a.t.Fatal(err)
+		}
+	}
+
+	req, err := http.NewRequest(method, a.url+path, &payload)
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		var failure struct {
+			Error string `json:"error"`
+		}
+		json.NewDecoder(resp.Body).Decode(&failure)
+		return resp.StatusCode, failure.Error
+	}
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			a.t.Fatalf("%s %s: decoding response: %v", method, path, err)
+		}
+	}
+	return resp.StatusCode, ""
+}
+
+// expect fails the test unless the request returns the wanted status
+func (a *testAPI) expect(want int, method, path, token string, body, out interface{}) {
+	a.t.Helper()
+	if got, message := a.do(method, path, token, body, out); got != want {
+		a.t.Fatalf("%s %s: status %d (%q), want %d", method, path, got, message, want)
+	}
+}
+
+// seedExamCell creates the first ExamCell account the way the CLI does,
+// since the API only lets ExamCell create privileged accounts
+func (a *testAPI) seedExamCell(username string) *models.User {
+	a.t.Helper()
+	user, err := auth.RegisterUser(a.db, username, testPassword, username+"@example.com", "ExamCell")
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	return user
+}
+
+// register creates an account, as the caller holding token, and returns the user
+func (a *testAPI) register(username, role, token string) *models.User {
+	a.t.Helper()
+	var created struct {
+		User          userResponse `json:"user"`
+		RecoveryCodes []string     `json:"recovery_codes"`
+	}
+	a.expect(http.StatusCreated, "POST", "/api/register", token, map[string]string{
+		"username": username,
+		"password": testPassword,
+		"email":    username + "@example.com",
+		"role":     role,
+	}, &created)
+	if created.User.Role != role || len(created.RecoveryCodes) == 0 {
+		a.t.Fatalf("registered %+v", created)
+	}
+	return &models.User{ID: created.User.ID, Username: username, Role: role}
+}
+
+// login runs both login steps and returns the session token
+func (a *testAPI) login(username string) string {
+	a.t.Helper()
+	var started struct {
+		LoginID   string `json:"login_id"`
+		MFAMethod string `json:"mfa_method"`
+	}
+	a.expect(http.StatusOK, "POST", "/api/login", "", map[string]string{
+		"username": username,
+		"password": testPassword,
+	}, &started)
+	if started.MFAMethod != auth.MFAEmail {
+		a.t.Fatalf("mfa_method = %q, want %q", started.MFAMethod, auth.MFAEmail)
+	}
+
+	var verified struct {
+		Token string `json:"token"`
+	}
+	a.expect(http.StatusOK, "POST", "/api/login/verify", "", map[string]string{
+		"login_id": started.LoginID,
+		"otp":      a.mail.lastOTP(a.t, username+"@example.com"),
+	}, &verified)
+	if verified.Token == "" {
+		a.t.Fatal("login returned no token")
+	}
+	return verified.Token
+}
+
+func TestRegisterAndTwoStepLogin(t *testing.T) {
+	api := newTestAPI(t)
+	api.register("faculty", "Faculty", "")
+
+	api.expect(http.StatusBadRequest, "POST", "/api/register", "", map[string]string{
+		"username": "faculty", "password": testPassword, "email": "other@example.com", "role": "Faculty",
+	}, nil)
+	api.expect(http.StatusBadRequest, "POST", "/api/register", "", map[string]string{
+		"username": "weak", "password": "password", "email": "weak@example.com", "role": "Faculty",
+	}, nil)
+	api.expect(http.StatusUnauthorized, "POST", "/api/login", "", map[string]string{
+		"username": "faculty", "password": "Wrong#Horse1",
+	}, nil)
+	// Each failure makes the next attempt wait for the back-off
+	time.Sleep(auth.BackoffBase)
+
+	// A wrong code keeps the login open for the right one
+	var started struct {
+		LoginID string `json:"login_id"`
+	}
+	api.expect(http.StatusOK, "POST", "/api/login", "", map[string]string{
+		"username": "faculty", "password": testPassword,
+	}, &started)
+	otp := api.mail.lastOTP(t, "faculty@example.com")
+	wrong := "000000"
+	if otp == wrong {
+		wrong = "111111"
+	}
+	api.expect(http.StatusUnauthorized, "POST", "/api/login/verify", "", map[string]string{
+		"login_id": started.LoginID, "otp": wrong,
+	}, nil)
+	time.Sleep(2 * auth.BackoffBase)
+	var verified struct {
+		Token string       `json:"token"`
+		User  userResponse `json:"user"`
+	}
+	api.expect(http.StatusOK, "POST", "/api/login/verify", "", map[string]string{
+		"login_id": started.LoginID, "otp": otp,
+	}, &verified)
+	if verified.User.Username != "faculty" {
+		t.Fatalf("logged in as %+v", verified.User)
+	}
+	api.expect(http.StatusUnauthorized, "POST", "/api/login/verify", "", map[string]string{
+		"login_id": started.LoginID, "otp": otp,
+	}, nil)
+
+	var sessions []struct {
+		ID      int  `json:"id"`
+		Current bool `json:"current"`
+	}
+	api.expect(http.StatusOK, "GET", "/api/auth/sessions", verified.Token, nil, &sessions)
+	if len(sessions) != 1 || !sessions[0].Current {
+		t.Fatalf("sessions = %+v", sessions)
+	}
+
+	// Only ExamCell can create accounts other than Faculty
+	examCell := map[string]string{
+		"username": "examcell", "password": testPassword, "email": "examcell@example.com", "role": "examcell",
+	}
+	api.expect(http.StatusUnauthorized, "POST", "/api/register", "", examCell, nil)
+	api.expect(http.StatusForbidden, "POST", "/api/register", verified.Token, examCell, nil)
+	api.seedExamCell("admin")
+	api.register("student", "Student", api.login("admin"))
+
+	api.expect(http.StatusNoContent, "POST", "/api/logout", verified.Token, nil, nil)
+	api.expect(http.StatusUnauthorized, "GET", "/api/papers", verified.Token, nil, nil)
+	api.expect(http.StatusUnauthorized, "GET", "/api/papers", "", nil, nil)
+}
+
+func TestBurntOTPEndsLogin(t *testing.T) {
+	api := newTestAPI(t)
+	user := api.register("faculty", "Faculty", "")
+
+	var started struct {
+		LoginID string `json:"login_id"`
+	}
+	api.expect(http.StatusOK, "POST", "/api/login", "", map[string]string{
+		"username": "faculty", "password": testPassword,
+	}, &started)
+	otp := api.mail.lastOTP(t, "faculty@example.com")
+	wrong := "000000"
+	if otp == wrong {
+		wrong = "111111"
+	}
+
+	// The next wrong code is the last one the OTP allows
+	dbtest.Exec(t, api.db, `UPDATE otp_sessions SET failed_attempts = ? WHERE user_id = ?`, auth.MaxOTPFailures-1, user.ID)
+	api.expect(http.StatusUnauthorized, "POST", "/api/login/verify", "", map[string]string{
+		"login_id": started.LoginID, "otp": wrong,
+	}, nil)
+	time.Sleep(auth.BackoffBase)
+
+	// The pending login went with the OTP, so even the right code is refused
+	status, message := api.do("POST", "/api/login/verify", "", map[string]string{
+		"login_id": started.LoginID, "otp": otp,
+	}, nil)
+	if status != http.StatusUnauthorized || !strings.Contains(message, "expired") {
+		t.Fatalf("verify after a burnt OTP: %d %q", status, message)
+	}
+}
+
+func TestWrongCodeKeepsLoginExpiry(t *testing.T) {
+	server := NewServer(dbtest.Open(t))
+	loginID, err := server.startLogin(&models.User{ID: 1, Username: "faculty"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expires := server.pending[loginID].ExpiresAt
+
+	time.Sleep(10 * time.Millisecond)
+	pending, ok := server.takePendingLogin(loginID)
+	if !ok {
+		t.Fatal("pending login not found")
+	}
+	server.putBackPendingLogin(loginID, pending)
+	if got := server.pending[loginID].ExpiresAt; !got.Equal(expires) {
+		t.Fatalf("expiry moved from %v to %v", expires, got)
+	}
+}
+
+func TestPaperUploadListAndDecrypt(t *testing.T) {
+	api := newTestAPI(t)
+	api.seedExamCell("admin")
+	admin := api.login("admin")
+	api.register("examcell", "ExamCell", admin)
+	api.register("faculty", "Faculty", "")
+	api.register("student", "Student", admin)
+	examCell := api.login("examcell")
+	faculty := api.login("faculty")
+	student := api.login("student")
+
+	content := []byte("Q1. Prove that the square root of 2 is irrational.")
+	upload := map[string]string{
+		"title":     "Midterm",
+		"subject":   "Maths",
+		"exam_date": time.Now().UTC().Format("2006-01-02"),
+		"content":   crypto.EncodeBase64(content),
+	}
+	var created struct {
+		ID int `json:"id"`
+	}
+	api.expect(http.StatusCreated, "POST", "/api/papers", faculty, upload, &created)
+
+	var mine []paperResponse
+	api.expect(http.StatusOK, "GET", "/api/papers", faculty, nil, &mine)
+	if len(mine) != 1 || mine[0].ID != created.ID || mine[0].Status != "pending" {
+		t.Fatalf("faculty papers = %+v", mine)
+	}
+	var all []paperResponse
+	api.expect(http.StatusOK, "GET", "/api/papers", examCell, nil, &all)
+	if len(all) != 1 || all[0].FacultyName != "faculty" {
+		t.Fatalf("ExamCell papers = %+v", all)
+	}
+
+	decryptPath := fmt.Sprintf("/api/papers/%d/decrypt", created.ID)
+	var decrypted struct {
+		Content string `json:"content"`
+	}
+	api.expect(http.StatusOK, "POST", decryptPath, examCell, nil, &decrypted)
+	plaintext, err := crypto.DecodeBase64(decrypted.Content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(plaintext, content) {
+		t.Fatalf("decrypted %q, want %q", plaintext, content)
+	}
+
+	// The ACL refuses everyone else
+	api.expect(http.StatusForbidden, "POST", "/api/papers", examCell, upload, nil)
+	api.expect(http.StatusForbidden, "POST", decryptPath, faculty, nil, nil)
+	api.expect(http.StatusForbidden, "POST", decryptPath, student, nil, nil)
+	api.expect(http.StatusForbidden, "GET", "/api/papers", student, nil, nil)
+	api.expect(http.StatusBadRequest, "POST", "/api/papers/999/decrypt", examCell, nil, nil)
+}
+
+func TestThresholdKeyShares(t *testing.T) {
+	t.Setenv("PAPER_KEY_THRESHOLD", "2")
+	api := newTestAPI(t)
+	api.seedExamCell("examcell1")
+	api.seedExamCell("examcell2")
+	api.register("faculty", "Faculty", "")
+	first := api.login("examcell1")
+	second := api.login("examcell2")
+	faculty := api.login("faculty")
+
+	content := []byte("Q1. State Ohm's law.")
+	var paper struct {
+		ID int `json:"id"`
+	}
+	api.expect(http.StatusCreated, "POST", "/api/papers", faculty, map[string]string{
+		"title":     "Quiz",
+		"subject":   "Physics",
+		"exam_date": time.Now().UTC().Format("2006-01-02"),
+		"content":   crypto.EncodeBase64(content),
+	}, &paper)
+
+	sharesPath := fmt.Sprintf("/api/papers/%d/shares", paper.ID)
+	decryptPath := fmt.Sprintf("/api/papers/%d/decrypt", paper.ID)
+	var status struct {
+		Submitted int `json:"submitted"`
+		Threshold int `json:"threshold"`
+	}
+	api.expect(http.StatusOK, "GET", sharesPath, first, nil, &status)
+	if status.Submitted != 0 || status.Threshold != 2 {
+		t.Fatalf("share status = %+v", status)
+	}
+	api.expect(http.StatusForbidden, "GET", sharesPath, faculty, nil, nil)
+	api.expect(http.StatusForbidden, "POST", sharesPath, faculty, nil, nil)
+
+	// One share is not enough, and it cannot be submitted twice
+	api.expect(http.StatusOK, "POST", sharesPath, first, nil, &status)
+	if status.Submitted != 1 {
+		t.Fatalf("share status after one submission = %+v", status)
+	}
+	api.expect(http.StatusBadRequest, "POST", sharesPath, first, nil, nil)
+	api.expect(http.StatusBadRequest, "POST", decryptPath, first, nil, nil)
+
+	api.expect(http.StatusOK, "POST", sharesPath, second, nil, &status)
+	if status.Submitted != 2 {
+		t.Fatalf("share status after two submissions = %+v", status)
+	}
+	var decrypted struct {
+		Content string `json:"content"`
+	}
+	api.expect(http.StatusOK, "POST", decryptPath, first, nil, &decrypted)
+	plaintext, err := crypto.DecodeBase64(decrypted.Content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(plaintext, content) {
+		t.Fatalf("decrypted %q, want %q", plaintext, content)
+	}
+}
+
+func TestResubmitRejectedPaper(t *testing.T) {
+	api := newTestAPI(t)
+	reviewer := api.seedExamCell("examcell")
+	api.register("faculty", "Faculty", "")
+	faculty := api.login("faculty")
+
+	var paper struct {
+		ID int `json:"id"`
+	}
+	api.expect(http.StatusCreated, "POST", "/api/papers", faculty, map[string]string{
+		"title":     "Final",
+		"subject":   "Physics",
+		"exam_date": time.Now().AddDate(0, 0, 7).UTC().Format("2006-01-02"),
+		"content":   crypto.EncodeBase64([]byte("Q1. Define it.")),
+	}, &paper)
+	workflow := services.NewWorkflowService(api.db, reviewer)
+	if err := workflow.StartReview(paper.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := workflow.Reject(paper.ID, "define what?"); err != nil {
+		t.Fatal(err)
+	}
+
+	resubmitPath := fmt.Sprintf("/api/papers/%d/resubmit", paper.ID)
+	api.expect(http.StatusBadRequest, "POST", resubmitPath, faculty, map[string]string{"comments": "no file"}, nil)
+	var revision struct {
+		ID         int `json:"id"`
+		RevisionOf int `json:"revision_of"`
+	}
+	api.expect(http.StatusCreated, "POST", resubmitPath, faculty, map[string]string{
+		"content":  crypto.EncodeBase64([]byte("Q1. Define momentum.")),
+		"comments": "clarified question 1",
+	}, &revision)
+	if revision.ID == paper.ID || revision.RevisionOf != paper.ID {
+		t.Fatalf("revision = %+v", revision)
+	}
+
+	var papers []paperResponse
+	api.expect(http.StatusOK, "GET", "/api/papers", faculty, nil, &papers)
+	for _, p := range papers {
+		if p.ID == revision.ID && (p.Status != "pending" || p.RevisionOf != paper.ID) {
+			t.Fatalf("revision listed as %+v", p)
+		}
+	}
+}
+
+func TestExamSessions(t *testing.T) {
+	api := newTestAPI(t)
+	reviewer := api.seedExamCell("examcell")
+	api.register("faculty", "Faculty", "")
+	examCell := api.login("examcell")
+	faculty := api.login("faculty")
+
+	var paper struct {
+		ID int `json:"id"`
+	}
+	api.expect(http.StatusCreated, "POST", "/api/papers", faculty, map[string]string{
+		"title":     "Final",
+		"subject":   "Physics",
+		"exam_date": time.Now().UTC().Format("2006-01-02"),
+		"content":   crypto.EncodeBase64([]byte("Q1. Define momentum.")),
+	}, &paper)
+
+	// Review has no API route yet; sessions need an approved paper
+	workflow := services.NewWorkflowService(api.db, reviewer)
+	if err := workflow.StartReview(paper.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := workflow.Approve(paper.ID, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now().Add(4 * time.Hour).Truncate(time.Second)
+	schedule := map[string]interface{}{
+		"paper_id":         paper.ID,
+		"name":             "Afternoon",
+		"scheduled_time":   start.Format(time.RFC3339),
+		"duration_minutes": 90,
+	}
+	api.expect(http.StatusForbidden, "POST", "/api/sessions", faculty, schedule, nil)
+	var session struct {
+		ID int `json:"id"`
+	}
+	api.expect(http.StatusCreated, "POST", "/api/sessions", examCell, schedule, &session)
+
+	var sessions []sessionResponse
+	api.expect(http.StatusOK, "GET", "/api/sessions", faculty, nil, &sessions)
+	if len(sessions) != 1 || sessions[0].ID != session.ID || sessions[0].Status != "scheduled" {
+		t.Fatalf("sessions = %+v", sessions)
+	}
+
+	// The paper is now locked until shortly before the session
+	api.expect(http.StatusBadRequest, "POST", fmt.Sprintf("/api/papers/%d/decrypt", paper.ID), examCell, nil, nil)
+
+	reschedulePath := fmt.Sprintf("/api/sessions/%d/reschedule", session.ID)
+	api.expect(http.StatusConflict, "POST", reschedulePath, examCell, map[string]interface{}{
+		"scheduled_time":   start.Add(-time.Hour).Format(time.RFC3339),
+		"duration_minutes": 90,
+	}, nil)
+	api.expect(http.StatusForbidden, "POST", reschedulePath, faculty, map[string]interface{}{
+		"scheduled_time":   start.Add(time.Hour).Format(time.RFC3339),
+		"duration_minutes": 90,
+	}, nil)
+	api.expect(http.StatusNoContent, "POST", reschedulePath, examCell, map[string]interface{}{
+		"scheduled_time":   start.Add(time.Hour).Format(time.RFC3339),
+		"duration_minutes": 90,
+	}, nil)
+
+	api.expect(http.StatusForbidden, "POST", fmt.Sprintf("/api/sessions/%d/cancel", session.ID), faculty, nil, nil)
+}
@@ -0,0 +1,257 @@
+package auth
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/internal/acl"
+	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/internal/models"
+	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/pkg/email"
+)
+
+// Attempt types recorded in login_attempts
+const (
+	AttemptPassword = "password"
+	AttemptOTP      = "otp"
+	AttemptUnlock   = "unlock" // admin unlock; resets the failure count like a successful login
+)
+
+const (
+	MaxFailedAttempts = 5                // per account before a temporary lockout
+	MaxSourceFailures = 20               // per source across all accounts
+	MaxOTPFailures    = 3                // wrong codes before the outstanding OTP is invalidated
+	AttemptWindow     = 15 * time.Minute // failures older than this are forgotten
+	LockoutDuration   = 15 * time.Minute
+	BackoffBase       = time.Second
+	MaxBackoff        = 30 * time.Second
+)
+
+// checkThrottle refuses an attempt while the account is locked, the source is
+// over its limit, or the exponential back-off since the last failure has not passed
+func checkThrottle(db *sql.DB, username, source string) error {
+	now := time.Now()
+
+	var lockedUntil sql.NullTime
+	err := db.QueryRow(`SELECT locked_until FROM users WHERE username = ?`, username).Scan(&lockedUntil)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("database error: %w", err)
+	}
+	if lockedUntil.Valid && now.Before(lockedUntil.Time) {
+		return fmt.Errorf("account locked until %s after too many failed attempts",
+			lockedUntil.Time.Local().Format("15:04"))
+	}
+
+	var sourceFailures int
+	query := `SELECT COUNT(*) FROM login_attempts WHERE source = ? AND success = FALSE AND attempted_at > ?`
+	if err := db.QueryRow(query, source, now.Add(-AttemptWindow)).Scan(&sourceFailures); err != nil {
+		return fmt.Errorf("database error: %w", err)
+	}
+	if sourceFailures >= MaxSourceFailures {
+		return fmt.Errorf("too many failed attempts from this source; try again later")
+	}
+
+	failures, lastFailure, err := recentFailures(db, username, now)
+	if err != nil {
+		return err
+	}
+	if failures > 0 {
+		if wait := backoffDelay(failures) - now.Sub(lastFailure); wait > 0 {
+			return fmt.Errorf("too many attempts; wait %d seconds before trying again", int(wait.Seconds())+1)
+		}
+	}
+
+	return nil
+}
+
+// backoffDelay doubles the required wait with every consecutive failure
+func backoffDelay(failures int) time.Duration {
+	delay := BackoffBase
+	for i := 1; i < failures && delay < MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > MaxBackoff {
+		delay = MaxBackoff
+	}
+	return delay
+}
+
+// recentFailures counts an account's failures since its last completed login or unlock
+func recentFailures(db *sql.DB, username string, now time.Time) (int, time.Time, error) {
+	since := now.Add(-AttemptWindow)
+
+	// ORDER BY rather than MAX() so the column keeps its time type on every backend
+	var lastReset time.Time
+	query := `
+        SELECT attempted_at FROM login_attempts
+        WHERE username = ? AND success = TRUE AND attempt_type IN (?, ?)
+        ORDER BY attempted_at DESC
+        LIMIT 1
+    `
+	err := db.QueryRow(query, username, AttemptOTP, AttemptUnlock).Scan(&lastReset)
+	if err != nil && err != sql.ErrNoRows {
+		return 0, time.Time{}, fmt.Errorf("database error: %w", err)
+	}
+	if lastReset.After(since) {
+		since = lastReset
+	}
+
+	var count int
+	query = `SELECT COUNT(*) FROM login_attempts WHERE username = ? AND success = FALSE AND attempted_at > ?`
+	if err := db.QueryRow(query, username, since).Scan(&count); err != nil {
+		return 0, time.Time{}, fmt.Errorf("database error: %w", err)
+	}
+	if count == 0 {
+		return 0, time.Time{}, nil
+	}
+
+	var lastFailure time.Time
+	query = `
+        SELECT attempted_at FROM login_attempts
+        WHERE username = ? AND success = FALSE
+        ORDER BY attempted_at DESC
+        LIMIT 1
+    `
+	if err := db.QueryRow(query, username).Scan(&lastFailure); err != nil {
+		return 0, time.Time{}, fmt.Errorf("database error: %w", err)
+	}
+
+	return count, lastFailure, nil
+}
+
+// recordAttempt appends a row to login_attempts
+func recordAttempt(db *sql.DB, username string, userID int, source, attemptType string, success bool) {
+	var uid interface{}
+	if userID > 0 {
+		uid = userID
+	}
+
+	query := `
+        INSERT INTO login_attempts (username, user_id, source, attempt_type, success, attempted_at)
+        VALUES (?, ?, ?, ?, ?, ?)
+    `
+	if _, err := db.Exec(query, username, uid, source, attemptType, success, time.Now()); err != nil {
+		fmt.Printf("  Warning: Failed to record login attempt: %v\n", err)
+	}
+}
+
+// registerFailure records a failed attempt and locks the account once the limit
+// is reached, reporting whether the account is now locked
+func registerFailure(db *sql.DB, username string, userID int, source, attemptType string) bool {
+	recordAttempt(db, username, userID, source, attemptType, false)
+	if userID == 0 {
+		return false
+	}
+
+	now := time.Now()
+	failures, _, err := recentFailures(db, username, now)
+	if err != nil || failures < MaxFailedAttempts {
+		return false
+	}
+
+	lockedUntil := now.Add(LockoutDuration)
+	result, err := db.Exec(`UPDATE users SET locked_until = ? WHERE id = ? AND (locked_until IS NULL OR locked_until < ?)`,
+		lockedUntil, userID, now)
+	if err != nil {
+		fmt.Printf("  Warning: Failed to lock account: %v\n", err)
+		return false
+	}
+	if affected, _ := result.RowsAffected(); affected > 0 {
+		acl.LogAction(db, userID, "account_locked", "UserAccount", &userID, true,
+			fmt.Sprintf("%d failed %s attempts from %s; locked for %s", failures, attemptType, source, LockoutDuration))
+
+		var address string
+		if err := db.QueryRow(`SELECT email FROM users WHERE id = ?`, userID).Scan(&address); err == nil {
+			data := email.AccountData{Username: username, Source: source, LockedUntil: lockedUntil}
+			if err := email.Notify(address, email.TemplateAccountLocked, data); err != nil {
+				fmt.Printf("  Warning: Failed to send lockout notice: %v\n", err)
+			}
+		}
+	}
+	return true
+}
+
+// registerOTPFailure counts a wrong code against the outstanding OTP and burns it after
+// MaxOTPFailures or once the account locks, reporting whether it was invalidated
+func registerOTPFailure(db *sql.DB, user *models.User, source string) bool {
+	locked := registerFailure(db, user.Username, user.ID, source, AttemptOTP)
+
+	_, err := db.Exec(`UPDATE otp_sessions SET failed_attempts = failed_attempts + 1 WHERE user_id = ? AND is_used = FALSE`, user.ID)
+	if err != nil {
+		fmt.Printf("  Warning: Failed to record OTP failure: %v\n", err)
+		return locked
+	}
+
+	limit := MaxOTPFailures
+	if locked {
+		limit = 0
+	}
+	result, err := db.Exec(`UPDATE otp_sessions SET is_used = TRUE WHERE user_id = ? AND is_used = FALSE AND failed_attempts >= ?`,
+		user.ID, limit)
+	if err != nil {
+		fmt.Printf("  Warning: Failed to invalidate OTP: %v\n", err)
+		return locked
+	}
+	if locked {
+		return true
+	}
+	if affected, _ := result.RowsAffected(); affected > 0 {
+		acl.LogAction(db, user.ID, "otp_invalidated", "UserAccount", &user.ID, true,
+			fmt.Sprintf("%d wrong codes from %s", MaxOTPFailures, source))
+		return true
+	}
+	return false
+}
+
+// UnlockAccount clears a lockout and resets the failure count (ExamCell only)
+func UnlockAccount(db *sql.DB, admin *models.User, userID int) error {
+	if err := acl.EnforcePermission(db, admin, "UserAccount", "update", &userID); err != nil {
+		return err
+	}
+
+	var username string
+	err := db.QueryRow(`SELECT username FROM users WHERE id = ?`, userID).Scan(&username)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("user not found")
+	} else if err != nil {
+		return fmt.Errorf("failed to fetch user: %w", err)
+	}
+
+	if _, err := db.Exec(`UPDATE users SET locked_until = NULL WHERE id = ?`, userID); err != nil {
+		return fmt.Errorf("failed to unlock account: %w", err)
+	}
+	recordAttempt(db, username, userID, "admin:"+admin.Username, AttemptUnlock, true)
+
+	acl.LogAction(db, admin.ID, "account_unlocked", "UserAccount", &userID, true, "unlocked "+username)
+	return nil
+}
+
+// GetLockedAccounts lists accounts that are currently locked (ExamCell only)
+func GetLockedAccounts(db *sql.DB, admin *models.User) ([]models.LockedAccount, error) {
+	if err := acl.EnforcePermission(db, admin, "UserAccount", "read", nil); err != nil {
+		return nil, err
+	}
+
+	query := `
+        SELECT id, username, role, locked_until
+        FROM users
+        WHERE locked_until > ?
+        ORDER BY locked_until DESC
+    `
+	rows, err := db.Query(query, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get locked accounts: %w", err)
+	}
+	defer rows.Close()
+
+	var accounts []models.LockedAccount
+	for rows.Next() {
+		var account models.LockedAccount
+		if err := rows.Scan(&account.UserID, &account.Username, &account.Role, &account.LockedUntil); err != nil {
+			return nil, fmt.Errorf("failed to scan account: %w", err)
+		}
+		accounts = append(accounts, account)
+	}
+
+	return accounts, nil
+}
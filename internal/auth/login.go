@@ -2,6 +2,7 @@ package auth
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"strings"
 
@@ -10,14 +11,18 @@ import (
 	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/pkg/email"
 )
 
-// AuthenticateUser verifies username and password
-func AuthenticateUser(db *sql.DB, username, password string) (*models.User, error) {
+// AuthenticateUser verifies username and password, subject to back-off and lockout
+func AuthenticateUser(db *sql.DB, username, password, source string) (*models.User, error) {
 	username = strings.TrimSpace(username)
 
 	if username == "" || password == "" {
 		return nil, fmt.Errorf("username and password are required")
 	}
 
+	if err := checkThrottle(db, username, source); err != nil {
+		return nil, err
+	}
+
 	// Get user from database
 	var user models.User
 	query := `
@@ -36,6 +41,7 @@ func AuthenticateUser(db *sql.DB, username, password string) (*models.User, erro
 	)
 
 	if err == sql.ErrNoRows {
+		registerFailure(db, username, 0, source, AttemptPassword)
 		return nil, fmt.Errorf("invalid username or password")
 	} else if err != nil {
 		return nil, fmt.Errorf("database error: %w", err)
@@ -43,14 +49,34 @@ func AuthenticateUser(db *sql.DB, username, password string) (*models.User, erro
 
 	// Verify password
 	if !crypto.VerifyPassword(password, user.Salt, user.PasswordHash) {
+		registerFailure(db, username, user.ID, source, AttemptPassword)
 		return nil, fmt.Errorf("invalid username or password")
 	}
 
+	recordAttempt(db, username, user.ID, source, AttemptPassword, true)
 	return &user, nil
 }
 
-// InitiateMFA generates and sends OTP
+// ErrInvalidOTP is returned for a wrong or expired code the user may retry with the same login
+var ErrInvalidOTP = errors.New("invalid or expired OTP")
+
+// MFA methods
+const (
+	MFAEmail = "email"
+	MFATOTP  = "totp"
+)
+
+// InitiateMFA starts the second factor and returns the method the user must answer with.
+// Users with an authenticator app get no email; everyone else is sent an OTP.
 func InitiateMFA(db *sql.DB, user *models.User) (string, error) {
+	totpEnabled, err := TOTPEnabled(db, user.ID)
+	if err != nil {
+		return "", err
+	}
+	if totpEnabled {
+		return MFATOTP, nil
+	}
+
 	// Generate OTP
 	otp, err := GenerateOTP()
 	if err != nil {
@@ -63,28 +89,115 @@ func InitiateMFA(db *sql.DB, user *models.User) (string, error) {
 		return "", fmt.Errorf("failed to store OTP: %w", err)
 	}
 
-	// Send OTP via email (simulated)
-	err = email.SendOTP(user.Email, otp)
+	// Send OTP through the configured notifier
+	err = email.Notify(user.Email, email.TemplateOTP, email.OTPData{Code: otp, ValidityMins: OTPValidityMins})
 	if err != nil {
 		return "", fmt.Errorf("failed to send OTP: %w", err)
 	}
 
-	return otp, nil
+	return MFAEmail, nil
 }
 
-// CompleteLogin verifies OTP and completes login
-func CompleteLogin(db *sql.DB, user *models.User, otp string) error {
-	valid, err := VerifyOTP(db, user.ID, otp)
+// CompleteLogin verifies the OTP, authenticator code or a recovery code, completes login and issues a session token
+func CompleteLogin(db *sql.DB, user *models.User, otp, source string) (string, error) {
+	if err := checkThrottle(db, user.Username, source); err != nil {
+		return "", err
+	}
+
+	totpEnabled, err := TOTPEnabled(db, user.ID)
 	if err != nil {
-		return err
+		return "", err
 	}
 
-	if !valid {
-		return fmt.Errorf("invalid or expired OTP")
+	var valid bool
+	if IsRecoveryCode(otp) {
+		valid, err = UseRecoveryCode(db, user, otp)
+	} else if totpEnabled {
+		valid, err = VerifyTOTP(db, user.ID, otp)
+	} else {
+		valid, err = VerifyOTP(db, user.ID, otp)
 	}
+	if err != nil || !valid {
+		if registerOTPFailure(db, user, source) {
+			return "", fmt.Errorf("too many wrong codes; the OTP has been invalidated, please log in again")
+		}
+		return "", ErrInvalidOTP
+	}
+	recordAttempt(db, user.Username, user.ID, source, AttemptOTP, true)
 
 	// Cleanup expired OTPs
 	CleanupExpiredOTPs(db)
 
+	return CreateSession(db, user, source)
+}
+
+// UnlockPrivateKey decrypts the user's stored private key with their password.
+// The key is kept only in memory on the user object for the rest of the session.
+func UnlockPrivateKey(db *sql.DB, user *models.User, password string) error {
+	// Students don't have keys
+	if user.Role != "Faculty" && user.Role != "ExamCell" {
+		return nil
+	}
+
+	var publicKeyPEM, privateKeyPEM, keyAlgorithm sql.NullString
+	query := `SELECT public_key, private_key_encrypted, key_algorithm FROM users WHERE id = ?`
+	err := db.QueryRow(query, user.ID).Scan(&publicKeyPEM, &privateKeyPEM, &keyAlgorithm)
+	if err != nil {
+		return fmt.Errorf("failed to load private key: %w", err)
+	}
+
+	if !privateKeyPEM.Valid || privateKeyPEM.String == "" {
+		return fmt.Errorf("no key pair found for %s", user.Username)
+	}
+
+	var privateKey crypto.PrivateKey
+	if crypto.IsEncryptedPrivateKeyPEM(privateKeyPEM.String) {
+		privateKey, err = crypto.DecryptPrivateKeyFromPEM(privateKeyPEM.String, password)
+		if err != nil {
+			return err
+		}
+	} else {
+		// Legacy plaintext key: re-wrap it now that we know the password
+		rsaKey, err := crypto.DecodePrivateKeyFromPEM(privateKeyPEM.String)
+		if err != nil {
+			return fmt.Errorf("failed to decode private key: %w", err)
+		}
+		privateKey = crypto.RSAPrivateKey{PrivateKey: rsaKey}
+
+		wrappedPEM, err := crypto.EncryptPrivateKeyToPEM(privateKey, password)
+		if err != nil {
+			return fmt.Errorf("failed to encrypt private key: %w", err)
+		}
+
+		_, err = db.Exec(`UPDATE users SET private_key_encrypted = ? WHERE id = ?`, wrappedPEM, user.ID)
+		if err != nil {
+			return fmt.Errorf("failed to store encrypted private key: %w", err)
+		}
+		privateKeyPEM.String = wrappedPEM
+		fmt.Println("Stored private key upgraded to password-encrypted format")
+	}
+
+	// The stored key must be of the suite the account is recorded with, or
+	// other members would wrap keys this key pair cannot open
+	if keyAlgorithm.Valid && keyAlgorithm.String != privateKey.Algorithm() {
+		return fmt.Errorf("stored private key is %s but the account uses %s", privateKey.Algorithm(), keyAlgorithm.String)
+	}
+	if !keyAlgorithm.Valid {
+		_, err = db.Exec(`UPDATE users SET key_algorithm = ? WHERE id = ?`, privateKey.Algorithm(), user.ID)
+		if err != nil {
+			return fmt.Errorf("failed to record key algorithm: %w", err)
+		}
+		query = `UPDATE user_keys SET key_algorithm = ? WHERE user_id = ? AND status = ? AND key_algorithm IS NULL`
+		_, err = db.Exec(query, privateKey.Algorithm(), user.ID, KeyStatusActive)
+		if err != nil {
+			return fmt.Errorf("failed to record key algorithm: %w", err)
+		}
+	}
+
+	user.PublicKey = publicKeyPEM.String
+	user.PrivateKeyEncrypted = privateKeyPEM.String
+	user.KeyAlgorithm = privateKey.Algorithm()
+	user.PrivateKey = privateKey
+
 	return nil
 }
@@ -1,10 +1,15 @@
 package auth
 
 import (
+	"crypto/hmac"
 	"crypto/rand"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
 	"fmt"
 	"math/big"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/internal/models"
@@ -28,34 +33,61 @@ func GenerateOTP() (string, error) {
 	return otp, nil
 }
 
-// StoreOTP saves OTP to database
+// hashOTP keys the code with a secret derived from the server secret, so a
+// database dump does not reveal live codes and 6 digits cannot be brute-forced offline
+func hashOTP(userID int, otp string) string {
+	keyMAC := hmac.New(sha256.New, ServerSecret())
+	keyMAC.Write([]byte("otp"))
+
+	mac := hmac.New(sha256.New, keyMAC.Sum(nil))
+	mac.Write([]byte(strconv.Itoa(userID) + ":" + otp))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// StoreOTP saves the OTP hash and invalidates any older unused codes,
+// so each user has at most one active OTP
 func StoreOTP(db *sql.DB, userID int, otp string) error {
 	expiresAt := time.Now().Add(OTPValidityMins * time.Minute)
 
-	query := `INSERT INTO otp_sessions (user_id, otp_code, expires_at) VALUES (?, ?, ?)`
-	_, err := db.Exec(query, userID, otp, expiresAt)
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`UPDATE otp_sessions SET is_used = TRUE WHERE user_id = ? AND is_used = FALSE`, userID)
+	if err != nil {
+		return fmt.Errorf("failed to invalidate old OTPs: %w", err)
+	}
+
+	query := `INSERT INTO otp_sessions (user_id, otp_hash, expires_at) VALUES (?, ?, ?)`
+	_, err = tx.Exec(query, userID, hashOTP(userID, otp), expiresAt)
 	if err != nil {
 		return fmt.Errorf("failed to store OTP: %w", err)
 	}
 
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit OTP: %w", err)
+	}
+
 	return nil
 }
 
-// VerifyOTP checks if OTP is valid
+// VerifyOTP checks the user's active OTP with a constant-time comparison
 func VerifyOTP(db *sql.DB, userID int, otp string) (bool, error) {
 	var session models.OTPSession
 
 	query := `
-        SELECT id, otp_code, expires_at, is_used 
+        SELECT id, otp_hash, expires_at, is_used 
         FROM otp_sessions 
-        WHERE user_id = ? AND otp_code = ? AND is_used = FALSE
-        ORDER BY created_at DESC 
+        WHERE user_id = ? AND is_used = FALSE
+        ORDER BY created_at DESC, id DESC 
         LIMIT 1
     `
 
-	err := db.QueryRow(query, userID, otp).Scan(
+	err := db.QueryRow(query, userID).Scan(
 		&session.ID,
-		&session.OTPCode,
+		&session.OTPHash,
 		&session.ExpiresAt,
 		&session.IsUsed,
 	)
@@ -66,24 +98,31 @@ func VerifyOTP(db *sql.DB, userID int, otp string) (bool, error) {
 		return false, fmt.Errorf("failed to verify OTP: %w", err)
 	}
 
+	if !hmac.Equal([]byte(hashOTP(userID, strings.TrimSpace(otp))), []byte(session.OTPHash)) {
+		return false, fmt.Errorf("invalid OTP")
+	}
+
 	// Check if expired
 	if time.Now().After(session.ExpiresAt) {
 		return false, fmt.Errorf("OTP expired")
 	}
 
-	// Mark as used
-	updateQuery := `UPDATE otp_sessions SET is_used = TRUE WHERE id = ?`
-	_, err = db.Exec(updateQuery, session.ID)
+	// Mark as used; the guard makes a concurrent second use fail
+	updateQuery := `UPDATE otp_sessions SET is_used = TRUE WHERE id = ? AND is_used = FALSE`
+	result, err := db.Exec(updateQuery, session.ID)
 	if err != nil {
 		return false, fmt.Errorf("failed to mark OTP as used: %w", err)
 	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return false, fmt.Errorf("invalid OTP")
+	}
 
 	return true, nil
 }
 
 // CleanupExpiredOTPs removes old OTP sessions
 func CleanupExpiredOTPs(db *sql.DB) error {
-	query := `DELETE FROM otp_sessions WHERE expires_at < NOW() OR is_used = TRUE`
-	_, err := db.Exec(query)
+	query := `DELETE FROM otp_sessions WHERE expires_at < ? OR is_used = TRUE`
+	_, err := db.Exec(query, time.Now())
 	return err
 }
@@ -0,0 +1,160 @@
+package auth
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/base32"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/internal/acl"
+	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/internal/crypto"
+	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/internal/models"
+	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/pkg/email"
+)
+
+const (
+	RecoveryCodeCount        = 10
+	RecoveryCodeLowThreshold = 3
+	recoveryCodeBytes        = 5 // 8 base32 characters
+)
+
+var recoveryCodePattern = regexp.MustCompile(`^[a-z2-7]{4}-[a-z2-7]{4}$`)
+
+// normalizeRecoveryCode lowercases a code and restores the dash users tend to drop
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), " ", ""))
+	if len(code) == 8 && !strings.Contains(code, "-") {
+		code = code[:4] + "-" + code[4:]
+	}
+	return code
+}
+
+// IsRecoveryCode reports whether input has the shape of a recovery code rather than an OTP
+func IsRecoveryCode(code string) bool {
+	return recoveryCodePattern.MatchString(normalizeRecoveryCode(code))
+}
+
+// GenerateRecoveryCodes replaces a user's recovery codes with a fresh set.
+// The plaintext codes are returned once and only their hashes are stored.
+func GenerateRecoveryCodes(db *sql.DB, userID int) ([]string, error) {
+	encoding := base32.StdEncoding.WithPadding(base32.NoPadding)
+
+	codes := make([]string, 0, RecoveryCodeCount)
+	hashes := make([][2]string, 0, RecoveryCodeCount)
+	for i := 0; i < RecoveryCodeCount; i++ {
+		raw := make([]byte, recoveryCodeBytes)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, fmt.Errorf("failed to generate recovery code: %w", err)
+		}
+		encoded := strings.ToLower(encoding.EncodeToString(raw))
+		code := encoded[:4] + "-" + encoded[4:]
+
+		salt, err := crypto.GenerateSalt()
+		if err != nil {
+			return nil, err
+		}
+		hash, err := crypto.HashPassword(code, salt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to hash recovery code: %w", err)
+		}
+
+		codes = append(codes, code)
+		hashes = append(hashes, [2]string{hash, salt})
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM recovery_codes WHERE user_id = ?`, userID); err != nil {
+		return nil, fmt.Errorf("failed to remove old recovery codes: %w", err)
+	}
+
+	query := `INSERT INTO recovery_codes (user_id, code_hash, salt) VALUES (?, ?, ?)`
+	for _, h := range hashes {
+		if _, err := tx.Exec(query, userID, h[0], h[1]); err != nil {
+			return nil, fmt.Errorf("failed to store recovery code: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit recovery codes: %w", err)
+	}
+
+	acl.LogAction(db, userID, "recovery_codes_generated", "User", &userID, true,
+		fmt.Sprintf("%d recovery codes issued", RecoveryCodeCount))
+
+	return codes, nil
+}
+
+// RemainingRecoveryCodes counts a user's unused recovery codes
+func RemainingRecoveryCodes(db *sql.DB, userID int) (int, error) {
+	var count int
+	query := `SELECT COUNT(*) FROM recovery_codes WHERE user_id = ? AND used_at IS NULL`
+	if err := db.QueryRow(query, userID).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count recovery codes: %w", err)
+	}
+	return count, nil
+}
+
+// UseRecoveryCode accepts an unused recovery code in place of an OTP and burns it
+func UseRecoveryCode(db *sql.DB, user *models.User, code string) (bool, error) {
+	code = normalizeRecoveryCode(code)
+
+	rows, err := db.Query(`SELECT id, code_hash, salt FROM recovery_codes WHERE user_id = ? AND used_at IS NULL`, user.ID)
+	if err != nil {
+		return false, fmt.Errorf("failed to load recovery codes: %w", err)
+	}
+
+	matchID := 0
+	for rows.Next() {
+		var id int
+		var hash, salt string
+		if err := rows.Scan(&id, &hash, &salt); err != nil {
+			rows.Close()
+			return false, fmt.Errorf("failed to scan recovery code: %w", err)
+		}
+		if crypto.VerifyPassword(code, salt, hash) {
+			matchID = id
+			break
+		}
+	}
+	rows.Close()
+
+	if matchID == 0 {
+		acl.LogAction(db, user.ID, "recovery_code_used", "User", &user.ID, false, "invalid or already used recovery code")
+		return false, fmt.Errorf("invalid or already used recovery code")
+	}
+
+	// The used_at guard stops two logins burning the same code concurrently
+	result, err := db.Exec(`UPDATE recovery_codes SET used_at = ? WHERE id = ? AND used_at IS NULL`, time.Now(), matchID)
+	if err != nil {
+		return false, fmt.Errorf("failed to mark recovery code as used: %w", err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		acl.LogAction(db, user.ID, "recovery_code_used", "User", &user.ID, false, "recovery code already used")
+		return false, fmt.Errorf("invalid or already used recovery code")
+	}
+
+	remaining, err := RemainingRecoveryCodes(db, user.ID)
+	if err != nil {
+		return false, err
+	}
+
+	acl.LogAction(db, user.ID, "recovery_code_used", "User", &user.ID, true,
+		fmt.Sprintf("%d recovery codes remaining", remaining))
+
+	if remaining <= RecoveryCodeLowThreshold {
+		data := email.AccountData{Username: user.Username, Remaining: remaining}
+		if err := email.Notify(user.Email, email.TemplateRecoveryCodesLow, data); err != nil {
+			fmt.Printf("Warning: Failed to send recovery code alert: %v\n", err)
+		}
+	}
+
+	return true, nil
+}
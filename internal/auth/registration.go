@@ -3,6 +3,7 @@ package auth
 import (
 	"database/sql"
 	"fmt"
+	"os"
 	"regexp"
 	"strings"
 
@@ -144,9 +145,9 @@ func RegisterUser(db *sql.DB, username, password, email, role string) (*models.U
 		Email:        email,
 	}
 
-	// Generate RSA keys for Faculty and ExamCell ONLY
+	// Generate keys for Faculty and ExamCell ONLY
 	if role == "Faculty" || role == "ExamCell" {
-		err := GenerateUserKeys(db, user)
+		err := GenerateUserKeys(db, user, password)
 		if err != nil {
 			fmt.Printf(" Warning: Failed to generate keys: %v\n", err)
 		}
@@ -155,36 +156,62 @@ func RegisterUser(db *sql.DB, username, password, email, role string) (*models.U
 	return user, nil
 }
 
-// GenerateUserKeys generates RSA keys for Faculty and ExamCell users
-func GenerateUserKeys(db *sql.DB, user *models.User) error {
+// GenerateUserKeys generates a key pair for Faculty and ExamCell users, of the
+// suite named by KEY_ALGORITHM (x25519-ed25519 by default).
+// The private key is encrypted with a key derived from the user's password.
+func GenerateUserKeys(db *sql.DB, user *models.User, password string) error {
 	// Only generate keys for Faculty and ExamCell
 	if user.Role != "Faculty" && user.Role != "ExamCell" {
 		return nil // Students don't need keys
 	}
 
-	fmt.Println("Generating RSA key pair (2048-bit)...")
+	algorithm, err := crypto.ParseKeyAlgorithm(os.Getenv("KEY_ALGORITHM"))
+	if err != nil {
+		return err
+	}
 
-	// Generate RSA key pair
-	privateKey, publicKey, err := crypto.GenerateRSAKeyPair()
+	fmt.Printf("Generating %s key pair...\n", algorithm)
+
+	privateKey, err := crypto.GenerateKeyPair(algorithm)
 	if err != nil {
 		return fmt.Errorf("failed to generate key pair: %w", err)
 	}
 
-	// Convert to PEM format
-	privateKeyPEM := crypto.EncodePrivateKeyToPEM(privateKey)
-	publicKeyPEM, err := crypto.EncodePublicKeyToPEM(publicKey)
+	// Convert to PEM format, wrapping the private key with the password
+	privateKeyPEM, err := crypto.EncryptPrivateKeyToPEM(privateKey, password)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt private key: %w", err)
+	}
+	publicKeyPEM, err := crypto.EncodePublicKey(privateKey.Public())
 	if err != nil {
 		return fmt.Errorf("failed to encode public key: %w", err)
 	}
 
-	// Store keys in database
-	query := `UPDATE users SET public_key = ?, private_key_encrypted = ? WHERE id = ?`
-	_, err = db.Exec(query, publicKeyPEM, privateKeyPEM, user.ID)
+	// Store keys in database, recording the public key in the key history
+	tx, err := db.Begin()
 	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE users SET public_key = ?, private_key_encrypted = ?, key_algorithm = ? WHERE id = ?`
+	_, err = tx.Exec(query, publicKeyPEM, privateKeyPEM, algorithm, user.ID)
+	if err != nil {
+		return fmt.Errorf("failed to store keys: %w", err)
+	}
+	if _, err := RecordActiveKey(tx, user.ID, publicKeyPEM, algorithm); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
 		return fmt.Errorf("failed to store keys: %w", err)
 	}
 
-	fmt.Println("RSA keys generated and stored securely")
+	user.PublicKey = publicKeyPEM
+	user.PrivateKeyEncrypted = privateKeyPEM
+	user.KeyAlgorithm = algorithm
+
+	fmt.Printf("%s keys generated; private key encrypted with your password\n", algorithm)
 
 	return nil
 }
@@ -0,0 +1,283 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/internal/models"
+)
+
+const (
+	SessionTTL         = 8 * time.Hour
+	SessionIdleTimeout = 30 * time.Minute
+	sessionIDBytes     = 32
+)
+
+var (
+	serverSecret     []byte
+	serverSecretOnce sync.Once
+)
+
+// ServerSecret returns the HMAC key used to sign tokens, read from SESSION_SECRET.
+// Without it a random per-process secret is used, so tokens do not survive restarts.
+func ServerSecret() []byte {
+	serverSecretOnce.Do(func() {
+		if secret := os.Getenv("SESSION_SECRET"); secret != "" {
+			serverSecret = []byte(secret)
+			return
+		}
+
+		serverSecret = make([]byte, 32)
+		if _, err := rand.Read(serverSecret); err != nil {
+			log.Fatalf("failed to generate server secret: %v", err)
+		}
+		log.Println("Warning: SESSION_SECRET not set; using a temporary secret")
+	})
+	return serverSecret
+}
+
+// signToken computes the token signature over its payload
+func signToken(payload string) string {
+	mac := hmac.New(sha256.New, ServerSecret())
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// hashToken is what gets stored, so a database dump does not reveal live tokens
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateSession issues a signed, expiring session token for a fully authenticated user.
+// Token format: <random id>.<expiry unix>.<HMAC-SHA256 signature>
+func CreateSession(db *sql.DB, user *models.User, source string) (string, error) {
+	idBytes := make([]byte, sessionIDBytes)
+	if _, err := rand.Read(idBytes); err != nil {
+		return "", fmt.Errorf("failed to generate session ID: %w", err)
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(SessionTTL)
+	payload := hex.EncodeToString(idBytes) + "." + strconv.FormatInt(expiresAt.Unix(), 10)
+	token := payload + "." + signToken(payload)
+
+	query := `
+        INSERT INTO user_sessions (user_id, token_hash, source, created_at, expires_at, last_seen_at)
+        VALUES (?, ?, ?, ?, ?, ?)
+    `
+	_, err := db.Exec(query, user.ID, hashToken(token), source, now, expiresAt, now)
+	if err != nil {
+		return "", fmt.Errorf("failed to store session: %w", err)
+	}
+
+	return token, nil
+}
+
+// parseToken checks the token signature and embedded expiry
+func parseToken(token string) error {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return fmt.Errorf("malformed session token")
+	}
+
+	payload := parts[0] + "." + parts[1]
+	if !hmac.Equal([]byte(signToken(payload)), []byte(parts[2])) {
+		return fmt.Errorf("invalid session token signature")
+	}
+
+	expiry, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return fmt.Errorf("malformed session token")
+	}
+	if time.Now().Unix() >= expiry {
+		return fmt.Errorf("session expired")
+	}
+
+	return nil
+}
+
+// ValidateSession resolves a token to its user, enforcing expiry, revocation, idle timeout
+// and account lockout
+func ValidateSession(db *sql.DB, token string) (*models.User, *models.UserSession, error) {
+	if err := parseToken(token); err != nil {
+		return nil, nil, err
+	}
+
+	var session models.UserSession
+	var revokedAt sql.NullTime
+	query := `
+        SELECT id, user_id, source, created_at, expires_at, last_seen_at, revoked_at
+        FROM user_sessions
+        WHERE token_hash = ?
+    `
+	err := db.QueryRow(query, hashToken(token)).Scan(
+		&session.ID,
+		&session.UserID,
+		&session.Source,
+		&session.CreatedAt,
+		&session.ExpiresAt,
+		&session.LastSeenAt,
+		&revokedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil, fmt.Errorf("unknown session")
+	} else if err != nil {
+		return nil, nil, fmt.Errorf("failed to load session: %w", err)
+	}
+
+	now := time.Now()
+	if revokedAt.Valid {
+		return nil, nil, fmt.Errorf("session has been revoked")
+	}
+	if !now.Before(session.ExpiresAt) {
+		return nil, nil, fmt.Errorf("session expired")
+	}
+	if now.Sub(session.LastSeenAt) > SessionIdleTimeout {
+		revokeSessionByID(db, session.ID, now)
+		return nil, nil, fmt.Errorf("session timed out after %s of inactivity", SessionIdleTimeout)
+	}
+
+	_, err = db.Exec(`UPDATE user_sessions SET last_seen_at = ? WHERE id = ?`, now, session.ID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to update session: %w", err)
+	}
+	session.LastSeenAt = now
+
+	var user models.User
+	var lockedUntil sql.NullTime
+	query = `SELECT id, username, role, email, locked_until FROM users WHERE id = ?`
+	err = db.QueryRow(query, session.UserID).Scan(&user.ID, &user.Username, &user.Role, &user.Email, &lockedUntil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load session user: %w", err)
+	}
+	// A lockout also shuts out sessions opened before it
+	if lockedUntil.Valid && now.Before(lockedUntil.Time) {
+		return nil, nil, fmt.Errorf("account locked until %s", lockedUntil.Time.Local().Format("15:04"))
+	}
+
+	return &user, &session, nil
+}
+
+// RefreshSession replaces a valid token with a new one carrying a fresh expiry
+func RefreshSession(db *sql.DB, token string) (string, error) {
+	user, session, err := ValidateSession(db, token)
+	if err != nil {
+		return "", err
+	}
+
+	newToken, err := CreateSession(db, user, session.Source)
+	if err != nil {
+		return "", err
+	}
+
+	revokeSessionByID(db, session.ID, time.Now())
+	return newToken, nil
+}
+
+// RevokeSession ends the session a token belongs to (logout)
+func RevokeSession(db *sql.DB, token string) error {
+	query := `UPDATE user_sessions SET revoked_at = ? WHERE token_hash = ? AND revoked_at IS NULL`
+	_, err := db.Exec(query, time.Now(), hashToken(token))
+	if err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+	return nil
+}
+
+// RevokeUserSession ends one of a user's own sessions by ID
+func RevokeUserSession(db *sql.DB, userID, sessionID int) error {
+	query := `UPDATE user_sessions SET revoked_at = ? WHERE id = ? AND user_id = ? AND revoked_at IS NULL`
+	result, err := db.Exec(query, time.Now(), sessionID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return fmt.Errorf("session not found")
+	}
+	return nil
+}
+
+// ListSessions returns a user's sessions that are still usable
+func ListSessions(db *sql.DB, userID int) ([]models.UserSession, error) {
+	now := time.Now()
+	query := `
+        SELECT id, user_id, source, created_at, expires_at, last_seen_at
+        FROM user_sessions
+        WHERE user_id = ? AND revoked_at IS NULL AND expires_at > ? AND last_seen_at > ?
+        ORDER BY last_seen_at DESC
+    `
+
+	rows, err := db.Query(query, userID, now, now.Add(-SessionIdleTimeout))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	defer rows.Close()
+
+	var sessions []models.UserSession
+	for rows.Next() {
+		var session models.UserSession
+		err := rows.Scan(
+			&session.ID,
+			&session.UserID,
+			&session.Source,
+			&session.CreatedAt,
+			&session.ExpiresAt,
+			&session.LastSeenAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan session: %w", err)
+		}
+		sessions = append(sessions, session)
+	}
+
+	return sessions, nil
+}
+
+// SessionIDForToken returns the database ID of a token's session
+func SessionIDForToken(db *sql.DB, token string) (int, error) {
+	var id int
+	err := db.QueryRow(`SELECT id FROM user_sessions WHERE token_hash = ?`, hashToken(token)).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("failed to find session: %w", err)
+	}
+	return id, nil
+}
+
+func revokeSessionByID(db *sql.DB, sessionID int, now time.Time) {
+	db.Exec(`UPDATE user_sessions SET revoked_at = ? WHERE id = ? AND revoked_at IS NULL`, now, sessionID)
+}
+
+// ActiveSessionIDs returns the IDs of every session that is still usable
+func ActiveSessionIDs(db *sql.DB) (map[int]bool, error) {
+	now := time.Now()
+	query := `SELECT id FROM user_sessions WHERE revoked_at IS NULL AND expires_at > ? AND last_seen_at > ?`
+
+	rows, err := db.Query(query, now, now.Add(-SessionIdleTimeout))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	defer rows.Close()
+
+	active := make(map[int]bool)
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan session: %w", err)
+		}
+		active[id] = true
+	}
+
+	return active, nil
+}
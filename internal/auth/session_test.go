@@ -0,0 +1,35 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/internal/database/dbtest"
+)
+
+func TestLockedAccountSessionsAreRejected(t *testing.T) {
+	db := dbtest.Open(t)
+	user, err := RegisterUser(db, "student", "Correct#Horse1", "student@example.com", "Student")
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := CreateSession(db, user, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := ValidateSession(db, token); err != nil {
+		t.Fatalf("fresh session: %v", err)
+	}
+
+	dbtest.Exec(t, db, `UPDATE users SET locked_until = ? WHERE id = ?`, time.Now().Add(LockoutDuration), user.ID)
+	if _, _, err := ValidateSession(db, token); err == nil || !strings.Contains(err.Error(), "locked") {
+		t.Fatalf("session of a locked account: %v, want a lockout error", err)
+	}
+
+	// An expired lock no longer gets in the way
+	dbtest.Exec(t, db, `UPDATE users SET locked_until = ? WHERE id = ?`, time.Now().Add(-time.Minute), user.ID)
+	if _, _, err := ValidateSession(db, token); err != nil {
+		t.Fatalf("session after the lock expired: %v", err)
+	}
+}
@@ -0,0 +1,225 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"database/sql"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/internal/models"
+)
+
+const (
+	TOTPIssuer           = "SecurePortal"
+	TOTPPeriod           = 30 // seconds
+	TOTPDigits           = 6
+	TOTPSecretBytes      = 20
+	DefaultTOTPSkewSteps = 1
+	MaxTOTPSkewSteps     = 10
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateTOTPSecret creates a random base32 secret for an authenticator app
+func GenerateTOTPSecret() (string, error) {
+	secret := make([]byte, TOTPSecretBytes)
+	if _, err := rand.Read(secret); err != nil {
+		return "", fmt.Errorf("failed to generate TOTP secret: %w", err)
+	}
+	return totpEncoding.EncodeToString(secret), nil
+}
+
+// TOTPURI builds the otpauth:// URI that authenticator apps import
+func TOTPURI(username, secret string) string {
+	label := url.PathEscape(TOTPIssuer + ":" + username)
+	params := url.Values{}
+	params.Set("secret", secret)
+	params.Set("issuer", TOTPIssuer)
+	params.Set("algorithm", "SHA1")
+	params.Set("digits", strconv.Itoa(TOTPDigits))
+	params.Set("period", strconv.Itoa(TOTPPeriod))
+	return "otpauth://totp/" + label + "?" + params.Encode()
+}
+
+// totpCode computes the RFC 6238 code for one time step (HOTP with SHA-1)
+func totpCode(secret string, step int64) (string, error) {
+	key, err := totpEncoding.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return "", fmt.Errorf("invalid TOTP secret: %w", err)
+	}
+
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(step))
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	mod := uint32(1)
+	for i := 0; i < TOTPDigits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", TOTPDigits, value%mod), nil
+}
+
+// totpSkewSteps is how many 30-second steps either side of now are accepted (TOTP_SKEW_STEPS)
+func totpSkewSteps() int64 {
+	if value := os.Getenv("TOTP_SKEW_STEPS"); value != "" {
+		if steps, err := strconv.Atoi(value); err == nil && steps >= 0 && steps <= MaxTOTPSkewSteps {
+			return int64(steps)
+		}
+	}
+	return DefaultTOTPSkewSteps
+}
+
+// matchTOTP returns the time step a code belongs to, looking only at steps after lastStep
+func matchTOTP(secret, code string, now time.Time, lastStep int64) (int64, bool) {
+	code = strings.TrimSpace(code)
+	if len(code) != TOTPDigits {
+		return 0, false
+	}
+
+	current := now.Unix() / TOTPPeriod
+	skew := totpSkewSteps()
+	for step := current - skew; step <= current+skew; step++ {
+		if step <= lastStep {
+			continue
+		}
+		expected, err := totpCode(secret, step)
+		if err != nil {
+			return 0, false
+		}
+		if hmac.Equal([]byte(expected), []byte(code)) {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// TOTPEnabled reports whether a user logs in with an authenticator app
+func TOTPEnabled(db *sql.DB, userID int) (bool, error) {
+	var enabled bool
+	err := db.QueryRow(`SELECT totp_enabled FROM users WHERE id = ?`, userID).Scan(&enabled)
+	if err != nil {
+		return false, fmt.Errorf("failed to check TOTP status: %w", err)
+	}
+	return enabled, nil
+}
+
+// BeginTOTPEnrollment stores a new, not yet active secret and returns it with its otpauth:// URI
+func BeginTOTPEnrollment(db *sql.DB, user *models.User) (string, string, error) {
+	enabled, err := TOTPEnabled(db, user.ID)
+	if err != nil {
+		return "", "", err
+	}
+	if enabled {
+		return "", "", fmt.Errorf("authenticator app is already enabled")
+	}
+
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		return "", "", err
+	}
+
+	query := `UPDATE users SET totp_secret = ?, totp_enabled = FALSE, totp_last_step = NULL WHERE id = ?`
+	if _, err := db.Exec(query, secret, user.ID); err != nil {
+		return "", "", fmt.Errorf("failed to store TOTP secret: %w", err)
+	}
+
+	return secret, TOTPURI(user.Username, secret), nil
+}
+
+// ConfirmTOTPEnrollment activates TOTP once the user proves their app produces valid codes
+func ConfirmTOTPEnrollment(db *sql.DB, user *models.User, code string) error {
+	var secret sql.NullString
+	var enabled bool
+	err := db.QueryRow(`SELECT totp_secret, totp_enabled FROM users WHERE id = ?`, user.ID).Scan(&secret, &enabled)
+	if err != nil {
+		return fmt.Errorf("failed to load TOTP secret: %w", err)
+	}
+	if enabled {
+		return fmt.Errorf("authenticator app is already enabled")
+	}
+	if !secret.Valid || secret.String == "" {
+		return fmt.Errorf("no TOTP enrolment in progress")
+	}
+
+	step, ok := matchTOTP(secret.String, code, time.Now(), -1)
+	if !ok {
+		return fmt.Errorf("invalid authenticator code")
+	}
+
+	query := `UPDATE users SET totp_enabled = TRUE, totp_last_step = ? WHERE id = ? AND totp_enabled = FALSE`
+	if _, err := db.Exec(query, step, user.ID); err != nil {
+		return fmt.Errorf("failed to enable TOTP: %w", err)
+	}
+
+	return nil
+}
+
+// DisableTOTP switches the user back to emailed OTPs after checking a current code
+func DisableTOTP(db *sql.DB, user *models.User, code string) error {
+	valid, err := VerifyTOTP(db, user.ID, code)
+	if err != nil {
+		return err
+	}
+	if !valid {
+		return fmt.Errorf("invalid authenticator code")
+	}
+
+	query := `UPDATE users SET totp_secret = NULL, totp_enabled = FALSE, totp_last_step = NULL WHERE id = ?`
+	if _, err := db.Exec(query, user.ID); err != nil {
+		return fmt.Errorf("failed to disable TOTP: %w", err)
+	}
+
+	return nil
+}
+
+// VerifyTOTP checks an authenticator code and rejects codes that were already used
+func VerifyTOTP(db *sql.DB, userID int, code string) (bool, error) {
+	var secret sql.NullString
+	var enabled bool
+	var lastStep sql.NullInt64
+
+	query := `SELECT totp_secret, totp_enabled, totp_last_step FROM users WHERE id = ?`
+	err := db.QueryRow(query, userID).Scan(&secret, &enabled, &lastStep)
+	if err != nil {
+		return false, fmt.Errorf("failed to load TOTP secret: %w", err)
+	}
+	if !enabled || !secret.Valid {
+		return false, fmt.Errorf("authenticator app is not enabled")
+	}
+
+	last := int64(-1)
+	if lastStep.Valid {
+		last = lastStep.Int64
+	}
+
+	step, ok := matchTOTP(secret.String, code, time.Now(), last)
+	if !ok {
+		return false, fmt.Errorf("invalid or already used authenticator code")
+	}
+
+	// Advancing the last step atomically stops a concurrent login replaying the same code
+	update := `UPDATE users SET totp_last_step = ? WHERE id = ? AND (totp_last_step IS NULL OR totp_last_step < ?)`
+	result, err := db.Exec(update, step, userID, step)
+	if err != nil {
+		return false, fmt.Errorf("failed to record TOTP use: %w", err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return false, fmt.Errorf("invalid or already used authenticator code")
+	}
+
+	return true, nil
+}
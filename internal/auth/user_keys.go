@@ -0,0 +1,37 @@
+package auth
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Key pair statuses in user_keys
+const (
+	KeyStatusActive  = "active"  // matches users.public_key
+	KeyStatusRetired = "retired" // rotated out; kept to verify old signatures
+)
+
+// RecordActiveKey retires the user's current key pair in the key history and
+// records publicKeyPEM as the active one, returning its user_keys ID.
+// users.public_key must be updated in the same transaction.
+func RecordActiveKey(tx *sql.Tx, userID int, publicKeyPEM, algorithm string) (int64, error) {
+	now := time.Now()
+
+	query := `UPDATE user_keys SET status = ?, retired_at = ? WHERE user_id = ? AND status = ?`
+	if _, err := tx.Exec(query, KeyStatusRetired, now, userID, KeyStatusActive); err != nil {
+		return 0, fmt.Errorf("failed to retire previous key: %w", err)
+	}
+
+	query = `INSERT INTO user_keys (user_id, public_key, key_algorithm, status, created_at) VALUES (?, ?, ?, ?, ?)`
+	result, err := tx.Exec(query, userID, publicKeyPEM, algorithm, KeyStatusActive, now)
+	if err != nil {
+		return 0, fmt.Errorf("failed to record key: %w", err)
+	}
+
+	keyID, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get key ID: %w", err)
+	}
+	return keyID, nil
+}
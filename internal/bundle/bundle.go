@@ -0,0 +1,290 @@
+package bundle
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/internal/crypto"
+)
+
+// A bundle is a tar archive holding manifest.json followed by paper.enc, the
+// paper's ciphertext exactly as stored. The manifest carries the paper's AES
+// key wrapped for one recipient public key, the faculty signature and the
+// signer's public key, so a printing centre can verify and decrypt the paper
+// with nothing but the bundle and its own private key. Version 1 bundles
+// predate signature schemes and are read as SignatureSchemeContent.
+const (
+	Format         = "qp-bundle-v2"
+	formatV1       = "qp-bundle-v1"
+	manifestName   = "manifest.json"
+	ciphertextName = "paper.enc"
+	maxManifest    = 1 << 20
+)
+
+// Manifest describes a bundle's contents
+type Manifest struct {
+	Format     string     `json:"format"`
+	Paper      Paper      `json:"paper"`
+	ExportedAt time.Time  `json:"exported_at"`
+	ExportedBy string     `json:"exported_by"`
+	Ciphertext Ciphertext `json:"ciphertext"`
+	Recipient  Recipient  `json:"recipient"`
+	Signature  Signature  `json:"signature"`
+}
+
+// Paper is the paper's metadata at export time. Open checks it against the
+// signature unless the paper predates signed metadata.
+type Paper struct {
+	ID         int       `json:"id"`
+	Title      string    `json:"title"`
+	Subject    string    `json:"subject"`
+	ExamDate   string    `json:"exam_date"`
+	FacultyID  int       `json:"faculty_id"`
+	Faculty    string    `json:"faculty"`
+	UploadDate time.Time `json:"upload_date"`
+	Status     string    `json:"status"`
+}
+
+// Ciphertext describes paper.enc
+type Ciphertext struct {
+	Format string `json:"format"` // crypto.ContentFormatStream or crypto.ContentFormatGCM
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"` // hex
+}
+
+// Recipient is the paper's AES key wrapped for the bundle's recipient
+type Recipient struct {
+	Fingerprint string `json:"fingerprint"`
+	KeyWrap     string `json:"key_wrap"`
+	WrappedKey  string `json:"wrapped_key"` // base64
+}
+
+// Signature is the faculty signature over the paper's envelope, or over the
+// SHA-256 of the plaintext alone for papers that predate it
+type Signature struct {
+	Scheme            string `json:"scheme"` // crypto.SignatureSchemeEnvelope or crypto.SignatureSchemeContent
+	Algorithm         string `json:"algorithm"`
+	Value             string `json:"value"`             // base64
+	SignerPublicKey   string `json:"signer_public_key"` // PEM
+	SignerFingerprint string `json:"signer_fingerprint"`
+}
+
+// Write writes a bundle. ciphertext must hold exactly manifest.Ciphertext.Size bytes.
+func Write(w io.Writer, manifest *Manifest, ciphertext io.Reader) error {
+	manifest.Format = Format
+	manifestJSON, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode manifest: %w", err)
+	}
+
+	tw := tar.NewWriter(w)
+	header := &tar.Header{
+		Name:    manifestName,
+		Mode:    0644,
+		Size:    int64(len(manifestJSON)),
+		ModTime: manifest.ExportedAt,
+	}
+	if err := tw.WriteHeader(header); err != nil {
+		return fmt.Errorf("failed to write bundle: %w", err)
+	}
+	if _, err := tw.Write(manifestJSON); err != nil {
+		return fmt.Errorf("failed to write bundle: %w", err)
+	}
+
+	header = &tar.Header{
+		Name:    ciphertextName,
+		Mode:    0644,
+		Size:    manifest.Ciphertext.Size,
+		ModTime: manifest.ExportedAt,
+	}
+	if err := tw.WriteHeader(header); err != nil {
+		return fmt.Errorf("failed to write bundle: %w", err)
+	}
+	if _, err := io.Copy(tw, ciphertext); err != nil {
+		return fmt.Errorf("failed to write ciphertext to bundle: %w", err)
+	}
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("failed to write bundle: %w", err)
+	}
+	return nil
+}
+
+// Reader reads a bundle's manifest and then, once, its ciphertext
+type Reader struct {
+	Manifest *Manifest
+	tr       *tar.Reader
+}
+
+// NewReader reads the manifest at the start of a bundle
+func NewReader(r io.Reader) (*Reader, error) {
+	tr := tar.NewReader(r)
+	header, err := tr.Next()
+	if err != nil {
+		return nil, fmt.Errorf("not a paper bundle: %w", err)
+	}
+	if header.Name != manifestName || header.Size > maxManifest {
+		return nil, fmt.Errorf("not a paper bundle: expected %s first", manifestName)
+	}
+
+	var manifest Manifest
+	if err := json.NewDecoder(io.LimitReader(tr, maxManifest)).Decode(&manifest); err != nil {
+		return nil, fmt.Errorf("invalid bundle manifest: %w", err)
+	}
+	switch manifest.Format {
+	case Format:
+	case formatV1:
+		manifest.Signature.Scheme = crypto.SignatureSchemeContent
+	default:
+		return nil, fmt.Errorf("unsupported bundle format %q", manifest.Format)
+	}
+	return &Reader{Manifest: &manifest, tr: tr}, nil
+}
+
+// Ciphertext returns the paper.enc entry
+func (br *Reader) Ciphertext() (io.Reader, error) {
+	header, err := br.tr.Next()
+	if err != nil {
+		return nil, fmt.Errorf("bundle has no ciphertext: %w", err)
+	}
+	if header.Name != ciphertextName || header.Size != br.Manifest.Ciphertext.Size {
+		return nil, fmt.Errorf("bundle ciphertext does not match its manifest")
+	}
+	return br.tr, nil
+}
+
+// Open decrypts a bundle with the recipient's private key, writing the
+// plaintext to w, and verifies the ciphertext hash and the faculty signature
+// over the content and the manifest's paper metadata once all of it has been
+// written; output is untrusted unless it returns nil. The ciphertext only
+// opens under the paper ID it was bound to.
+// The signer's key is taken from the bundle: when signerFingerprint is set it
+// must match, which pins the key to one obtained out of band.
+func Open(r io.Reader, privateKey crypto.PrivateKey, signerFingerprint string, w io.Writer) (*Manifest, error) {
+	br, err := NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	manifest := br.Manifest
+
+	recipientFingerprint, err := crypto.PublicKeyFingerprint(privateKey.Public())
+	if err != nil {
+		return nil, err
+	}
+	if manifest.Recipient.Fingerprint != recipientFingerprint {
+		return manifest, fmt.Errorf("bundle was exported for %s, not this key (%s)", manifest.Recipient.Fingerprint, recipientFingerprint)
+	}
+
+	signerKey, err := crypto.ParsePublicKey(manifest.Signature.SignerPublicKey)
+	if err != nil {
+		return manifest, fmt.Errorf("invalid signer public key: %w", err)
+	}
+	actualSigner, err := crypto.PublicKeyFingerprint(signerKey)
+	if err != nil {
+		return manifest, err
+	}
+	if actualSigner != manifest.Signature.SignerFingerprint {
+		return manifest, fmt.Errorf("signer public key does not match its fingerprint")
+	}
+	if signerFingerprint != "" && actualSigner != signerFingerprint {
+		return manifest, fmt.Errorf("paper was signed by %s, not the expected %s", actualSigner, signerFingerprint)
+	}
+
+	wrappedKey, err := crypto.DecodeBase64(manifest.Recipient.WrappedKey)
+	if err != nil {
+		return manifest, fmt.Errorf("invalid wrapped key: %w", err)
+	}
+	aesKey, err := privateKey.Unwrap(manifest.Recipient.KeyWrap, wrappedKey)
+	if err != nil {
+		return manifest, fmt.Errorf("failed to decrypt AES key: %w", err)
+	}
+
+	ciphertext, err := br.Ciphertext()
+	if err != nil {
+		return manifest, err
+	}
+	ciphertextDigest := sha256.New()
+	plaintextDigest := sha256.New()
+	_, err = crypto.DecryptContent(manifest.Ciphertext.Format, io.TeeReader(ciphertext, ciphertextDigest), aesKey,
+		crypto.PaperAdditionalData(manifest.Paper.ID), io.MultiWriter(w, plaintextDigest))
+	if err != nil {
+		return manifest, fmt.Errorf("failed to decrypt paper: %w", err)
+	}
+	// Drain anything the decrypter did not need so the hash covers all of paper.enc
+	if _, err := io.Copy(ciphertextDigest, ciphertext); err != nil {
+		return manifest, fmt.Errorf("failed to read ciphertext: %w", err)
+	}
+	if hex.EncodeToString(ciphertextDigest.Sum(nil)) != manifest.Ciphertext.SHA256 {
+		return manifest, fmt.Errorf("ciphertext does not match the SHA-256 in the manifest")
+	}
+
+	signature, err := crypto.DecodeBase64(manifest.Signature.Value)
+	if err != nil {
+		return manifest, fmt.Errorf("invalid signature: %w", err)
+	}
+	envelope, err := manifest.Paper.envelope(plaintextDigest.Sum(nil))
+	if err != nil {
+		return manifest, err
+	}
+	signedDigest, err := envelope.SignedDigest(manifest.Signature.Scheme)
+	if err != nil {
+		return manifest, err
+	}
+	if err := signerKey.VerifyDigest(manifest.Signature.Algorithm, signedDigest, signature); err != nil {
+		return manifest, fmt.Errorf("signature verification failed: %w", err)
+	}
+	return manifest, nil
+}
+
+// envelope rebuilds the signed envelope from the manifest's paper metadata
+func (p Paper) envelope(contentSHA256 []byte) (crypto.PaperEnvelope, error) {
+	envelope := crypto.PaperEnvelope{
+		PaperID:       p.ID,
+		ContentSHA256: contentSHA256,
+		Title:         p.Title,
+		Subject:       p.Subject,
+		FacultyID:     p.FacultyID,
+		UploadedAt:    p.UploadDate,
+	}
+	if p.ExamDate != "" {
+		examDate, err := time.Parse("2006-01-02", p.ExamDate)
+		if err != nil {
+			return envelope, fmt.Errorf("invalid exam date in manifest: %w", err)
+		}
+		envelope.ExamDate = examDate
+	}
+	return envelope, nil
+}
+
+// OpenToFile decrypts a bundle into path. The plaintext is written to a
+// temporary file beside it and renamed into place only once it verifies.
+func OpenToFile(r io.Reader, privateKey crypto.PrivateKey, signerFingerprint, path string) (*Manifest, error) {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".paper-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create output file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	manifest, err := Open(r, privateKey, signerFingerprint, tmp)
+	if err != nil {
+		tmp.Close()
+		return manifest, err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return manifest, fmt.Errorf("failed to write output file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return manifest, fmt.Errorf("failed to write output file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return manifest, fmt.Errorf("failed to save decrypted paper: %w", err)
+	}
+	return manifest, nil
+}
@@ -20,8 +20,10 @@ func GenerateAESKey() ([]byte, error) {
 	return key, nil
 }
 
-// EncryptAES encrypts data using AES-GCM
-func EncryptAES(plaintext []byte, key []byte) ([]byte, error) {
+// EncryptAES encrypts data using AES-GCM. additionalData is authenticated but
+// not encrypted, binding the ciphertext to its context (e.g. PaperAdditionalData);
+// it may be nil and must be supplied again to DecryptAES.
+func EncryptAES(plaintext []byte, key []byte, additionalData []byte) ([]byte, error) {
 	block, err := aes.NewCipher(key)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create cipher: %w", err)
@@ -41,12 +43,13 @@ func EncryptAES(plaintext []byte, key []byte) ([]byte, error) {
 
 	// Encrypt and authenticate
 	// The nonce is prepended to the ciphertext
-	ciphertext := gcm.Seal(nonce, nonce, plaintext, nil)
+	ciphertext := gcm.Seal(nonce, nonce, plaintext, additionalData)
 	return ciphertext, nil
 }
 
-// DecryptAES decrypts data using AES-GCM
-func DecryptAES(ciphertext []byte, key []byte) ([]byte, error) {
+// DecryptAES decrypts data using AES-GCM, failing unless additionalData
+// matches what it was encrypted with
+func DecryptAES(ciphertext []byte, key []byte, additionalData []byte) ([]byte, error) {
 	block, err := aes.NewCipher(key)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create cipher: %w", err)
@@ -66,7 +69,7 @@ func DecryptAES(ciphertext []byte, key []byte) ([]byte, error) {
 	nonce, ciphertext := ciphertext[:nonceSize], ciphertext[nonceSize:]
 
 	// Decrypt and verify
-	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, additionalData)
 	if err != nil {
 		return nil, fmt.Errorf("failed to decrypt: %w", err)
 	}
@@ -0,0 +1,68 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Signature schemes: what a paper's signature covers, recorded per paper in
+// signature_scheme
+const (
+	SignatureSchemeContent  = "content-sha256" // SHA-256 of the plaintext only; legacy, verify only
+	SignatureSchemeEnvelope = "envelope-v1"    // SHA-256 of the paper's PaperEnvelope
+)
+
+// PaperEnvelope is the paper metadata a faculty signature covers along with
+// the content, so rows cannot be swapped or edited without breaking it
+type PaperEnvelope struct {
+	PaperID       int
+	ContentSHA256 []byte
+	Title         string
+	Subject       string
+	ExamDate      time.Time // only the UTC date is signed; zero if unset
+	FacultyID     int
+	UploadedAt    time.Time // signed in UTC to the second
+}
+
+// Canonical returns the exact bytes that are hashed and signed: a version line
+// and then one line per field, with strings quoted so that no value can run
+// into the next field
+func (e PaperEnvelope) Canonical() []byte {
+	examDate := ""
+	if !e.ExamDate.IsZero() {
+		examDate = e.ExamDate.UTC().Format("2006-01-02")
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "%s\n", SignatureSchemeEnvelope)
+	fmt.Fprintf(&b, "paper_id:%d\n", e.PaperID)
+	fmt.Fprintf(&b, "content_sha256:%x\n", e.ContentSHA256)
+	fmt.Fprintf(&b, "title:%s\n", strconv.Quote(e.Title))
+	fmt.Fprintf(&b, "subject:%s\n", strconv.Quote(e.Subject))
+	fmt.Fprintf(&b, "exam_date:%s\n", examDate)
+	fmt.Fprintf(&b, "faculty_id:%d\n", e.FacultyID)
+	fmt.Fprintf(&b, "uploaded_at:%s\n", e.UploadedAt.UTC().Truncate(time.Second).Format(time.RFC3339))
+	return b.Bytes()
+}
+
+// SignedDigest returns the SHA-256 digest a signature made under scheme covers
+func (e PaperEnvelope) SignedDigest(scheme string) ([]byte, error) {
+	switch scheme {
+	case SignatureSchemeEnvelope:
+		digest := sha256.Sum256(e.Canonical())
+		return digest[:], nil
+	case SignatureSchemeContent:
+		return e.ContentSHA256, nil
+	default:
+		return nil, fmt.Errorf("unsupported signature scheme %q", scheme)
+	}
+}
+
+// PaperAdditionalData is the AES-GCM additional data that binds a paper's
+// ciphertext to its ID, so it cannot be moved to another paper's row
+func PaperAdditionalData(paperID int) []byte {
+	return []byte("question paper " + strconv.Itoa(paperID))
+}
@@ -0,0 +1,324 @@
+package crypto
+
+import (
+	"crypto/ecdh"
+	"crypto/ed25519"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"strings"
+)
+
+// Key algorithms a user's key pair can use, stored in users.key_algorithm
+const (
+	KeyAlgRSA2048       = "rsa-2048" // accounts created before key suites
+	KeyAlgRSA3072       = "rsa-3072"
+	KeyAlgRSA4096       = "rsa-4096"
+	KeyAlgX25519Ed25519 = "x25519-ed25519" // X25519 key wrapping, Ed25519 signatures
+
+	DefaultKeyAlgorithm = KeyAlgX25519Ed25519
+)
+
+// Algorithms used by the X25519/Ed25519 suite
+const (
+	KeyWrapX25519    = "x25519-hkdf-sha256-aes256gcm"
+	SignatureEd25519 = "ed25519"
+
+	x25519PublicKeyType = "X25519-ED25519 PUBLIC KEY"
+	x25519WrapInfo      = "question paper x25519 key wrap v1"
+)
+
+// PrivateKey is a user's unlocked key pair, whatever its algorithm
+type PrivateKey interface {
+	Algorithm() string
+	Public() PublicKey
+	// Unwrap opens a key wrapped for this key pair with the named wrap algorithm
+	Unwrap(wrapAlg string, ciphertext []byte) ([]byte, error)
+	// SignDigest signs a SHA-256 digest and names the signature algorithm used
+	SignDigest(digest []byte) (signature []byte, sigAlg string, err error)
+}
+
+// PublicKey is the shareable half of a key pair
+type PublicKey interface {
+	Algorithm() string
+	// Wrap encrypts a small secret for the key's owner and names the wrap algorithm used
+	Wrap(secret []byte) (ciphertext []byte, wrapAlg string, err error)
+	// VerifyDigest checks a signature over a SHA-256 digest made with sigAlg
+	VerifyDigest(sigAlg string, digest, signature []byte) error
+}
+
+// ParseKeyAlgorithm validates a key algorithm name; empty means the default
+func ParseKeyAlgorithm(name string) (string, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	switch name {
+	case "":
+		return DefaultKeyAlgorithm, nil
+	case KeyAlgRSA2048, KeyAlgRSA3072, KeyAlgRSA4096, KeyAlgX25519Ed25519:
+		return name, nil
+	default:
+		return "", fmt.Errorf("unsupported key algorithm %q (use %s, %s, %s or %s)",
+			name, KeyAlgX25519Ed25519, KeyAlgRSA3072, KeyAlgRSA4096, KeyAlgRSA2048)
+	}
+}
+
+// GenerateKeyPair creates a key pair of the named algorithm
+func GenerateKeyPair(algorithm string) (PrivateKey, error) {
+	switch algorithm {
+	case KeyAlgRSA2048, KeyAlgRSA3072, KeyAlgRSA4096:
+		bits := map[string]int{KeyAlgRSA2048: 2048, KeyAlgRSA3072: 3072, KeyAlgRSA4096: 4096}[algorithm]
+		key, err := rsa.GenerateKey(rand.Reader, bits)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate RSA key pair: %w", err)
+		}
+		return RSAPrivateKey{key}, nil
+	case KeyAlgX25519Ed25519:
+		_, signKey, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate Ed25519 key: %w", err)
+		}
+		kexKey, err := ecdh.X25519().GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate X25519 key: %w", err)
+		}
+		return X25519Ed25519PrivateKey{Sign: signKey, KeyExchange: kexKey}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key algorithm %q", algorithm)
+	}
+}
+
+// EncodePublicKey serialises any public key to PEM for users.public_key
+func EncodePublicKey(publicKey PublicKey) (string, error) {
+	switch pub := publicKey.(type) {
+	case RSAPublicKey:
+		return EncodePublicKeyToPEM(pub.PublicKey)
+	case X25519Ed25519PublicKey:
+		block := &pem.Block{
+			Type:  x25519PublicKeyType,
+			Bytes: append(append([]byte{}, pub.Sign...), pub.KeyExchange.Bytes()...),
+		}
+		return string(pem.EncodeToMemory(block)), nil
+	default:
+		return "", fmt.Errorf("unsupported public key type %T", publicKey)
+	}
+}
+
+// PublicKeyFingerprint identifies a public key as "SHA256:" and the hex
+// SHA-256 of its encoded form, for comparing keys handled out of band
+func PublicKeyFingerprint(publicKey PublicKey) (string, error) {
+	publicKeyPEM, err := EncodePublicKey(publicKey)
+	if err != nil {
+		return "", err
+	}
+	block, _ := pem.Decode([]byte(publicKeyPEM))
+	if block == nil {
+		return "", fmt.Errorf("failed to decode PEM block")
+	}
+	sum := sha256.Sum256(block.Bytes)
+	return "SHA256:" + hex.EncodeToString(sum[:]), nil
+}
+
+// ParsePublicKey reads a PEM public key of any supported algorithm
+func ParsePublicKey(publicKeyPEM string) (PublicKey, error) {
+	block, _ := pem.Decode([]byte(publicKeyPEM))
+	if block == nil {
+		return nil, fmt.Errorf("failed to decode PEM block")
+	}
+
+	switch block.Type {
+	case x25519PublicKeyType:
+		if len(block.Bytes) != ed25519.PublicKeySize+32 {
+			return nil, fmt.Errorf("invalid %s length", x25519PublicKeyType)
+		}
+		kex, err := ecdh.X25519().NewPublicKey(block.Bytes[ed25519.PublicKeySize:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid X25519 public key: %w", err)
+		}
+		sign := ed25519.PublicKey(append([]byte{}, block.Bytes[:ed25519.PublicKeySize]...))
+		return X25519Ed25519PublicKey{Sign: sign, KeyExchange: kex}, nil
+	default:
+		pub, err := DecodePublicKeyFromPEM(publicKeyPEM)
+		if err != nil {
+			return nil, err
+		}
+		return RSAPublicKey{pub}, nil
+	}
+}
+
+// marshalPrivateKey returns the bytes wrapped by EncryptPrivateKeyToPEM
+func marshalPrivateKey(privateKey PrivateKey) ([]byte, error) {
+	switch key := privateKey.(type) {
+	case RSAPrivateKey:
+		return x509.MarshalPKCS1PrivateKey(key.PrivateKey), nil
+	case X25519Ed25519PrivateKey:
+		return append(append([]byte{}, key.Sign.Seed()...), key.KeyExchange.Bytes()...), nil
+	default:
+		return nil, fmt.Errorf("unsupported private key type %T", privateKey)
+	}
+}
+
+// unmarshalPrivateKey reverses marshalPrivateKey
+func unmarshalPrivateKey(algorithm string, data []byte) (PrivateKey, error) {
+	if algorithm == KeyAlgX25519Ed25519 {
+		if len(data) != ed25519.SeedSize+32 {
+			return nil, fmt.Errorf("invalid %s private key length", algorithm)
+		}
+		kex, err := ecdh.X25519().NewPrivateKey(data[ed25519.SeedSize:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid X25519 private key: %w", err)
+		}
+		return X25519Ed25519PrivateKey{Sign: ed25519.NewKeyFromSeed(data[:ed25519.SeedSize]), KeyExchange: kex}, nil
+	}
+
+	key, err := x509.ParsePKCS1PrivateKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key: %w", err)
+	}
+	return RSAPrivateKey{key}, nil
+}
+
+// RSAPrivateKey is the RSA suite: OAEP key wrapping and PSS signatures
+type RSAPrivateKey struct {
+	*rsa.PrivateKey
+}
+
+func (k RSAPrivateKey) Algorithm() string {
+	return fmt.Sprintf("rsa-%d", k.N.BitLen())
+}
+
+func (k RSAPrivateKey) Public() PublicKey {
+	return RSAPublicKey{&k.PrivateKey.PublicKey}
+}
+
+func (k RSAPrivateKey) Unwrap(wrapAlg string, ciphertext []byte) ([]byte, error) {
+	return DecryptWithAlgorithm(wrapAlg, ciphertext, k.PrivateKey)
+}
+
+func (k RSAPrivateKey) SignDigest(digest []byte) ([]byte, string, error) {
+	signature, err := SignDigest(digest, k.PrivateKey)
+	return signature, SignaturePSS, err
+}
+
+// RSAPublicKey is the public half of an RSAPrivateKey
+type RSAPublicKey struct {
+	*rsa.PublicKey
+}
+
+func (k RSAPublicKey) Algorithm() string {
+	return fmt.Sprintf("rsa-%d", k.N.BitLen())
+}
+
+func (k RSAPublicKey) Wrap(secret []byte) ([]byte, string, error) {
+	ciphertext, err := EncryptWithPublicKey(secret, k.PublicKey)
+	return ciphertext, KeyWrapOAEP, err
+}
+
+func (k RSAPublicKey) VerifyDigest(sigAlg string, digest, signature []byte) error {
+	return VerifyDigest(sigAlg, digest, signature, k.PublicKey)
+}
+
+// X25519Ed25519PrivateKey pairs an Ed25519 signing key with an X25519 key
+// agreement key; the two are independent so neither use weakens the other
+type X25519Ed25519PrivateKey struct {
+	Sign        ed25519.PrivateKey
+	KeyExchange *ecdh.PrivateKey
+}
+
+func (k X25519Ed25519PrivateKey) Algorithm() string { return KeyAlgX25519Ed25519 }
+
+func (k X25519Ed25519PrivateKey) Public() PublicKey {
+	return X25519Ed25519PublicKey{
+		Sign:        k.Sign.Public().(ed25519.PublicKey),
+		KeyExchange: k.KeyExchange.PublicKey(),
+	}
+}
+
+// Unwrap reverses X25519Ed25519PublicKey.Wrap
+func (k X25519Ed25519PrivateKey) Unwrap(wrapAlg string, ciphertext []byte) ([]byte, error) {
+	if wrapAlg != KeyWrapX25519 {
+		return nil, fmt.Errorf("unsupported key wrap algorithm %q for %s keys", wrapAlg, KeyAlgX25519Ed25519)
+	}
+	if len(ciphertext) < 32 {
+		return nil, fmt.Errorf("wrapped key too short")
+	}
+
+	ephemeral, err := ecdh.X25519().NewPublicKey(ciphertext[:32])
+	if err != nil {
+		return nil, fmt.Errorf("invalid ephemeral key: %w", err)
+	}
+	shared, err := k.KeyExchange.ECDH(ephemeral)
+	if err != nil {
+		return nil, fmt.Errorf("key agreement failed: %w", err)
+	}
+	wrapKey, err := x25519WrapKey(shared, ciphertext[:32], k.KeyExchange.PublicKey().Bytes())
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := DecryptAES(ciphertext[32:], wrapKey, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap key: %w", err)
+	}
+	return secret, nil
+}
+
+// SignDigest signs the SHA-256 digest itself with Ed25519
+func (k X25519Ed25519PrivateKey) SignDigest(digest []byte) ([]byte, string, error) {
+	return ed25519.Sign(k.Sign, digest), SignatureEd25519, nil
+}
+
+// X25519Ed25519PublicKey is the public half of an X25519Ed25519PrivateKey
+type X25519Ed25519PublicKey struct {
+	Sign        ed25519.PublicKey
+	KeyExchange *ecdh.PublicKey
+}
+
+func (k X25519Ed25519PublicKey) Algorithm() string { return KeyAlgX25519Ed25519 }
+
+// Wrap encrypts secret to the recipient with an ephemeral X25519 key: the
+// shared secret is expanded with HKDF-SHA256 (salted with both public keys)
+// into an AES-256-GCM key. Output is ephemeral public key || nonce || sealed.
+func (k X25519Ed25519PublicKey) Wrap(secret []byte) ([]byte, string, error) {
+	ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to generate ephemeral key: %w", err)
+	}
+	shared, err := ephemeral.ECDH(k.KeyExchange)
+	if err != nil {
+		return nil, "", fmt.Errorf("key agreement failed: %w", err)
+	}
+	ephemeralPublic := ephemeral.PublicKey().Bytes()
+	wrapKey, err := x25519WrapKey(shared, ephemeralPublic, k.KeyExchange.Bytes())
+	if err != nil {
+		return nil, "", err
+	}
+
+	sealed, err := EncryptAES(secret, wrapKey, nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to wrap key: %w", err)
+	}
+	return append(ephemeralPublic, sealed...), KeyWrapX25519, nil
+}
+
+func (k X25519Ed25519PublicKey) VerifyDigest(sigAlg string, digest, signature []byte) error {
+	if sigAlg != SignatureEd25519 {
+		return fmt.Errorf("unsupported signature algorithm %q for %s keys", sigAlg, KeyAlgX25519Ed25519)
+	}
+	if !ed25519.Verify(k.Sign, digest, signature) {
+		return fmt.Errorf("signature verification failed: invalid Ed25519 signature")
+	}
+	return nil
+}
+
+func x25519WrapKey(shared, ephemeralPublic, recipientPublic []byte) ([]byte, error) {
+	salt := append(append([]byte{}, ephemeralPublic...), recipientPublic...)
+	key, err := hkdf.Key(sha256.New, shared, salt, x25519WrapInfo, AESKeySize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive wrapping key: %w", err)
+	}
+	return key, nil
+}
@@ -0,0 +1,105 @@
+package crypto
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+const (
+	KDFSaltSize = 16
+
+	// Argon2id parameters for password-derived key wrapping
+	argon2Time    = 1
+	argon2Memory  = 64 * 1024 // 64 MB
+	argon2Threads = 4
+
+	encryptedPrivateKeyType = "ENCRYPTED RSA PRIVATE KEY"
+	encryptedKeyType        = "ENCRYPTED PRIVATE KEY" // non-RSA keys, with an Algorithm header
+	kdfArgon2id             = "argon2id"
+)
+
+// DeriveKeyFromPassword stretches a password into an AES-256 key using Argon2id
+func DeriveKeyFromPassword(password string, salt []byte) []byte {
+	return argon2.IDKey([]byte(password), salt, argon2Time, argon2Memory, argon2Threads, AESKeySize)
+}
+
+// EncryptPrivateKeyToPEM wraps a private key with a key derived from the user's password
+func EncryptPrivateKeyToPEM(privateKey PrivateKey, password string) (string, error) {
+	keyBytes, err := marshalPrivateKey(privateKey)
+	if err != nil {
+		return "", err
+	}
+
+	salt := make([]byte, KDFSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("failed to generate KDF salt: %w", err)
+	}
+
+	wrappingKey := DeriveKeyFromPassword(password, salt)
+	encrypted, err := EncryptAES(keyBytes, wrappingKey, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to encrypt private key: %w", err)
+	}
+
+	// KDF parameters travel with the ciphertext so the key can be unwrapped later
+	block := &pem.Block{
+		Type: encryptedPrivateKeyType,
+		Headers: map[string]string{
+			"KDF":  kdfArgon2id,
+			"Salt": hex.EncodeToString(salt),
+		},
+		Bytes: encrypted,
+	}
+	if _, isRSA := privateKey.(RSAPrivateKey); !isRSA {
+		block.Type = encryptedKeyType
+		block.Headers["Algorithm"] = privateKey.Algorithm()
+	}
+	return string(pem.EncodeToMemory(block)), nil
+}
+
+// DecryptPrivateKeyFromPEM unwraps a password-encrypted private key
+func DecryptPrivateKeyFromPEM(encryptedPEM, password string) (PrivateKey, error) {
+	block, _ := pem.Decode([]byte(encryptedPEM))
+	if block == nil {
+		return nil, fmt.Errorf("failed to decode PEM block")
+	}
+	algorithm := KeyAlgRSA2048
+	switch block.Type {
+	case encryptedPrivateKeyType:
+	case encryptedKeyType:
+		algorithm = block.Headers["Algorithm"]
+		if algorithm != KeyAlgX25519Ed25519 {
+			return nil, fmt.Errorf("unsupported private key algorithm: %s", algorithm)
+		}
+	default:
+		return nil, fmt.Errorf("private key is not password-encrypted")
+	}
+	if block.Headers["KDF"] != kdfArgon2id {
+		return nil, fmt.Errorf("unsupported key derivation function: %s", block.Headers["KDF"])
+	}
+
+	salt, err := hex.DecodeString(block.Headers["Salt"])
+	if err != nil || len(salt) == 0 {
+		return nil, fmt.Errorf("invalid KDF salt")
+	}
+
+	wrappingKey := DeriveKeyFromPassword(password, salt)
+	keyBytes, err := DecryptAES(block.Bytes, wrappingKey, nil)
+	if err != nil {
+		// GCM authentication fails when the password is wrong
+		return nil, fmt.Errorf("failed to unlock private key (wrong password?)")
+	}
+
+	return unmarshalPrivateKey(algorithm, keyBytes)
+}
+
+// IsEncryptedPrivateKeyPEM reports whether a stored key is password-encrypted
+func IsEncryptedPrivateKeyPEM(privateKeyPEM string) bool {
+	return strings.Contains(privateKeyPEM, "BEGIN "+encryptedPrivateKeyType) ||
+		strings.Contains(privateKeyPEM, "BEGIN "+encryptedKeyType)
+}
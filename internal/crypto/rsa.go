@@ -3,6 +3,7 @@ package crypto
 import (
 	"crypto/rand"
 	"crypto/rsa"
+	"crypto/sha256"
 	"crypto/x509"
 	"encoding/pem"
 	"fmt"
@@ -79,18 +80,39 @@ func DecodePublicKeyFromPEM(publicKeyPEM string) (*rsa.PublicKey, error) {
 	return publicKey, nil
 }
 
-// EncryptWithPublicKey encrypts data with RSA public key (for AES key encryption)
+// Key transport algorithms, stored alongside every wrapped key
+const (
+	KeyWrapOAEP     = "rsa-oaep-sha256" // current
+	KeyWrapPKCS1v15 = "rsa-pkcs1v15"    // legacy, decrypt only
+)
+
+// EncryptWithPublicKey encrypts data with RSA-OAEP (SHA-256), i.e. KeyWrapOAEP
 func EncryptWithPublicKey(data []byte, publicKey *rsa.PublicKey) ([]byte, error) {
-	ciphertext, err := rsa.EncryptPKCS1v15(rand.Reader, publicKey, data)
+	ciphertext, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, publicKey, data, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to encrypt with public key: %w", err)
 	}
 	return ciphertext, nil
 }
 
-// DecryptWithPrivateKey decrypts data with RSA private key
+// DecryptWithPrivateKey decrypts RSA-OAEP (SHA-256) ciphertext
 func DecryptWithPrivateKey(ciphertext []byte, privateKey *rsa.PrivateKey) ([]byte, error) {
-	plaintext, err := rsa.DecryptPKCS1v15(rand.Reader, privateKey, ciphertext)
+	return DecryptWithAlgorithm(KeyWrapOAEP, ciphertext, privateKey)
+}
+
+// DecryptWithAlgorithm decrypts a key wrapped with the named algorithm, so
+// keys stored before OAEP can still be opened
+func DecryptWithAlgorithm(algorithm string, ciphertext []byte, privateKey *rsa.PrivateKey) ([]byte, error) {
+	var plaintext []byte
+	var err error
+	switch algorithm {
+	case KeyWrapOAEP:
+		plaintext, err = rsa.DecryptOAEP(sha256.New(), nil, privateKey, ciphertext, nil)
+	case KeyWrapPKCS1v15:
+		plaintext, err = rsa.DecryptPKCS1v15(nil, privateKey, ciphertext)
+	default:
+		return nil, fmt.Errorf("unsupported key wrap algorithm %q", algorithm)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to decrypt with private key: %w", err)
 	}
@@ -0,0 +1,142 @@
+package crypto
+
+import (
+	"crypto/rand"
+	"fmt"
+)
+
+// Shamir's secret sharing over GF(2^8).
+// Each share is the secret-length y values followed by a single x coordinate byte.
+
+var (
+	gfExp [510]byte
+	gfLog [256]byte
+)
+
+func init() {
+	// Build log/exp tables for GF(2^8) with the AES polynomial and generator 3
+	x := byte(1)
+	for i := 0; i < 255; i++ {
+		gfExp[i] = x
+		gfLog[x] = byte(i)
+		x = gfMulNoTable(x, 3)
+	}
+	for i := 255; i < len(gfExp); i++ {
+		gfExp[i] = gfExp[i-255]
+	}
+}
+
+func gfMulNoTable(a, b byte) byte {
+	var p byte
+	for b > 0 {
+		if b&1 != 0 {
+			p ^= a
+		}
+		carry := a & 0x80
+		a <<= 1
+		if carry != 0 {
+			a ^= 0x1b
+		}
+		b >>= 1
+	}
+	return p
+}
+
+func gfMul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return gfExp[int(gfLog[a])+int(gfLog[b])]
+}
+
+func gfDiv(a, b byte) byte {
+	if a == 0 {
+		return 0
+	}
+	return gfExp[int(gfLog[a])+255-int(gfLog[b])]
+}
+
+// SplitSecret splits a secret into n shares, any k of which recover it
+func SplitSecret(secret []byte, n, k int) ([][]byte, error) {
+	if k < 2 || k > n {
+		return nil, fmt.Errorf("threshold must be between 2 and %d", n)
+	}
+	if n > 255 {
+		return nil, fmt.Errorf("at most 255 shares are supported")
+	}
+	if len(secret) == 0 {
+		return nil, fmt.Errorf("secret cannot be empty")
+	}
+
+	shares := make([][]byte, n)
+	for i := range shares {
+		shares[i] = make([]byte, len(secret)+1)
+		shares[i][len(secret)] = byte(i + 1) // x coordinate, never zero
+	}
+
+	// One random polynomial of degree k-1 per secret byte, constant term = secret byte
+	coefficients := make([]byte, k)
+	for b, secretByte := range secret {
+		if _, err := rand.Read(coefficients[1:]); err != nil {
+			return nil, fmt.Errorf("failed to generate polynomial: %w", err)
+		}
+		coefficients[0] = secretByte
+
+		for i := range shares {
+			x := shares[i][len(secret)]
+			// Horner's method
+			var y byte
+			for c := k - 1; c >= 0; c-- {
+				y = gfMul(y, x) ^ coefficients[c]
+			}
+			shares[i][b] = y
+		}
+	}
+
+	return shares, nil
+}
+
+// CombineShares recovers a secret from at least k shares by Lagrange interpolation at x = 0
+func CombineShares(shares [][]byte) ([]byte, error) {
+	if len(shares) < 2 {
+		return nil, fmt.Errorf("at least 2 shares are required")
+	}
+
+	shareLen := len(shares[0])
+	if shareLen < 2 {
+		return nil, fmt.Errorf("invalid share length")
+	}
+
+	xs := make([]byte, len(shares))
+	seen := make(map[byte]bool)
+	for i, share := range shares {
+		if len(share) != shareLen {
+			return nil, fmt.Errorf("shares have different lengths")
+		}
+		x := share[shareLen-1]
+		if x == 0 || seen[x] {
+			return nil, fmt.Errorf("invalid or duplicate share")
+		}
+		seen[x] = true
+		xs[i] = x
+	}
+
+	secret := make([]byte, shareLen-1)
+	for b := range secret {
+		var value byte
+		for i := range shares {
+			// Lagrange basis polynomial l_i(0)
+			basis := byte(1)
+			for j := range shares {
+				if i == j {
+					continue
+				}
+				basis = gfMul(basis, gfDiv(xs[j], xs[i]^xs[j]))
+			}
+			value ^= gfMul(shares[i][b], basis)
+		}
+		secret[b] = value
+	}
+
+	return secret, nil
+}
@@ -8,13 +8,27 @@ import (
 	"fmt"
 )
 
+// Signature algorithms, stored with every paper signature
+const (
+	SignaturePSS      = "rsa-pss-sha256"      // current
+	SignaturePKCS1v15 = "rsa-pkcs1v15-sha256" // legacy, verify only
+)
+
+// pssOptions uses a salt as long as the hash, the usual interoperable choice
+var pssOptions = &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: crypto.SHA256}
+
 // CreateSignature signs data with private key
 func CreateSignature(data []byte, privateKey *rsa.PrivateKey) ([]byte, error) {
 	// Hash the data
 	hash := sha256.Sum256(data)
 
-	// Sign the hash
-	signature, err := rsa.SignPKCS1v15(rand.Reader, privateKey, crypto.SHA256, hash[:])
+	return SignDigest(hash[:], privateKey)
+}
+
+// SignDigest signs a SHA-256 digest computed elsewhere, e.g. while streaming,
+// using RSA-PSS (SignaturePSS)
+func SignDigest(digest []byte, privateKey *rsa.PrivateKey) ([]byte, error) {
+	signature, err := rsa.SignPSS(rand.Reader, privateKey, crypto.SHA256, digest, pssOptions)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create signature: %w", err)
 	}
@@ -27,8 +41,21 @@ func VerifySignature(data []byte, signature []byte, publicKey *rsa.PublicKey) er
 	// Hash the data
 	hash := sha256.Sum256(data)
 
-	// Verify signature
-	err := rsa.VerifyPKCS1v15(publicKey, crypto.SHA256, hash[:], signature)
+	return VerifyDigest(SignaturePSS, hash[:], signature, publicKey)
+}
+
+// VerifyDigest verifies a signature over a SHA-256 digest computed elsewhere,
+// made with the named algorithm
+func VerifyDigest(algorithm string, digest []byte, signature []byte, publicKey *rsa.PublicKey) error {
+	var err error
+	switch algorithm {
+	case SignaturePSS:
+		err = rsa.VerifyPSS(publicKey, crypto.SHA256, digest, signature, pssOptions)
+	case SignaturePKCS1v15:
+		err = rsa.VerifyPKCS1v15(publicKey, crypto.SHA256, digest, signature)
+	default:
+		return fmt.Errorf("unsupported signature algorithm %q", algorithm)
+	}
 	if err != nil {
 		return fmt.Errorf("signature verification failed: %w", err)
 	}
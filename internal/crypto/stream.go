@@ -0,0 +1,288 @@
+package crypto
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Streaming AES-256-GCM format for large papers.
+//
+// Header (32 bytes): magic "SQPS" | version | 3 reserved zero bytes |
+// chunk size (uint32 BE) | 16-byte salt | 4 reserved zero bytes.
+// The content key is expanded with HKDF-SHA256 over the salt, so every stream
+// gets its own GCM key. The plaintext is split into chunks of the header's
+// size; chunk i is sealed with nonce = i (uint64 BE) | 3 zero bytes | last
+// flag, and the header as additional data. The counter stops reordering and
+// dropping chunks, the last flag stops truncation at a chunk boundary, and
+// authenticating the header stops tampering with the chunk size or salt.
+// Version 2 appends the caller's additional data (e.g. PaperAdditionalData)
+// to the header in every chunk's additional data, binding the stream to it.
+const (
+	StreamChunkSize  = 64 * 1024
+	streamHeaderSize = 32
+	streamVersion    = 2
+	streamMaxChunk   = 16 * 1024 * 1024
+	streamTagSize    = 16
+	streamKeyInfo    = "question paper stream v1"
+)
+
+// Content formats of a paper's ciphertext, recorded per paper in content_format
+const (
+	ContentFormatGCM      = "gcm"           // one AES-GCM seal of the whole paper, no additional data
+	ContentFormatStreamV1 = "gcm-stream-v1" // chunked AES-GCM stream, not bound to its paper
+	ContentFormatStream   = "gcm-stream-v2" // chunked AES-GCM stream bound to its paper (NewEncryptWriter)
+)
+
+var streamMagic = []byte("SQPS")
+
+// ErrStreamCorrupt is returned when a stream fails authentication or is cut short
+var ErrStreamCorrupt = errors.New("encrypted stream is corrupt, truncated or reordered")
+
+func streamAEAD(key, salt []byte) (cipher.AEAD, error) {
+	if len(key) != AESKeySize {
+		return nil, fmt.Errorf("stream key must be %d bytes", AESKeySize)
+	}
+	subkey, err := hkdf.Key(sha256.New, key, salt, streamKeyInfo, AESKeySize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive stream key: %w", err)
+	}
+	block, err := aes.NewCipher(subkey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+func streamNonce(counter uint64, last bool) []byte {
+	nonce := make([]byte, 12)
+	binary.BigEndian.PutUint64(nonce, counter)
+	if last {
+		nonce[11] = 1
+	}
+	return nonce
+}
+
+type streamWriter struct {
+	dst            io.Writer
+	aead           cipher.AEAD
+	additionalData []byte // header, then the caller's additional data
+	buf            []byte
+	chunk          int
+	counter        uint64
+	closed         bool
+}
+
+// NewEncryptWriter returns a writer that encrypts everything written to it
+// onto dst, authenticating additionalData with every chunk. Close must be
+// called to write the final chunk; it does not close dst.
+func NewEncryptWriter(dst io.Writer, key []byte, additionalData []byte) (io.WriteCloser, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %w", err)
+	}
+
+	header := make([]byte, streamHeaderSize)
+	copy(header, streamMagic)
+	header[4] = streamVersion
+	binary.BigEndian.PutUint32(header[8:12], StreamChunkSize)
+	copy(header[12:28], salt)
+
+	aead, err := streamAEAD(key, salt)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := dst.Write(header); err != nil {
+		return nil, fmt.Errorf("failed to write stream header: %w", err)
+	}
+
+	return &streamWriter{
+		dst:            dst,
+		aead:           aead,
+		additionalData: append(header, additionalData...),
+		buf:            make([]byte, 0, StreamChunkSize+streamTagSize),
+		chunk:          StreamChunkSize,
+	}, nil
+}
+
+// Write buffers p, sealing a chunk whenever more than a chunk is pending so the
+// final chunk is always written by Close
+func (w *streamWriter) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, errors.New("write to closed encrypt stream")
+	}
+
+	n := len(p)
+	for len(p) > 0 {
+		if len(w.buf) == w.chunk {
+			if err := w.seal(false); err != nil {
+				return n - len(p), err
+			}
+		}
+		take := w.chunk - len(w.buf)
+		if take > len(p) {
+			take = len(p)
+		}
+		w.buf = append(w.buf, p[:take]...)
+		p = p[take:]
+	}
+	return n, nil
+}
+
+func (w *streamWriter) seal(last bool) error {
+	if w.counter == ^uint64(0) {
+		return errors.New("encrypt stream too long")
+	}
+	sealed := w.aead.Seal(w.buf[:0], streamNonce(w.counter, last), w.buf, w.additionalData)
+	if _, err := w.dst.Write(sealed); err != nil {
+		return fmt.Errorf("failed to write encrypted chunk: %w", err)
+	}
+	w.counter++
+	w.buf = w.buf[:0]
+	return nil
+}
+
+// Close seals the final chunk, which may be empty
+func (w *streamWriter) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	return w.seal(true)
+}
+
+type streamReader struct {
+	src            *bufio.Reader
+	aead           cipher.AEAD
+	additionalData []byte // header, then the caller's additional data
+	chunk          []byte
+	plain          []byte
+	counter        uint64
+	done           bool
+	err            error
+}
+
+// NewDecryptReader returns a reader of the plaintext of an encrypted stream.
+// Every chunk is authenticated, with additionalData, before it is returned; a
+// stream that is cut short, altered or bound to other additional data yields
+// ErrStreamCorrupt instead of io.EOF. Version 1 streams carry no additional
+// data, so they only open with nil.
+func NewDecryptReader(src io.Reader, key []byte, additionalData []byte) (io.Reader, error) {
+	header := make([]byte, streamHeaderSize)
+	if _, err := io.ReadFull(src, header); err != nil {
+		return nil, fmt.Errorf("failed to read stream header: %w", err)
+	}
+	if !IsEncryptedStream(header) {
+		return nil, errors.New("not an encrypted paper stream")
+	}
+	switch {
+	case header[4] == 1 && additionalData != nil:
+		return nil, errors.New("stream version 1 is not bound to additional data")
+	case header[4] != 1 && header[4] != streamVersion:
+		return nil, fmt.Errorf("unsupported stream version %d", header[4])
+	}
+	chunkSize := binary.BigEndian.Uint32(header[8:12])
+	if chunkSize == 0 || chunkSize > streamMaxChunk {
+		return nil, fmt.Errorf("invalid stream chunk size %d", chunkSize)
+	}
+
+	aead, err := streamAEAD(key, header[12:28])
+	if err != nil {
+		return nil, err
+	}
+
+	return &streamReader{
+		src:            bufio.NewReaderSize(src, int(chunkSize)+streamTagSize),
+		aead:           aead,
+		additionalData: append(header, additionalData...),
+		chunk:          make([]byte, int(chunkSize)+streamTagSize),
+	}, nil
+}
+
+// IsEncryptedStream reports whether data starts with a stream header
+func IsEncryptedStream(data []byte) bool {
+	return len(data) >= streamHeaderSize && bytes.Equal(data[:4], streamMagic)
+}
+
+func (r *streamReader) Read(p []byte) (int, error) {
+	for len(r.plain) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		if r.done {
+			return 0, io.EOF
+		}
+		r.err = r.next()
+	}
+
+	n := copy(p, r.plain)
+	r.plain = r.plain[n:]
+	return n, nil
+}
+
+// next reads and opens one chunk. A chunk is the last one when the source ends
+// right after it; it must then open with the last flag set.
+func (r *streamReader) next() error {
+	n, err := io.ReadFull(r.src, r.chunk)
+	switch {
+	case err == io.EOF:
+		return ErrStreamCorrupt
+	case err == io.ErrUnexpectedEOF:
+		r.done = true
+	case err != nil:
+		return fmt.Errorf("failed to read encrypted chunk: %w", err)
+	default:
+		if _, err := r.src.Peek(1); err == io.EOF {
+			r.done = true
+		} else if err != nil {
+			return fmt.Errorf("failed to read encrypted chunk: %w", err)
+		}
+	}
+
+	plain, err := r.aead.Open(r.chunk[:0], streamNonce(r.counter, r.done), r.chunk[:n], r.additionalData)
+	if err != nil {
+		return ErrStreamCorrupt
+	}
+	r.counter++
+	r.plain = plain
+	return nil
+}
+
+// DecryptContent writes the plaintext of ciphertext in the given format to w.
+// additionalData is what the ciphertext was bound to; formats that predate
+// binding ignore it.
+func DecryptContent(format string, ciphertext io.Reader, aesKey []byte, additionalData []byte, w io.Writer) (int64, error) {
+	switch format {
+	case ContentFormatStream, ContentFormatStreamV1:
+		if format == ContentFormatStreamV1 {
+			additionalData = nil
+		}
+		plaintext, err := NewDecryptReader(ciphertext, aesKey, additionalData)
+		if err != nil {
+			return 0, err
+		}
+		return io.Copy(w, plaintext)
+	case ContentFormatGCM:
+		// Papers uploaded before streaming were sealed in one GCM call
+		sealed, err := io.ReadAll(ciphertext)
+		if err != nil {
+			return 0, err
+		}
+		plaintext, err := DecryptAES(sealed, aesKey, nil)
+		if err != nil {
+			return 0, err
+		}
+		n, err := w.Write(plaintext)
+		return int64(n), err
+	default:
+		return 0, fmt.Errorf("unknown content format %q", format)
+	}
+}
@@ -4,25 +4,20 @@ import (
 	"database/sql"
 	"fmt"
 	"log"
-	"os"
-	// "strings"
 
-	_ "github.com/go-sql-driver/mysql"
 	"github.com/joho/godotenv"
 )
 
+// Connect opens the database selected by DB_DRIVER
 func Connect() (*sql.DB, error) {
 	godotenv.Load()
 
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?parseTime=true&multiStatements=true",
-		os.Getenv("DB_USER"),
-		os.Getenv("DB_PASSWORD"),
-		os.Getenv("DB_HOST"),
-		os.Getenv("DB_PORT"),
-		os.Getenv("DB_NAME"),
-	)
+	dialect, err := DialectFromEnv()
+	if err != nil {
+		return nil, err
+	}
 
-	db, err := sql.Open("mysql", dsn)
+	db, err := sql.Open(dialect.DriverName(), dialect.DSN())
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
@@ -31,54 +26,54 @@ func Connect() (*sql.DB, error) {
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
+	currentDialect = dialect
 	return db, nil
 }
 
+// InitSchema brings the database up to the latest migration on startup
 func InitSchema(db *sql.DB) error {
-	// Execute schema
-	_, err := db.Exec(Schema)
+	applied, err := MigrateUp(db)
 	if err != nil {
-		return fmt.Errorf("failed to create schema: %w", err)
+		return fmt.Errorf("failed to migrate schema: %w", err)
 	}
-
-	// Check if ACL data already exists
-	var count int
-	err = db.QueryRow("SELECT COUNT(*) FROM access_control").Scan(&count)
-	if err != nil {
-		return fmt.Errorf("failed to check ACL data: %w", err)
+	for _, migration := range applied {
+		log.Printf("Applied migration %04d_%s", migration.Version, migration.Name)
 	}
-
-	// Insert ACL data only if empty
-	if count == 0 {
-		_, err = db.Exec(ACLData)
-		if err != nil {
-			return fmt.Errorf("failed to insert ACL data: %w", err)
-		}
-		log.Println("ACL permissions initialized")
-	}
-
 	return nil
 }
 
 func VerifySchema(db *sql.DB) error {
 	tables := []string{
 		"users",
+		"user_keys",
 		"otp_sessions",
+		"recovery_codes",
+		"login_attempts",
+		"user_sessions",
 		"question_papers",
+		"paper_status_history",
+		"paper_revisions",
+		"paper_key_recipients",
+		"paper_reencryption_queue",
+		"paper_key_escrow",
+		"paper_key_shares",
+		"paper_share_submissions",
 		"exam_sessions",
+		"session_schedule_requests",
+		"decryption_overrides",
+		"email_outbox",
 		"access_control",
 		"audit_log",
 	}
 
 	for _, table := range tables {
-		var exists string
-		query := fmt.Sprintf("SHOW TABLES LIKE '%s'", table)
-		err := db.QueryRow(query).Scan(&exists)
-		if err == sql.ErrNoRows {
-			return fmt.Errorf("table %s does not exist", table)
-		} else if err != nil {
+		exists, err := currentDialect.TableExists(db, table)
+		if err != nil {
 			return err
 		}
+		if !exists {
+			return fmt.Errorf("table %s does not exist", table)
+		}
 		log.Printf("Table '%s' exists", table)
 	}
 
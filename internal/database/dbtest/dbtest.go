@@ -0,0 +1,39 @@
+// Package dbtest opens throwaway databases for tests in other packages
+package dbtest
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/internal/database"
+)
+
+// Open creates a SQLite database in a temporary directory, migrated to the
+// latest schema and closed when the test ends
+func Open(t testing.TB) *sql.DB {
+	t.Helper()
+	t.Setenv("DB_DRIVER", "sqlite")
+	t.Setenv("SQLITE_PATH", filepath.Join(t.TempDir(), "portal.db"))
+
+	db, err := database.Connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if err := database.InitSchema(db); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// Exec runs statements that set up a test, failing it on error
+func Exec(t testing.TB, db *sql.DB, query string, args ...any) sql.Result {
+	t.Helper()
+	result, err := db.Exec(query, args...)
+	if err != nil {
+		t.Fatalf("%s: %v", query, err)
+	}
+	return result
+}
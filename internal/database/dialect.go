@@ -0,0 +1,56 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Dialect captures what differs between the supported database backends;
+// each also has its own directory under migrations/ named after Name
+type Dialect interface {
+	// Name is the value of DB_DRIVER that selects the dialect
+	Name() string
+	// DriverName is the database/sql driver to open
+	DriverName() string
+	// DSN builds the connection string from the environment
+	DSN() string
+	// TableExists reports whether a table is present
+	TableExists(db *sql.DB, table string) (bool, error)
+	// PrepareMigration readies the connection a migration step runs on and
+	// returns a function that puts it back the way it was
+	PrepareMigration(ctx context.Context, conn *sql.Conn) (restore func() error, err error)
+	// CheckMigration runs in a migration step's transaction just before commit
+	CheckMigration(tx *sql.Tx) error
+}
+
+var dialects = map[string]Dialect{
+	"mysql":  MySQLDialect{},
+	"sqlite": SQLiteDialect{},
+}
+
+var currentDialect Dialect = MySQLDialect{}
+
+// DialectFromEnv returns the dialect named by DB_DRIVER (default mysql)
+func DialectFromEnv() (Dialect, error) {
+	name := strings.ToLower(strings.TrimSpace(os.Getenv("DB_DRIVER")))
+	if name == "" {
+		name = "mysql"
+	}
+	if name == "sqlite3" {
+		name = "sqlite"
+	}
+
+	dialect, ok := dialects[name]
+	if !ok {
+		return nil, fmt.Errorf("unsupported DB_DRIVER %q (use mysql or sqlite)", name)
+	}
+	return dialect, nil
+}
+
+// CurrentDialect returns the dialect of the open connection
+func CurrentDialect() Dialect {
+	return currentDialect
+}
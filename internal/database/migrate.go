@@ -0,0 +1,324 @@
+package database
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"database/sql/driver"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"log"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+//go:embed migrations
+var migrationFiles embed.FS
+
+// migrationFile matches NNNN_name.up.sql and NNNN_name.down.sql
+var migrationFile = regexp.MustCompile(`^(\d{4})_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+const migrationTable = `
+CREATE TABLE IF NOT EXISTS schema_migrations (
+    version INT PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    checksum CHAR(64) NOT NULL,
+    applied_at TIMESTAMP NOT NULL
+)`
+
+// Migration is one versioned schema change with its rollback
+type Migration struct {
+	Version  int
+	Name     string
+	Up       string
+	Down     string
+	Checksum string
+}
+
+// MigrationState describes a migration as recorded in schema_migrations
+type MigrationState struct {
+	Version   int
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+	// Modified is set when the up file no longer matches what was applied
+	Modified bool
+	// Unknown is set when the database has a version this build does not ship
+	Unknown bool
+}
+
+type appliedMigration struct {
+	name      string
+	checksum  string
+	appliedAt time.Time
+}
+
+// LoadMigrations reads the embedded migrations for a dialect in version order
+func LoadMigrations(dialect Dialect) ([]Migration, error) {
+	dir := path.Join("migrations", dialect.Name())
+	entries, err := fs.ReadDir(migrationFiles, dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations for %s: %w", dialect.Name(), err)
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		m := migrationFile.FindStringSubmatch(entry.Name())
+		if m == nil {
+			return nil, fmt.Errorf("unexpected file %s in %s", entry.Name(), dir)
+		}
+		version, _ := strconv.Atoi(m[1])
+
+		content, err := fs.ReadFile(migrationFiles, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", entry.Name(), err)
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = migration
+		} else if migration.Name != m[2] {
+			return nil, fmt.Errorf("migration %04d has two names: %s and %s", version, migration.Name, m[2])
+		}
+
+		if m[3] == "up" {
+			migration.Up = string(content)
+			sum := sha256.Sum256(content)
+			migration.Checksum = hex.EncodeToString(sum[:])
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("migration %04d_%s needs both an up and a down file", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+func appliedMigrations(db *sql.DB) (map[int]appliedMigration, error) {
+	if _, err := db.Exec(migrationTable); err != nil {
+		return nil, fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	rows, err := db.Query(`SELECT version, name, checksum, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int]appliedMigration)
+	for rows.Next() {
+		var version int
+		var a appliedMigration
+		if err := rows.Scan(&version, &a.name, &a.checksum, &a.appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = a
+	}
+	return applied, rows.Err()
+}
+
+// checkApplied refuses to continue if an applied migration was edited or the
+// database is ahead of this build
+func checkApplied(migrations []Migration, applied map[int]appliedMigration) error {
+	known := make(map[int]bool, len(migrations))
+	for _, migration := range migrations {
+		known[migration.Version] = true
+		a, ok := applied[migration.Version]
+		if ok && a.checksum != migration.Checksum {
+			return fmt.Errorf("migration %04d_%s was modified after it was applied; add a new migration instead", migration.Version, migration.Name)
+		}
+	}
+	for version, a := range applied {
+		if !known[version] {
+			return fmt.Errorf("database has migration %04d_%s which this build does not know; upgrade the portal", version, a.name)
+		}
+	}
+	return nil
+}
+
+// adoptBaseline records migration 0001 as applied, without running it, on a
+// database created by InitSchema before migrations existed. Migration 0001 is
+// exactly that schema, so every later migration applies on top of it as usual.
+func adoptBaseline(db *sql.DB, migrations []Migration, applied map[int]appliedMigration) error {
+	if len(applied) > 0 || len(migrations) == 0 || migrations[0].Version != 1 {
+		return nil
+	}
+	exists, err := currentDialect.TableExists(db, "users")
+	if err != nil || !exists {
+		return err
+	}
+
+	baseline := migrations[0]
+	now := time.Now().UTC()
+	_, err = db.Exec(`INSERT INTO schema_migrations (version, name, checksum, applied_at) VALUES (?, ?, ?, ?)`,
+		baseline.Version, baseline.Name, baseline.Checksum, now)
+	if err != nil {
+		return fmt.Errorf("failed to record existing schema as %04d_%s: %w", baseline.Version, baseline.Name, err)
+	}
+	applied[baseline.Version] = appliedMigration{name: baseline.Name, checksum: baseline.Checksum, appliedAt: now}
+	log.Printf("Existing schema predates migrations; recorded it as %04d_%s", baseline.Version, baseline.Name)
+	return nil
+}
+
+// MigrateUp applies every pending migration in order and returns those applied
+func MigrateUp(db *sql.DB) ([]Migration, error) {
+	migrations, err := LoadMigrations(currentDialect)
+	if err != nil {
+		return nil, err
+	}
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkApplied(migrations, applied); err != nil {
+		return nil, err
+	}
+	if err := adoptBaseline(db, migrations, applied); err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for _, migration := range migrations {
+		if _, ok := applied[migration.Version]; ok {
+			continue
+		}
+		if err := runMigration(db, migration, migration.Up, true); err != nil {
+			return done, err
+		}
+		done = append(done, migration)
+	}
+	return done, nil
+}
+
+// MigrateDown rolls back the most recent steps migrations and returns those reverted
+func MigrateDown(db *sql.DB, steps int) ([]Migration, error) {
+	migrations, err := LoadMigrations(currentDialect)
+	if err != nil {
+		return nil, err
+	}
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkApplied(migrations, applied); err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for i := len(migrations) - 1; i >= 0 && len(done) < steps; i-- {
+		migration := migrations[i]
+		if _, ok := applied[migration.Version]; !ok {
+			continue
+		}
+		if err := runMigration(db, migration, migration.Down, false); err != nil {
+			return done, err
+		}
+		done = append(done, migration)
+	}
+	return done, nil
+}
+
+// runMigration executes one direction of a migration and records it. MySQL
+// commits DDL implicitly, so a failure there can leave a partial change that
+// must be repaired by hand; SQLite rolls the whole step back.
+func runMigration(db *sql.DB, migration Migration, script string, up bool) (err error) {
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	restore, err := currentDialect.PrepareMigration(ctx, conn)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if restoreErr := restore(); restoreErr != nil {
+			// Never hand a connection with altered settings back to the pool
+			conn.Raw(func(any) error { return driver.ErrBadConn })
+			if err == nil {
+				err = fmt.Errorf("failed to restore connection after migration %04d_%s: %w", migration.Version, migration.Name, restoreErr)
+			}
+		}
+	}()
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	direction := "down"
+	if up {
+		direction = "up"
+	}
+	if _, err := tx.Exec(script); err != nil {
+		return fmt.Errorf("migration %04d_%s %s failed: %w", migration.Version, migration.Name, direction, err)
+	}
+
+	if up {
+		_, err = tx.Exec(`INSERT INTO schema_migrations (version, name, checksum, applied_at) VALUES (?, ?, ?, ?)`,
+			migration.Version, migration.Name, migration.Checksum, time.Now().UTC())
+	} else {
+		_, err = tx.Exec(`DELETE FROM schema_migrations WHERE version = ?`, migration.Version)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to record migration %04d_%s: %w", migration.Version, migration.Name, err)
+	}
+	if err := currentDialect.CheckMigration(tx); err != nil {
+		return fmt.Errorf("migration %04d_%s %s failed: %w", migration.Version, migration.Name, direction, err)
+	}
+	return tx.Commit()
+}
+
+// MigrationStatus lists every known migration and whether it has been applied
+func MigrationStatus(db *sql.DB) ([]MigrationState, error) {
+	migrations, err := LoadMigrations(currentDialect)
+	if err != nil {
+		return nil, err
+	}
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var states []MigrationState
+	known := make(map[int]bool, len(migrations))
+	for _, migration := range migrations {
+		known[migration.Version] = true
+		state := MigrationState{Version: migration.Version, Name: migration.Name}
+		if a, ok := applied[migration.Version]; ok {
+			state.Applied = true
+			state.AppliedAt = a.appliedAt
+			state.Modified = a.checksum != migration.Checksum
+		}
+		states = append(states, state)
+	}
+	for version, a := range applied {
+		if !known[version] {
+			states = append(states, MigrationState{
+				Version: version, Name: a.name, Applied: true, AppliedAt: a.appliedAt, Unknown: true,
+			})
+		}
+	}
+	sort.Slice(states, func(i, j int) bool {
+		return states[i].Version < states[j].Version
+	})
+	return states, nil
+}
@@ -0,0 +1,163 @@
+package database
+
+import (
+	"database/sql"
+	"io/fs"
+	"path/filepath"
+	"testing"
+)
+
+// openTestSQLite opens an empty SQLite database in a temporary directory
+func openTestSQLite(t *testing.T) *sql.DB {
+	t.Helper()
+	t.Setenv("SQLITE_PATH", filepath.Join(t.TempDir(), "portal.db"))
+
+	dialect := SQLiteDialect{}
+	db, err := sql.Open(dialect.DriverName(), dialect.DSN())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	previous := currentDialect
+	currentDialect = dialect
+	t.Cleanup(func() { currentDialect = previous })
+	return db
+}
+
+func countRows(t *testing.T, db *sql.DB, query string, args ...any) int {
+	t.Helper()
+	var n int
+	if err := db.QueryRow(query, args...).Scan(&n); err != nil {
+		t.Fatalf("%s: %v", query, err)
+	}
+	return n
+}
+
+func TestMigrateUpDownRoundTrip(t *testing.T) {
+	db := openTestSQLite(t)
+
+	migrations, err := LoadMigrations(currentDialect)
+	if err != nil {
+		t.Fatal(err)
+	}
+	applied, err := MigrateUp(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != len(migrations) {
+		t.Fatalf("applied %d of %d migrations", len(applied), len(migrations))
+	}
+	if err := VerifySchema(db); err != nil {
+		t.Fatal(err)
+	}
+
+	reverted, err := MigrateDown(db, len(migrations))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reverted) != len(migrations) {
+		t.Fatalf("reverted %d of %d migrations", len(reverted), len(migrations))
+	}
+	if n := countRows(t, db, `SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name NOT IN ('schema_migrations', 'sqlite_sequence')`); n != 0 {
+		t.Fatalf("%d tables left after rolling everything back", n)
+	}
+
+	if _, err := MigrateUp(db); err != nil {
+		t.Fatalf("re-applying after a full rollback: %v", err)
+	}
+}
+
+func TestMigrateUpAdoptsPreMigrationDatabase(t *testing.T) {
+	db := openTestSQLite(t)
+
+	// A database created by InitSchema before migrations existed: the 0001
+	// schema and some rows, but no schema_migrations table
+	baseline, err := fs.ReadFile(migrationFiles, "migrations/sqlite/0001_initial_schema.up.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(string(baseline)); err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec(`
+INSERT INTO users (username, password_hash, salt, role, email) VALUES
+('faculty', 'hash', 'salt', 'Faculty', 'faculty@example.com'),
+('examcell', 'hash', 'salt', 'ExamCell', 'examcell@example.com');
+INSERT INTO question_papers (title, subject, faculty_id, encrypted_content, encrypted_aes_key, digital_signature, exam_date)
+VALUES ('Midterm', 'Maths', 1, 'content', 'key', 'signature', '2030-01-15');
+INSERT INTO exam_sessions (paper_id, session_name, scheduled_time, duration_minutes, created_by)
+VALUES (1, 'Morning', '2030-01-15 09:00:00', 90, 2);
+INSERT INTO otp_sessions (user_id, otp_code, expires_at) VALUES (1, '123456', '2030-01-01 00:00:00');`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	applied, err := MigrateUp(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) == 0 || applied[0].Version == 1 {
+		t.Fatalf("0001 should be recorded, not run, on an existing schema; applied %v", applied)
+	}
+
+	states, err := MigrationStatus(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, state := range states {
+		if !state.Applied || state.Modified || state.Unknown {
+			t.Errorf("migration %04d_%s: %+v", state.Version, state.Name, state)
+		}
+	}
+
+	// Rebuilt tables keep their rows and the rows that reference them
+	if n := countRows(t, db, `SELECT COUNT(*) FROM question_papers WHERE status = 'pending' AND release_threshold = 0`); n != 1 {
+		t.Errorf("question_papers rows after upgrade = %d, want 1", n)
+	}
+	if n := countRows(t, db, `SELECT COUNT(*) FROM exam_sessions WHERE paper_id = 1`); n != 1 {
+		t.Errorf("exam_sessions rows after upgrade = %d, want 1", n)
+	}
+	if n := countRows(t, db, `SELECT COUNT(*) FROM pragma_foreign_key_check`); n != 0 {
+		t.Errorf("%d foreign key violations after upgrade", n)
+	}
+
+	// Later ENUM values and ACL rows are present
+	if _, err := db.Exec(`UPDATE exam_sessions SET status = 'cancelled'`); err != nil {
+		t.Errorf("cancelling a session after upgrade: %v", err)
+	}
+	if _, err := db.Exec(`UPDATE question_papers SET status = 'rejected'`); err != nil {
+		t.Errorf("rejecting a paper after upgrade: %v", err)
+	}
+	if n := countRows(t, db, `SELECT COUNT(*) FROM users WHERE totp_enabled = FALSE AND totp_secret IS NULL`); n != 2 {
+		t.Errorf("users without TOTP after upgrade = %d, want 2", n)
+	}
+	if n := countRows(t, db, `SELECT COUNT(*) FROM otp_sessions`); n != 0 {
+		t.Errorf("%d plaintext OTP codes survived the upgrade", n)
+	}
+	if _, err := db.Exec(`INSERT INTO otp_sessions (user_id, otp_hash, expires_at, failed_attempts) VALUES (1, 'hash', '2030-01-01 00:00:00', 0)`); err != nil {
+		t.Errorf("storing a hashed OTP after upgrade: %v", err)
+	}
+	if n := countRows(t, db, `SELECT COUNT(*) FROM users WHERE locked_until IS NULL`); n != 2 {
+		t.Errorf("unlocked users after upgrade = %d, want 2", n)
+	}
+	if n := countRows(t, db, `SELECT COUNT(*) FROM access_control WHERE role = 'ExamCell' AND object_type = 'UserAccount' AND can_update = TRUE`); n != 1 {
+		t.Errorf("ExamCell UserAccount ACL rows = %d, want 1", n)
+	}
+	if n := countRows(t, db, `SELECT COUNT(*) FROM access_control WHERE object_type = 'EmailOutbox'`); n != 3 {
+		t.Errorf("EmailOutbox ACL rows = %d, want 3", n)
+	}
+}
+
+func TestMigrateUpRejectsModifiedMigration(t *testing.T) {
+	db := openTestSQLite(t)
+	if _, err := MigrateUp(db); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`UPDATE schema_migrations SET checksum = 'edited' WHERE version = 1`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := MigrateUp(db); err == nil {
+		t.Fatal("MigrateUp accepted an applied migration whose checksum changed")
+	}
+}
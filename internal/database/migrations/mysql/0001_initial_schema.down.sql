@@ -0,0 +1,7 @@
+-- Drops every table created by 0001, children before parents
+DROP TABLE IF EXISTS audit_log;
+DROP TABLE IF EXISTS access_control;
+DROP TABLE IF EXISTS exam_sessions;
+DROP TABLE IF EXISTS question_papers;
+DROP TABLE IF EXISTS otp_sessions;
+DROP TABLE IF EXISTS users;
@@ -1,6 +1,3 @@
-package database
-
-const Schema = `
 -- Users table
 CREATE TABLE IF NOT EXISTS users (
     id INT AUTO_INCREMENT PRIMARY KEY,
@@ -90,9 +87,6 @@ CREATE TABLE IF NOT EXISTS audit_log (
     INDEX idx_user_action (user_id, action),
     INDEX idx_timestamp (timestamp)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
-`
-
-const ACLData = `
 -- Insert default ACL permissions
 
 -- Faculty permissions
@@ -112,4 +106,3 @@ INSERT INTO access_control (role, object_type, can_create, can_read, can_update,
 ('Student', 'QuestionPaper', FALSE, FALSE, FALSE, FALSE, FALSE, FALSE),
 ('Student', 'EncryptionKey', FALSE, FALSE, FALSE, FALSE, FALSE, FALSE),
 ('Student', 'ExamSession', FALSE, TRUE, FALSE, FALSE, FALSE, FALSE);
-`
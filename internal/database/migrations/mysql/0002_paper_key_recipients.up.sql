@@ -0,0 +1,12 @@
+-- Paper AES keys wrapped for each authorised recipient. Papers uploaded before
+-- this keep their single key in question_papers.encrypted_aes_key.
+CREATE TABLE IF NOT EXISTS paper_key_recipients (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    paper_id INT NOT NULL,
+    user_id INT NOT NULL,
+    encrypted_aes_key TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (paper_id) REFERENCES question_papers(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    UNIQUE KEY unique_paper_recipient (paper_id, user_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
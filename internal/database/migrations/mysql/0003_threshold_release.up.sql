@@ -0,0 +1,28 @@
+-- k-of-n release: 0 means any ExamCell recipient can open the paper alone
+ALTER TABLE question_papers ADD COLUMN release_threshold INT NOT NULL DEFAULT 0 AFTER status;
+
+-- Shamir shares of threshold-protected paper keys, one per ExamCell member
+CREATE TABLE IF NOT EXISTS paper_key_shares (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    paper_id INT NOT NULL,
+    user_id INT NOT NULL,
+    encrypted_share TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (paper_id) REFERENCES question_papers(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    UNIQUE KEY unique_paper_share (paper_id, user_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- Submitted key shares, re-wrapped for each share holder
+CREATE TABLE IF NOT EXISTS paper_share_submissions (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    paper_id INT NOT NULL,
+    submitted_by INT NOT NULL,
+    recipient_id INT NOT NULL,
+    encrypted_share TEXT NOT NULL,
+    submitted_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (paper_id) REFERENCES question_papers(id) ON DELETE CASCADE,
+    FOREIGN KEY (submitted_by) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (recipient_id) REFERENCES users(id) ON DELETE CASCADE,
+    UNIQUE KEY unique_share_submission (paper_id, submitted_by, recipient_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,16 @@
+-- Emergency early-decryption overrides (two-person rule)
+CREATE TABLE IF NOT EXISTS decryption_overrides (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    paper_id INT NOT NULL,
+    requested_by INT NOT NULL,
+    approved_by INT,
+    reason TEXT NOT NULL,
+    requested_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    approved_at DATETIME,
+    expires_at DATETIME,
+    used_at DATETIME,
+    FOREIGN KEY (paper_id) REFERENCES question_papers(id) ON DELETE CASCADE,
+    FOREIGN KEY (requested_by) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (approved_by) REFERENCES users(id) ON DELETE CASCADE,
+    INDEX idx_override_paper (paper_id, requested_by)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,9 @@
+-- Revisions stay as papers of their own; only the links are lost
+UPDATE access_control SET can_update = FALSE WHERE role = 'Faculty' AND object_type = 'QuestionPaper';
+DROP TABLE IF EXISTS paper_revisions;
+
+-- Papers under review or rejected go back to pending
+DROP TABLE IF EXISTS paper_status_history;
+UPDATE question_papers SET status = 'pending' WHERE status IN ('in_review', 'rejected');
+ALTER TABLE question_papers
+    MODIFY COLUMN status ENUM('pending', 'approved', 'published') DEFAULT 'pending';
@@ -0,0 +1,30 @@
+-- Papers go through review before approval and can be sent back
+ALTER TABLE question_papers
+    MODIFY COLUMN status ENUM('pending', 'in_review', 'approved', 'rejected', 'published') DEFAULT 'pending';
+
+-- Paper review and publication history
+CREATE TABLE IF NOT EXISTS paper_status_history (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    paper_id INT NOT NULL,
+    from_status VARCHAR(20),
+    to_status VARCHAR(20) NOT NULL,
+    changed_by INT NOT NULL,
+    comments TEXT,
+    changed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (paper_id) REFERENCES question_papers(id) ON DELETE CASCADE,
+    FOREIGN KEY (changed_by) REFERENCES users(id) ON DELETE CASCADE,
+    INDEX idx_history_paper (paper_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- A rejected paper is resubmitted by uploading its revision, which goes
+-- through review as a new paper linked to the one it replaces. Faculty may
+-- update (resubmit) their own papers; reviewing now takes decrypt permission.
+CREATE TABLE IF NOT EXISTS paper_revisions (
+    paper_id INT PRIMARY KEY,
+    revision_of INT NOT NULL UNIQUE,
+    created_at TIMESTAMP NOT NULL,
+    FOREIGN KEY (paper_id) REFERENCES question_papers(id) ON DELETE CASCADE,
+    FOREIGN KEY (revision_of) REFERENCES question_papers(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+UPDATE access_control SET can_update = TRUE WHERE role = 'Faculty' AND object_type = 'QuestionPaper';
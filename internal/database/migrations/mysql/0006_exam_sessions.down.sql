@@ -0,0 +1,7 @@
+-- Pending requests are lost; approved changes stay applied
+DROP TABLE IF EXISTS session_schedule_requests;
+
+-- Cancelled sessions are removed so they cannot release their paper
+DELETE FROM exam_sessions WHERE status = 'cancelled';
+ALTER TABLE exam_sessions
+    MODIFY COLUMN status ENUM('scheduled', 'active', 'completed') DEFAULT 'scheduled';
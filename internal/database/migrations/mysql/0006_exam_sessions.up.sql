@@ -0,0 +1,25 @@
+-- Sessions can be cancelled; cancelled sessions no longer set a release time
+ALTER TABLE exam_sessions
+    MODIFY COLUMN status ENUM('scheduled', 'active', 'completed', 'cancelled') DEFAULT 'scheduled';
+
+-- Session changes that would open a paper earlier than its current release
+-- time or its exam date, held until a second ExamCell member approves them.
+-- session_id is NULL for a new session.
+CREATE TABLE IF NOT EXISTS session_schedule_requests (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    paper_id INT NOT NULL,
+    session_id INT NULL,
+    session_name VARCHAR(100) NOT NULL,
+    scheduled_time DATETIME NOT NULL,
+    duration_minutes INT NOT NULL,
+    reason TEXT NOT NULL,
+    requested_by INT NOT NULL,
+    requested_at TIMESTAMP NOT NULL,
+    approved_by INT NULL,
+    approved_at TIMESTAMP NULL,
+    FOREIGN KEY (paper_id) REFERENCES question_papers(id) ON DELETE CASCADE,
+    FOREIGN KEY (session_id) REFERENCES exam_sessions(id) ON DELETE CASCADE,
+    FOREIGN KEY (requested_by) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (approved_by) REFERENCES users(id) ON DELETE CASCADE,
+    INDEX idx_schedule_pending (approved_by, requested_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,13 @@
+-- Login sessions issued after MFA
+CREATE TABLE IF NOT EXISTS user_sessions (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    user_id INT NOT NULL,
+    token_hash CHAR(64) UNIQUE NOT NULL,
+    source VARCHAR(100),
+    created_at DATETIME NOT NULL,
+    expires_at DATETIME NOT NULL,
+    last_seen_at DATETIME NOT NULL,
+    revoked_at DATETIME,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    INDEX idx_user_sessions (user_id, revoked_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
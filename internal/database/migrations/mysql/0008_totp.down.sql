@@ -0,0 +1,5 @@
+-- Users enrolled in TOTP fall back to emailed OTPs
+ALTER TABLE users
+    DROP COLUMN totp_last_step,
+    DROP COLUMN totp_enabled,
+    DROP COLUMN totp_secret;
@@ -0,0 +1,6 @@
+-- Authenticator-app MFA. The secret is set at enrolment and only trusted once
+-- totp_enabled is set; totp_last_step stops a code being replayed.
+ALTER TABLE users
+    ADD COLUMN totp_secret VARCHAR(64) NULL AFTER private_key_encrypted,
+    ADD COLUMN totp_enabled BOOLEAN DEFAULT FALSE AFTER totp_secret,
+    ADD COLUMN totp_last_step BIGINT NULL AFTER totp_enabled;
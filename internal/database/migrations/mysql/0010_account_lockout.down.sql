@@ -0,0 +1,6 @@
+-- Locked accounts are unlocked and the attempt history is lost
+DELETE FROM access_control WHERE object_type = 'UserAccount';
+ALTER TABLE access_control
+    MODIFY COLUMN object_type ENUM('QuestionPaper', 'EncryptionKey', 'ExamSession') NOT NULL;
+DROP TABLE IF EXISTS login_attempts;
+ALTER TABLE users DROP COLUMN locked_until;
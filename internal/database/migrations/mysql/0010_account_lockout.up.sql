@@ -0,0 +1,26 @@
+-- Accounts locked after repeated failed logins, until the time set here or an
+-- ExamCell unlock
+ALTER TABLE users ADD COLUMN locked_until TIMESTAMP NULL AFTER totp_last_step;
+
+-- Password and OTP attempts, for back-off and lockout
+CREATE TABLE IF NOT EXISTS login_attempts (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    username VARCHAR(50) NOT NULL,
+    user_id INT NULL,
+    source VARCHAR(100) NOT NULL,
+    attempt_type ENUM('password', 'otp', 'unlock') NOT NULL,
+    success BOOLEAN NOT NULL,
+    attempted_at TIMESTAMP NOT NULL,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    INDEX idx_attempt_username (username, attempted_at),
+    INDEX idx_attempt_source (source, attempted_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- Only the Exam Cell can list and unlock accounts
+ALTER TABLE access_control
+    MODIFY COLUMN object_type ENUM('QuestionPaper', 'EncryptionKey', 'ExamSession', 'UserAccount') NOT NULL;
+
+INSERT IGNORE INTO access_control (role, object_type, can_create, can_read, can_update, can_delete, can_encrypt, can_decrypt) VALUES
+('Faculty', 'UserAccount', FALSE, FALSE, FALSE, FALSE, FALSE, FALSE),
+('ExamCell', 'UserAccount', FALSE, TRUE, TRUE, FALSE, FALSE, FALSE),
+('Student', 'UserAccount', FALSE, FALSE, FALSE, FALSE, FALSE, FALSE);
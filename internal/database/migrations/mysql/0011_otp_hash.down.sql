@@ -0,0 +1,5 @@
+-- Hashed codes cannot be turned back into plaintext, so they are cleared
+DELETE FROM otp_sessions;
+ALTER TABLE otp_sessions
+    DROP COLUMN failed_attempts,
+    CHANGE COLUMN otp_hash otp_code VARCHAR(6) NOT NULL;
@@ -0,0 +1,7 @@
+-- OTP codes are stored as keyed HMACs. Codes already issued were stored in
+-- plaintext and cannot be hashed here without the key, so they are cleared;
+-- anyone mid-login requests a new one.
+DELETE FROM otp_sessions;
+ALTER TABLE otp_sessions
+    CHANGE COLUMN otp_code otp_hash CHAR(64) NOT NULL,
+    ADD COLUMN failed_attempts INT NOT NULL DEFAULT 0 AFTER is_used;
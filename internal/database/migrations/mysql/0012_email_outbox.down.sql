@@ -0,0 +1,5 @@
+-- Queued mail that was never sent is lost
+DELETE FROM access_control WHERE object_type = 'EmailOutbox';
+ALTER TABLE access_control
+    MODIFY COLUMN object_type ENUM('QuestionPaper', 'EncryptionKey', 'ExamSession', 'UserAccount') NOT NULL;
+DROP TABLE IF EXISTS email_outbox;
@@ -0,0 +1,24 @@
+-- Outbound email queue, drained by the background worker
+CREATE TABLE IF NOT EXISTS email_outbox (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    recipient VARCHAR(100) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    body TEXT NOT NULL,
+    template VARCHAR(50),
+    status ENUM('pending', 'sent', 'dead') NOT NULL DEFAULT 'pending',
+    attempts INT NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP NOT NULL,
+    last_error TEXT,
+    created_at TIMESTAMP NOT NULL,
+    sent_at TIMESTAMP NULL,
+    INDEX idx_outbox_due (status, next_attempt_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- Only the Exam Cell can inspect and retry the outbox
+ALTER TABLE access_control
+    MODIFY COLUMN object_type ENUM('QuestionPaper', 'EncryptionKey', 'ExamSession', 'UserAccount', 'EmailOutbox') NOT NULL;
+
+INSERT IGNORE INTO access_control (role, object_type, can_create, can_read, can_update, can_delete, can_encrypt, can_decrypt) VALUES
+('Faculty', 'EmailOutbox', FALSE, FALSE, FALSE, FALSE, FALSE, FALSE),
+('ExamCell', 'EmailOutbox', FALSE, TRUE, FALSE, FALSE, FALSE, FALSE),
+('Student', 'EmailOutbox', FALSE, FALSE, FALSE, FALSE, FALSE, FALSE);
@@ -0,0 +1,6 @@
+-- Paper ciphertext moves to the blob store; the row keeps its ref, size and hash.
+-- encrypted_content is left empty for papers stored as blobs.
+ALTER TABLE question_papers
+    ADD COLUMN blob_ref VARCHAR(80) NULL AFTER encrypted_content,
+    ADD COLUMN blob_size BIGINT NULL AFTER blob_ref,
+    ADD COLUMN blob_sha256 CHAR(64) NULL AFTER blob_size;
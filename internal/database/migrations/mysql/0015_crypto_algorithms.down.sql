@@ -0,0 +1,5 @@
+-- Rows already upgraded to OAEP/PSS become unreadable by older builds
+ALTER TABLE question_papers DROP COLUMN signature_alg;
+ALTER TABLE paper_share_submissions DROP COLUMN key_wrap;
+ALTER TABLE paper_key_shares DROP COLUMN key_wrap;
+ALTER TABLE paper_key_recipients DROP COLUMN key_wrap;
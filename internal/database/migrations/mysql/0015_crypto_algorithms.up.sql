@@ -0,0 +1,10 @@
+-- Record the RSA algorithm behind every wrapped key and signature so OAEP/PSS
+-- can replace PKCS#1 v1.5 while existing rows stay readable
+ALTER TABLE paper_key_recipients
+    ADD COLUMN key_wrap VARCHAR(32) NOT NULL DEFAULT 'rsa-pkcs1v15' AFTER encrypted_aes_key;
+ALTER TABLE paper_key_shares
+    ADD COLUMN key_wrap VARCHAR(32) NOT NULL DEFAULT 'rsa-pkcs1v15' AFTER encrypted_share;
+ALTER TABLE paper_share_submissions
+    ADD COLUMN key_wrap VARCHAR(32) NOT NULL DEFAULT 'rsa-pkcs1v15' AFTER encrypted_share;
+ALTER TABLE question_papers
+    ADD COLUMN signature_alg VARCHAR(32) NOT NULL DEFAULT 'rsa-pkcs1v15-sha256' AFTER digital_signature;
@@ -0,0 +1,2 @@
+-- Users with non-RSA keys cannot log in on older builds
+ALTER TABLE users DROP COLUMN key_algorithm;
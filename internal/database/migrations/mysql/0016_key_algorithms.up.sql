@@ -0,0 +1,8 @@
+-- Name the key suite behind every user's key pair. Accounts created before
+-- key suites hold RSA-2048 keys; anything this cannot classify (a larger RSA
+-- key, after rolling back and re-applying) is recorded at the next login.
+ALTER TABLE users ADD COLUMN key_algorithm VARCHAR(32) NULL AFTER private_key_encrypted;
+UPDATE users SET key_algorithm = 'x25519-ed25519'
+    WHERE public_key LIKE '-----BEGIN X25519-ED25519 PUBLIC KEY-----%';
+UPDATE users SET key_algorithm = 'rsa-2048'
+    WHERE public_key LIKE '-----BEGIN RSA PUBLIC KEY-----%' AND LENGTH(public_key) < 600;
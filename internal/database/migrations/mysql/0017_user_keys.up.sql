@@ -0,0 +1,24 @@
+-- Every key pair a user has held. The row matching users.public_key is
+-- 'active'; rotated-out keys stay as 'retired' so their signatures still
+-- verify. Only public halves are kept: retired private keys are discarded.
+CREATE TABLE IF NOT EXISTS user_keys (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    user_id INT NOT NULL,
+    public_key TEXT NOT NULL,
+    key_algorithm VARCHAR(32) NULL,
+    status ENUM('active', 'retired') NOT NULL DEFAULT 'active',
+    created_at TIMESTAMP NOT NULL,
+    retired_at TIMESTAMP NULL,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    INDEX idx_user_keys (user_id, status)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+INSERT INTO user_keys (user_id, public_key, key_algorithm, status, created_at)
+    SELECT id, public_key, key_algorithm, 'active', COALESCE(created_at, CURRENT_TIMESTAMP)
+    FROM users WHERE public_key IS NOT NULL AND public_key <> '';
+
+-- The key a paper was signed with; user_keys rows live as long as their user
+ALTER TABLE question_papers ADD COLUMN signer_key_id INT NULL AFTER signature_alg;
+UPDATE question_papers qp
+    JOIN user_keys k ON k.user_id = qp.faculty_id AND k.status = 'active'
+    SET qp.signer_key_id = k.id;
@@ -0,0 +1,6 @@
+-- Compromise reports are lost; re-signed and re-encrypted papers stay as they are
+DROP TABLE IF EXISTS paper_reencryption_queue;
+ALTER TABLE user_keys
+    DROP COLUMN compromise_reason,
+    DROP COLUMN compromised_by,
+    DROP COLUMN compromised_at;
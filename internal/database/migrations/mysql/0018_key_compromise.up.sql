@@ -0,0 +1,24 @@
+-- A key reported compromised by the Exam Cell. Papers it signed are refused
+-- until re-signed; papers whose AES key was wrapped to it are re-encrypted.
+ALTER TABLE user_keys
+    ADD COLUMN compromised_at TIMESTAMP NULL AFTER retired_at,
+    ADD COLUMN compromised_by INT NULL AFTER compromised_at,
+    ADD COLUMN compromise_reason TEXT NULL AFTER compromised_by;
+
+-- Papers waiting to be re-encrypted under a fresh AES key
+CREATE TABLE IF NOT EXISTS paper_reencryption_queue (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    paper_id INT NOT NULL,
+    key_id INT NOT NULL,
+    status ENUM('pending', 'done') NOT NULL DEFAULT 'pending',
+    requested_by INT NOT NULL,
+    requested_at TIMESTAMP NOT NULL,
+    completed_by INT NULL,
+    completed_at TIMESTAMP NULL,
+    FOREIGN KEY (paper_id) REFERENCES question_papers(id) ON DELETE CASCADE,
+    FOREIGN KEY (key_id) REFERENCES user_keys(id) ON DELETE CASCADE,
+    FOREIGN KEY (requested_by) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (completed_by) REFERENCES users(id) ON DELETE SET NULL,
+    UNIQUE KEY unique_paper_key (paper_id, key_id),
+    INDEX idx_reencryption_status (status, paper_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
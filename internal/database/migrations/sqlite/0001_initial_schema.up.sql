@@ -0,0 +1,103 @@
+-- Users table
+CREATE TABLE IF NOT EXISTS users (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    username VARCHAR(50) UNIQUE NOT NULL,
+    password_hash VARCHAR(255) NOT NULL,
+    salt VARCHAR(64) NOT NULL,
+    role TEXT NOT NULL CHECK (role IN ('Faculty', 'ExamCell', 'Student')),
+    email VARCHAR(100) UNIQUE NOT NULL,
+    public_key TEXT,
+    private_key_encrypted TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_role ON users (role);
+
+-- OTP sessions table
+CREATE TABLE IF NOT EXISTS otp_sessions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    otp_code VARCHAR(6) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL,
+    is_used BOOLEAN DEFAULT FALSE
+);
+CREATE INDEX IF NOT EXISTS idx_user_otp ON otp_sessions (user_id, is_used);
+
+-- Question papers table
+CREATE TABLE IF NOT EXISTS question_papers (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    title VARCHAR(200) NOT NULL,
+    subject VARCHAR(100) NOT NULL,
+    faculty_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    encrypted_content TEXT NOT NULL,
+    encrypted_aes_key TEXT NOT NULL,
+    digital_signature TEXT NOT NULL,
+    upload_date TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    exam_date DATE,
+    status TEXT DEFAULT 'pending' CHECK (status IN ('pending', 'approved', 'published'))
+);
+CREATE INDEX IF NOT EXISTS idx_faculty ON question_papers (faculty_id);
+CREATE INDEX IF NOT EXISTS idx_paper_status ON question_papers (status);
+
+-- Exam sessions table
+CREATE TABLE IF NOT EXISTS exam_sessions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    paper_id INTEGER NOT NULL REFERENCES question_papers(id) ON DELETE CASCADE,
+    session_name VARCHAR(100) NOT NULL,
+    scheduled_time DATETIME NOT NULL,
+    duration_minutes INTEGER NOT NULL,
+    status TEXT DEFAULT 'scheduled' CHECK (status IN ('scheduled', 'active', 'completed')),
+    created_by INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_paper ON exam_sessions (paper_id);
+CREATE INDEX IF NOT EXISTS idx_session_status ON exam_sessions (status);
+
+-- Access control list
+CREATE TABLE IF NOT EXISTS access_control (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    role TEXT NOT NULL CHECK (role IN ('Faculty', 'ExamCell', 'Student')),
+    object_type TEXT NOT NULL CHECK (object_type IN ('QuestionPaper', 'EncryptionKey', 'ExamSession')),
+    can_create BOOLEAN DEFAULT FALSE,
+    can_read BOOLEAN DEFAULT FALSE,
+    can_update BOOLEAN DEFAULT FALSE,
+    can_delete BOOLEAN DEFAULT FALSE,
+    can_encrypt BOOLEAN DEFAULT FALSE,
+    can_decrypt BOOLEAN DEFAULT FALSE,
+    UNIQUE (role, object_type)
+);
+
+-- Audit log
+CREATE TABLE IF NOT EXISTS audit_log (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    action VARCHAR(100) NOT NULL,
+    object_type VARCHAR(50) NOT NULL,
+    object_id INTEGER,
+    timestamp TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    ip_address VARCHAR(45),
+    success BOOLEAN NOT NULL,
+    details TEXT
+);
+CREATE INDEX IF NOT EXISTS idx_user_action ON audit_log (user_id, action);
+CREATE INDEX IF NOT EXISTS idx_timestamp ON audit_log (timestamp);
+
+-- Insert default ACL permissions
+
+-- Faculty permissions
+INSERT INTO access_control (role, object_type, can_create, can_read, can_update, can_delete, can_encrypt, can_decrypt) VALUES
+('Faculty', 'QuestionPaper', TRUE, TRUE, FALSE, FALSE, TRUE, FALSE),
+('Faculty', 'EncryptionKey', TRUE, FALSE, FALSE, FALSE, FALSE, FALSE),
+('Faculty', 'ExamSession', FALSE, TRUE, FALSE, FALSE, FALSE, FALSE);
+
+-- ExamCell permissions
+INSERT INTO access_control (role, object_type, can_create, can_read, can_update, can_delete, can_encrypt, can_decrypt) VALUES
+('ExamCell', 'QuestionPaper', FALSE, TRUE, TRUE, FALSE, FALSE, TRUE),
+('ExamCell', 'EncryptionKey', FALSE, FALSE, FALSE, FALSE, FALSE, TRUE),
+('ExamCell', 'ExamSession', TRUE, TRUE, TRUE, TRUE, FALSE, FALSE);
+
+-- Student permissions (very limited)
+INSERT INTO access_control (role, object_type, can_create, can_read, can_update, can_delete, can_encrypt, can_decrypt) VALUES
+('Student', 'QuestionPaper', FALSE, FALSE, FALSE, FALSE, FALSE, FALSE),
+('Student', 'EncryptionKey', FALSE, FALSE, FALSE, FALSE, FALSE, FALSE),
+('Student', 'ExamSession', FALSE, TRUE, FALSE, FALSE, FALSE, FALSE);
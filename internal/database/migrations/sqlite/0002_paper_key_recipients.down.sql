@@ -0,0 +1,2 @@
+-- Papers uploaded after 0002 have no legacy key and become unreadable
+DROP TABLE IF EXISTS paper_key_recipients;
@@ -0,0 +1,10 @@
+-- Paper AES keys wrapped for each authorised recipient. Papers uploaded before
+-- this keep their single key in question_papers.encrypted_aes_key.
+CREATE TABLE IF NOT EXISTS paper_key_recipients (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    paper_id INTEGER NOT NULL REFERENCES question_papers(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    encrypted_aes_key TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (paper_id, user_id)
+);
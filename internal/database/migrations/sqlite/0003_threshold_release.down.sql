@@ -0,0 +1,4 @@
+-- Threshold-protected papers have no recipient keys and become unreadable
+DROP TABLE IF EXISTS paper_share_submissions;
+DROP TABLE IF EXISTS paper_key_shares;
+ALTER TABLE question_papers DROP COLUMN release_threshold;
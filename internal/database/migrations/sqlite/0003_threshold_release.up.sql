@@ -0,0 +1,23 @@
+-- k-of-n release: 0 means any ExamCell recipient can open the paper alone
+ALTER TABLE question_papers ADD COLUMN release_threshold INTEGER NOT NULL DEFAULT 0;
+
+-- Shamir shares of threshold-protected paper keys, one per ExamCell member
+CREATE TABLE IF NOT EXISTS paper_key_shares (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    paper_id INTEGER NOT NULL REFERENCES question_papers(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    encrypted_share TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (paper_id, user_id)
+);
+
+-- Submitted key shares, re-wrapped for each share holder
+CREATE TABLE IF NOT EXISTS paper_share_submissions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    paper_id INTEGER NOT NULL REFERENCES question_papers(id) ON DELETE CASCADE,
+    submitted_by INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    recipient_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    encrypted_share TEXT NOT NULL,
+    submitted_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (paper_id, submitted_by, recipient_id)
+);
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS decryption_overrides;
@@ -0,0 +1,13 @@
+-- Emergency early-decryption overrides (two-person rule)
+CREATE TABLE IF NOT EXISTS decryption_overrides (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    paper_id INTEGER NOT NULL REFERENCES question_papers(id) ON DELETE CASCADE,
+    requested_by INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    approved_by INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    reason TEXT NOT NULL,
+    requested_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    approved_at DATETIME,
+    expires_at DATETIME,
+    used_at DATETIME
+);
+CREATE INDEX IF NOT EXISTS idx_override_paper ON decryption_overrides (paper_id, requested_by);
@@ -0,0 +1,29 @@
+-- Revisions stay as papers of their own; only the links are lost
+UPDATE access_control SET can_update = FALSE WHERE role = 'Faculty' AND object_type = 'QuestionPaper';
+DROP TABLE IF EXISTS paper_revisions;
+
+-- Papers under review or rejected go back to pending
+DROP TABLE IF EXISTS paper_status_history;
+UPDATE question_papers SET status = 'pending' WHERE status IN ('in_review', 'rejected');
+
+CREATE TABLE question_papers_old (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    title VARCHAR(200) NOT NULL,
+    subject VARCHAR(100) NOT NULL,
+    faculty_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    encrypted_content TEXT NOT NULL,
+    encrypted_aes_key TEXT NOT NULL,
+    digital_signature TEXT NOT NULL,
+    upload_date TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    exam_date DATE,
+    status TEXT DEFAULT 'pending' CHECK (status IN ('pending', 'approved', 'published')),
+    release_threshold INTEGER NOT NULL DEFAULT 0
+);
+INSERT INTO question_papers_old
+    SELECT id, title, subject, faculty_id, encrypted_content, encrypted_aes_key, digital_signature,
+        upload_date, exam_date, status, release_threshold
+    FROM question_papers;
+DROP TABLE question_papers;
+ALTER TABLE question_papers_old RENAME TO question_papers;
+CREATE INDEX IF NOT EXISTS idx_faculty ON question_papers (faculty_id);
+CREATE INDEX IF NOT EXISTS idx_paper_status ON question_papers (status);
@@ -0,0 +1,47 @@
+-- Papers go through review before approval and can be sent back. SQLite cannot
+-- alter a CHECK constraint, so the table is rebuilt; the runner turns foreign
+-- keys off for the step so the rows that reference papers are left alone.
+CREATE TABLE question_papers_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    title VARCHAR(200) NOT NULL,
+    subject VARCHAR(100) NOT NULL,
+    faculty_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    encrypted_content TEXT NOT NULL,
+    encrypted_aes_key TEXT NOT NULL,
+    digital_signature TEXT NOT NULL,
+    upload_date TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    exam_date DATE,
+    status TEXT DEFAULT 'pending' CHECK (status IN ('pending', 'in_review', 'approved', 'rejected', 'published')),
+    release_threshold INTEGER NOT NULL DEFAULT 0
+);
+INSERT INTO question_papers_new
+    SELECT id, title, subject, faculty_id, encrypted_content, encrypted_aes_key, digital_signature,
+        upload_date, exam_date, status, release_threshold
+    FROM question_papers;
+DROP TABLE question_papers;
+ALTER TABLE question_papers_new RENAME TO question_papers;
+CREATE INDEX IF NOT EXISTS idx_faculty ON question_papers (faculty_id);
+CREATE INDEX IF NOT EXISTS idx_paper_status ON question_papers (status);
+
+-- Paper review and publication history
+CREATE TABLE IF NOT EXISTS paper_status_history (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    paper_id INTEGER NOT NULL REFERENCES question_papers(id) ON DELETE CASCADE,
+    from_status VARCHAR(20),
+    to_status VARCHAR(20) NOT NULL,
+    changed_by INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    comments TEXT,
+    changed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_history_paper ON paper_status_history (paper_id);
+
+-- A rejected paper is resubmitted by uploading its revision, which goes
+-- through review as a new paper linked to the one it replaces. Faculty may
+-- update (resubmit) their own papers; reviewing now takes decrypt permission.
+CREATE TABLE IF NOT EXISTS paper_revisions (
+    paper_id INTEGER PRIMARY KEY REFERENCES question_papers(id) ON DELETE CASCADE,
+    revision_of INTEGER NOT NULL UNIQUE REFERENCES question_papers(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL
+);
+
+UPDATE access_control SET can_update = TRUE WHERE role = 'Faculty' AND object_type = 'QuestionPaper';
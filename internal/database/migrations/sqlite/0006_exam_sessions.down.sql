@@ -0,0 +1,23 @@
+-- Pending requests are lost; approved changes stay applied
+DROP TABLE IF EXISTS session_schedule_requests;
+
+-- Cancelled sessions are removed so they cannot release their paper
+DELETE FROM exam_sessions WHERE status = 'cancelled';
+
+CREATE TABLE exam_sessions_old (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    paper_id INTEGER NOT NULL REFERENCES question_papers(id) ON DELETE CASCADE,
+    session_name VARCHAR(100) NOT NULL,
+    scheduled_time DATETIME NOT NULL,
+    duration_minutes INTEGER NOT NULL,
+    status TEXT DEFAULT 'scheduled' CHECK (status IN ('scheduled', 'active', 'completed')),
+    created_by INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+INSERT INTO exam_sessions_old
+    SELECT id, paper_id, session_name, scheduled_time, duration_minutes, status, created_by, created_at
+    FROM exam_sessions;
+DROP TABLE exam_sessions;
+ALTER TABLE exam_sessions_old RENAME TO exam_sessions;
+CREATE INDEX IF NOT EXISTS idx_paper ON exam_sessions (paper_id);
+CREATE INDEX IF NOT EXISTS idx_session_status ON exam_sessions (status);
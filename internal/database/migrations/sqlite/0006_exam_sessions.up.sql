@@ -0,0 +1,37 @@
+-- Sessions can be cancelled; cancelled sessions no longer set a release time.
+-- Rebuilt to widen the CHECK constraint.
+CREATE TABLE exam_sessions_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    paper_id INTEGER NOT NULL REFERENCES question_papers(id) ON DELETE CASCADE,
+    session_name VARCHAR(100) NOT NULL,
+    scheduled_time DATETIME NOT NULL,
+    duration_minutes INTEGER NOT NULL,
+    status TEXT DEFAULT 'scheduled' CHECK (status IN ('scheduled', 'active', 'completed', 'cancelled')),
+    created_by INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+INSERT INTO exam_sessions_new
+    SELECT id, paper_id, session_name, scheduled_time, duration_minutes, status, created_by, created_at
+    FROM exam_sessions;
+DROP TABLE exam_sessions;
+ALTER TABLE exam_sessions_new RENAME TO exam_sessions;
+CREATE INDEX IF NOT EXISTS idx_paper ON exam_sessions (paper_id);
+CREATE INDEX IF NOT EXISTS idx_session_status ON exam_sessions (status);
+
+-- Session changes that would open a paper earlier than its current release
+-- time or its exam date, held until a second ExamCell member approves them.
+-- session_id is NULL for a new session.
+CREATE TABLE IF NOT EXISTS session_schedule_requests (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    paper_id INTEGER NOT NULL REFERENCES question_papers(id) ON DELETE CASCADE,
+    session_id INTEGER NULL REFERENCES exam_sessions(id) ON DELETE CASCADE,
+    session_name VARCHAR(100) NOT NULL,
+    scheduled_time DATETIME NOT NULL,
+    duration_minutes INTEGER NOT NULL,
+    reason TEXT NOT NULL,
+    requested_by INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    requested_at TIMESTAMP NOT NULL,
+    approved_by INTEGER NULL REFERENCES users(id) ON DELETE CASCADE,
+    approved_at TIMESTAMP NULL
+);
+CREATE INDEX IF NOT EXISTS idx_schedule_pending ON session_schedule_requests (approved_by, requested_at);
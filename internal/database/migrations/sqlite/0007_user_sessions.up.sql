@@ -0,0 +1,12 @@
+-- Login sessions issued after MFA
+CREATE TABLE IF NOT EXISTS user_sessions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    token_hash CHAR(64) UNIQUE NOT NULL,
+    source VARCHAR(100),
+    created_at DATETIME NOT NULL,
+    expires_at DATETIME NOT NULL,
+    last_seen_at DATETIME NOT NULL,
+    revoked_at DATETIME
+);
+CREATE INDEX IF NOT EXISTS idx_user_sessions ON user_sessions (user_id, revoked_at);
@@ -0,0 +1,4 @@
+-- Users enrolled in TOTP fall back to emailed OTPs
+ALTER TABLE users DROP COLUMN totp_last_step;
+ALTER TABLE users DROP COLUMN totp_enabled;
+ALTER TABLE users DROP COLUMN totp_secret;
@@ -0,0 +1,5 @@
+-- Authenticator-app MFA. The secret is set at enrolment and only trusted once
+-- totp_enabled is set; totp_last_step stops a code being replayed.
+ALTER TABLE users ADD COLUMN totp_secret VARCHAR(64);
+ALTER TABLE users ADD COLUMN totp_enabled BOOLEAN DEFAULT FALSE;
+ALTER TABLE users ADD COLUMN totp_last_step BIGINT;
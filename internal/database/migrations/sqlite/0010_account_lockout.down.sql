@@ -0,0 +1,21 @@
+-- Locked accounts are unlocked and the attempt history is lost
+DELETE FROM access_control WHERE object_type = 'UserAccount';
+
+CREATE TABLE access_control_old (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    role TEXT NOT NULL CHECK (role IN ('Faculty', 'ExamCell', 'Student')),
+    object_type TEXT NOT NULL CHECK (object_type IN ('QuestionPaper', 'EncryptionKey', 'ExamSession')),
+    can_create BOOLEAN DEFAULT FALSE,
+    can_read BOOLEAN DEFAULT FALSE,
+    can_update BOOLEAN DEFAULT FALSE,
+    can_delete BOOLEAN DEFAULT FALSE,
+    can_encrypt BOOLEAN DEFAULT FALSE,
+    can_decrypt BOOLEAN DEFAULT FALSE,
+    UNIQUE (role, object_type)
+);
+INSERT INTO access_control_old SELECT * FROM access_control;
+DROP TABLE access_control;
+ALTER TABLE access_control_old RENAME TO access_control;
+
+DROP TABLE IF EXISTS login_attempts;
+ALTER TABLE users DROP COLUMN locked_until;
@@ -0,0 +1,39 @@
+-- Accounts locked after repeated failed logins, until the time set here or an
+-- ExamCell unlock
+ALTER TABLE users ADD COLUMN locked_until TIMESTAMP NULL;
+
+-- Password and OTP attempts, for back-off and lockout
+CREATE TABLE IF NOT EXISTS login_attempts (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    username VARCHAR(50) NOT NULL,
+    user_id INTEGER NULL REFERENCES users(id) ON DELETE CASCADE,
+    source VARCHAR(100) NOT NULL,
+    attempt_type TEXT NOT NULL CHECK (attempt_type IN ('password', 'otp', 'unlock')),
+    success BOOLEAN NOT NULL,
+    attempted_at TIMESTAMP NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_attempt_username ON login_attempts (username, attempted_at);
+CREATE INDEX IF NOT EXISTS idx_attempt_source ON login_attempts (source, attempted_at);
+
+-- Only the Exam Cell can list and unlock accounts. Rebuilt to widen the CHECK
+-- constraint.
+CREATE TABLE access_control_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    role TEXT NOT NULL CHECK (role IN ('Faculty', 'ExamCell', 'Student')),
+    object_type TEXT NOT NULL CHECK (object_type IN ('QuestionPaper', 'EncryptionKey', 'ExamSession', 'UserAccount')),
+    can_create BOOLEAN DEFAULT FALSE,
+    can_read BOOLEAN DEFAULT FALSE,
+    can_update BOOLEAN DEFAULT FALSE,
+    can_delete BOOLEAN DEFAULT FALSE,
+    can_encrypt BOOLEAN DEFAULT FALSE,
+    can_decrypt BOOLEAN DEFAULT FALSE,
+    UNIQUE (role, object_type)
+);
+INSERT INTO access_control_new SELECT * FROM access_control;
+DROP TABLE access_control;
+ALTER TABLE access_control_new RENAME TO access_control;
+
+INSERT OR IGNORE INTO access_control (role, object_type, can_create, can_read, can_update, can_delete, can_encrypt, can_decrypt) VALUES
+('Faculty', 'UserAccount', FALSE, FALSE, FALSE, FALSE, FALSE, FALSE),
+('ExamCell', 'UserAccount', FALSE, TRUE, TRUE, FALSE, FALSE, FALSE),
+('Student', 'UserAccount', FALSE, FALSE, FALSE, FALSE, FALSE, FALSE);
@@ -0,0 +1,11 @@
+-- Hashed codes cannot be turned back into plaintext, so they are cleared
+DROP TABLE otp_sessions;
+CREATE TABLE otp_sessions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    otp_code VARCHAR(6) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL,
+    is_used BOOLEAN DEFAULT FALSE
+);
+CREATE INDEX IF NOT EXISTS idx_user_otp ON otp_sessions (user_id, is_used);
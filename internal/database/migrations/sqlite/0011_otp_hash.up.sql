@@ -0,0 +1,15 @@
+-- OTP codes are stored as keyed HMACs. Codes already issued were stored in
+-- plaintext and cannot be hashed here without the key, so they are cleared;
+-- anyone mid-login requests a new one. Nothing references otp_sessions, so
+-- the table is recreated rather than altered column by column.
+DROP TABLE otp_sessions;
+CREATE TABLE otp_sessions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    otp_hash CHAR(64) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL,
+    is_used BOOLEAN DEFAULT FALSE,
+    failed_attempts INTEGER NOT NULL DEFAULT 0
+);
+CREATE INDEX IF NOT EXISTS idx_user_otp ON otp_sessions (user_id, is_used);
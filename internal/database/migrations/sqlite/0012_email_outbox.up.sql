@@ -0,0 +1,38 @@
+-- Outbound email queue, drained by the background worker
+CREATE TABLE IF NOT EXISTS email_outbox (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    recipient VARCHAR(100) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    body TEXT NOT NULL,
+    template VARCHAR(50),
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'sent', 'dead')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP NOT NULL,
+    last_error TEXT,
+    created_at TIMESTAMP NOT NULL,
+    sent_at TIMESTAMP NULL
+);
+CREATE INDEX IF NOT EXISTS idx_outbox_due ON email_outbox (status, next_attempt_at);
+
+-- Only the Exam Cell can inspect and retry the outbox. Rebuilt to widen the
+-- CHECK constraint.
+CREATE TABLE access_control_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    role TEXT NOT NULL CHECK (role IN ('Faculty', 'ExamCell', 'Student')),
+    object_type TEXT NOT NULL CHECK (object_type IN ('QuestionPaper', 'EncryptionKey', 'ExamSession', 'UserAccount', 'EmailOutbox')),
+    can_create BOOLEAN DEFAULT FALSE,
+    can_read BOOLEAN DEFAULT FALSE,
+    can_update BOOLEAN DEFAULT FALSE,
+    can_delete BOOLEAN DEFAULT FALSE,
+    can_encrypt BOOLEAN DEFAULT FALSE,
+    can_decrypt BOOLEAN DEFAULT FALSE,
+    UNIQUE (role, object_type)
+);
+INSERT INTO access_control_new SELECT * FROM access_control;
+DROP TABLE access_control;
+ALTER TABLE access_control_new RENAME TO access_control;
+
+INSERT OR IGNORE INTO access_control (role, object_type, can_create, can_read, can_update, can_delete, can_encrypt, can_decrypt) VALUES
+('Faculty', 'EmailOutbox', FALSE, FALSE, FALSE, FALSE, FALSE, FALSE),
+('ExamCell', 'EmailOutbox', FALSE, TRUE, FALSE, FALSE, FALSE, FALSE),
+('Student', 'EmailOutbox', FALSE, FALSE, FALSE, FALSE, FALSE, FALSE);
@@ -0,0 +1,4 @@
+-- Papers must be moved back inline before rolling back, or their ciphertext is unreachable
+ALTER TABLE question_papers DROP COLUMN blob_sha256;
+ALTER TABLE question_papers DROP COLUMN blob_size;
+ALTER TABLE question_papers DROP COLUMN blob_ref;
@@ -0,0 +1,5 @@
+-- Paper ciphertext moves to the blob store; the row keeps its ref, size and hash.
+-- encrypted_content is left empty for papers stored as blobs.
+ALTER TABLE question_papers ADD COLUMN blob_ref VARCHAR(80);
+ALTER TABLE question_papers ADD COLUMN blob_size BIGINT;
+ALTER TABLE question_papers ADD COLUMN blob_sha256 CHAR(64);
@@ -0,0 +1 @@
+ALTER TABLE question_papers DROP COLUMN content_format;
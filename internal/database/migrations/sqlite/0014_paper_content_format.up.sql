@@ -0,0 +1,2 @@
+-- How a paper's ciphertext was produced; existing papers are single-shot AES-GCM
+ALTER TABLE question_papers ADD COLUMN content_format VARCHAR(20) NOT NULL DEFAULT 'gcm';
@@ -0,0 +1,6 @@
+-- Record the RSA algorithm behind every wrapped key and signature so OAEP/PSS
+-- can replace PKCS#1 v1.5 while existing rows stay readable
+ALTER TABLE paper_key_recipients ADD COLUMN key_wrap VARCHAR(32) NOT NULL DEFAULT 'rsa-pkcs1v15';
+ALTER TABLE paper_key_shares ADD COLUMN key_wrap VARCHAR(32) NOT NULL DEFAULT 'rsa-pkcs1v15';
+ALTER TABLE paper_share_submissions ADD COLUMN key_wrap VARCHAR(32) NOT NULL DEFAULT 'rsa-pkcs1v15';
+ALTER TABLE question_papers ADD COLUMN signature_alg VARCHAR(32) NOT NULL DEFAULT 'rsa-pkcs1v15-sha256';
@@ -0,0 +1,3 @@
+-- Papers signed before a rotation verify against the wrong key on older builds
+ALTER TABLE question_papers DROP COLUMN signer_key_id;
+DROP TABLE IF EXISTS user_keys;
@@ -0,0 +1,25 @@
+-- Every key pair a user has held. The row matching users.public_key is
+-- 'active'; rotated-out keys stay as 'retired' so their signatures still
+-- verify. Only public halves are kept: retired private keys are discarded.
+CREATE TABLE IF NOT EXISTS user_keys (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    public_key TEXT NOT NULL,
+    key_algorithm VARCHAR(32),
+    status TEXT NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'retired')),
+    created_at TIMESTAMP NOT NULL,
+    retired_at TIMESTAMP NULL
+);
+CREATE INDEX IF NOT EXISTS idx_user_keys ON user_keys (user_id, status);
+
+INSERT INTO user_keys (user_id, public_key, key_algorithm, status, created_at)
+    SELECT id, public_key, key_algorithm, 'active', COALESCE(created_at, CURRENT_TIMESTAMP)
+    FROM users WHERE public_key IS NOT NULL AND public_key <> '';
+
+-- The key a paper was signed with. No REFERENCES clause: SQLite cannot drop
+-- a column with one, and user_keys rows live as long as their user.
+ALTER TABLE question_papers ADD COLUMN signer_key_id INTEGER NULL;
+UPDATE question_papers SET signer_key_id = (
+    SELECT k.id FROM user_keys k
+    WHERE k.user_id = question_papers.faculty_id AND k.status = 'active'
+);
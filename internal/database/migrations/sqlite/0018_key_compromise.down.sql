@@ -0,0 +1,5 @@
+-- Compromise reports are lost; re-signed and re-encrypted papers stay as they are
+DROP TABLE IF EXISTS paper_reencryption_queue;
+ALTER TABLE user_keys DROP COLUMN compromise_reason;
+ALTER TABLE user_keys DROP COLUMN compromised_by;
+ALTER TABLE user_keys DROP COLUMN compromised_at;
@@ -0,0 +1,19 @@
+-- A key reported compromised by the Exam Cell. Papers it signed are refused
+-- until re-signed; papers whose AES key was wrapped to it are re-encrypted.
+ALTER TABLE user_keys ADD COLUMN compromised_at TIMESTAMP NULL;
+ALTER TABLE user_keys ADD COLUMN compromised_by INTEGER NULL;
+ALTER TABLE user_keys ADD COLUMN compromise_reason TEXT NULL;
+
+-- Papers waiting to be re-encrypted under a fresh AES key
+CREATE TABLE IF NOT EXISTS paper_reencryption_queue (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    paper_id INTEGER NOT NULL REFERENCES question_papers(id) ON DELETE CASCADE,
+    key_id INTEGER NOT NULL REFERENCES user_keys(id) ON DELETE CASCADE,
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'done')),
+    requested_by INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    requested_at TIMESTAMP NOT NULL,
+    completed_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    completed_at TIMESTAMP NULL,
+    UNIQUE (paper_id, key_id)
+);
+CREATE INDEX IF NOT EXISTS idx_reencryption_status ON paper_reencryption_queue (status, paper_id);
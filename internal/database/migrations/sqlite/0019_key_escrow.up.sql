@@ -0,0 +1,11 @@
+-- Each paper's AES key wrapped to the offline escrow key, so papers stay
+-- recoverable if every ExamCell account or key is lost. Rows belong to the
+-- paper only: deleting a user never removes them.
+CREATE TABLE IF NOT EXISTS paper_key_escrow (
+    paper_id INTEGER PRIMARY KEY REFERENCES question_papers(id) ON DELETE CASCADE,
+    encrypted_aes_key TEXT NOT NULL,
+    key_wrap VARCHAR(32) NOT NULL,
+    escrow_fingerprint VARCHAR(80) NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_escrow_fingerprint ON paper_key_escrow (escrow_fingerprint);
@@ -0,0 +1,2 @@
+-- Older builds can neither verify envelope signatures nor open gcm-stream-v2 content
+ALTER TABLE question_papers DROP COLUMN signature_scheme;
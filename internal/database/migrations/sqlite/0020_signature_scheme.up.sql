@@ -0,0 +1,2 @@
+-- What a paper's signature covers; existing papers signed the plaintext only
+ALTER TABLE question_papers ADD COLUMN signature_scheme VARCHAR(20) NOT NULL DEFAULT 'content-sha256';
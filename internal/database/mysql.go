@@ -0,0 +1,44 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+
+	_ "github.com/go-sql-driver/mysql"
+)
+
+// MySQLDialect is the default production backend
+type MySQLDialect struct{}
+
+func (MySQLDialect) Name() string       { return "mysql" }
+func (MySQLDialect) DriverName() string { return "mysql" }
+
+// DSN builds a MySQL DSN from DB_USER, DB_PASSWORD, DB_HOST, DB_PORT and DB_NAME
+func (MySQLDialect) DSN() string {
+	return fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?parseTime=true&multiStatements=true",
+		os.Getenv("DB_USER"),
+		os.Getenv("DB_PASSWORD"),
+		os.Getenv("DB_HOST"),
+		os.Getenv("DB_PORT"),
+		os.Getenv("DB_NAME"),
+	)
+}
+
+// TableExists looks the table up in the current database's information schema
+func (MySQLDialect) TableExists(db *sql.DB, table string) (bool, error) {
+	var count int
+	query := `SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = DATABASE() AND table_name = ?`
+	if err := db.QueryRow(query, table).Scan(&count); err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// PrepareMigration has nothing to do: MySQL alters tables in place
+func (MySQLDialect) PrepareMigration(ctx context.Context, conn *sql.Conn) (func() error, error) {
+	return func() error { return nil }, nil
+}
+
+func (MySQLDialect) CheckMigration(tx *sql.Tx) error { return nil }
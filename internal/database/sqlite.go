@@ -0,0 +1,82 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"os"
+
+	_ "modernc.org/sqlite"
+)
+
+const DefaultSQLitePath = "portal.db"
+
+// SQLiteDialect runs the portal on a local file with the pure Go SQLite driver,
+// for development and tests without a MySQL server
+type SQLiteDialect struct{}
+
+func (SQLiteDialect) Name() string       { return "sqlite" }
+func (SQLiteDialect) DriverName() string { return "sqlite" }
+
+// DSN opens SQLITE_PATH with foreign keys enforced, WAL for concurrent readers,
+// a busy timeout for the email worker, and times stored in a sortable format
+func (SQLiteDialect) DSN() string {
+	path := os.Getenv("SQLITE_PATH")
+	if path == "" {
+		path = DefaultSQLitePath
+	}
+
+	params := url.Values{}
+	params.Add("_pragma", "foreign_keys(1)")
+	params.Add("_pragma", "busy_timeout(10000)")
+	params.Add("_pragma", "journal_mode(WAL)")
+	params.Set("_txlock", "immediate")
+	params.Set("_time_format", "sqlite")
+	return "file:" + path + "?" + params.Encode()
+}
+
+// TableExists looks the table up in sqlite_master
+func (SQLiteDialect) TableExists(db *sql.DB, table string) (bool, error) {
+	var count int
+	query := `SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?`
+	if err := db.QueryRow(query, table).Scan(&count); err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// PrepareMigration turns foreign keys off for the step. SQLite cannot change a
+// CHECK constraint, so migrations rebuild the table instead, and with keys on
+// dropping the old copy would cascade into every table that references it.
+// The pragma is ignored inside a transaction, so it is set on the connection.
+func (SQLiteDialect) PrepareMigration(ctx context.Context, conn *sql.Conn) (func() error, error) {
+	if _, err := conn.ExecContext(ctx, `PRAGMA foreign_keys = OFF`); err != nil {
+		return nil, fmt.Errorf("failed to disable foreign keys: %w", err)
+	}
+	return func() error {
+		_, err := conn.ExecContext(ctx, `PRAGMA foreign_keys = ON`)
+		return err
+	}, nil
+}
+
+// CheckMigration refuses to commit a step that left a row pointing at a
+// missing parent, since foreign keys were not enforced while it ran
+func (SQLiteDialect) CheckMigration(tx *sql.Tx) error {
+	rows, err := tx.Query(`PRAGMA foreign_key_check`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	if rows.Next() {
+		var table, parent string
+		var rowID sql.NullInt64
+		var fkID int
+		if err := rows.Scan(&table, &rowID, &parent, &fkID); err != nil {
+			return err
+		}
+		return fmt.Errorf("foreign key check failed: %s row %d references a missing %s", table, rowID.Int64, parent)
+	}
+	return rows.Err()
+}
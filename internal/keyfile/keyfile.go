@@ -0,0 +1,80 @@
+package keyfile
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/internal/crypto"
+)
+
+// Generate creates a key pair kept outside the database, such as the escrow
+// key, of the named algorithm (empty for the default). The private key is
+// written passphrase-encrypted to privatePath and the public key to
+// publicPath. Existing files are never overwritten. It returns the
+// public key's fingerprint.
+func Generate(privatePath, publicPath, algorithm, passphrase string) (string, error) {
+	algorithm, err := crypto.ParseKeyAlgorithm(algorithm)
+	if err != nil {
+		return "", err
+	}
+
+	privateKey, err := crypto.GenerateKeyPair(algorithm)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate key pair: %w", err)
+	}
+	privateKeyPEM, err := crypto.EncryptPrivateKeyToPEM(privateKey, passphrase)
+	if err != nil {
+		return "", fmt.Errorf("failed to encrypt private key: %w", err)
+	}
+	publicKeyPEM, err := crypto.EncodePublicKey(privateKey.Public())
+	if err != nil {
+		return "", fmt.Errorf("failed to encode public key: %w", err)
+	}
+
+	if err := writeNewFile(privatePath, privateKeyPEM, 0600); err != nil {
+		return "", err
+	}
+	if err := writeNewFile(publicPath, publicKeyPEM, 0644); err != nil {
+		os.Remove(privatePath)
+		return "", err
+	}
+	return crypto.PublicKeyFingerprint(privateKey.Public())
+}
+
+// LoadPublicKey reads a PEM public key of any supported algorithm
+func LoadPublicKey(path string) (crypto.PublicKey, error) {
+	publicKeyPEM, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read public key: %w", err)
+	}
+	publicKey, err := crypto.ParsePublicKey(string(publicKeyPEM))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse public key %s: %w", path, err)
+	}
+	return publicKey, nil
+}
+
+// LoadPrivateKey reads a passphrase-encrypted private key written by Generate
+func LoadPrivateKey(path, passphrase string) (crypto.PrivateKey, error) {
+	privateKeyPEM, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read private key: %w", err)
+	}
+	if !crypto.IsEncryptedPrivateKeyPEM(string(privateKeyPEM)) {
+		return nil, fmt.Errorf("%s is not a passphrase-encrypted private key", path)
+	}
+	return crypto.DecryptPrivateKeyFromPEM(string(privateKeyPEM), passphrase)
+}
+
+func writeNewFile(path, content string, perm os.FileMode) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := file.WriteString(content); err != nil {
+		file.Close()
+		os.Remove(path)
+		return err
+	}
+	return file.Close()
+}
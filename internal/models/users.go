@@ -1,6 +1,10 @@
 package models
 
-import "time"
+import (
+	"time"
+
+	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/internal/crypto"
+)
 
 type User struct {
 	ID                  int
@@ -11,18 +15,52 @@ type User struct {
 	Email               string
 	PublicKey           string
 	PrivateKeyEncrypted string
+	KeyAlgorithm        string
 	CreatedAt           time.Time
+
+	// PrivateKey is unwrapped with the user's password after login and is never persisted
+	PrivateKey crypto.PrivateKey
+}
+
+// UserKey is one key pair in a user's key history
+type UserKey struct {
+	ID               int
+	UserID           int
+	PublicKey        string
+	KeyAlgorithm     string
+	Status           string
+	CreatedAt        time.Time
+	RetiredAt        *time.Time
+	CompromisedAt    *time.Time
+	CompromiseReason string
+}
+
+// LockedAccount is a user temporarily locked out after failed logins
+type LockedAccount struct {
+	UserID      int
+	Username    string
+	Role        string
+	LockedUntil time.Time
 }
 
 type OTPSession struct {
 	ID        int
 	UserID    int
-	OTPCode   string
+	OTPHash   string // HMAC-SHA256 of the code; the code itself is never stored
 	CreatedAt time.Time
 	ExpiresAt time.Time
 	IsUsed    bool
 }
 
+type UserSession struct {
+	ID         int
+	UserID     int
+	Source     string
+	CreatedAt  time.Time
+	ExpiresAt  time.Time
+	LastSeenAt time.Time
+}
+
 type QuestionPaper struct {
 	ID               int
 	Title            string
@@ -35,11 +73,32 @@ type QuestionPaper struct {
 	UploadDate       time.Time
 	ExamDate         time.Time
 	Status           string
+	ReleaseThreshold int
+	ReviewComments   string
+	RevisionOf       int // the rejected paper this one revises, if any
+
+	// SignerCompromised is set when the signing key was reported compromised;
+	// the paper cannot be decrypted until its faculty re-signs it
+	SignerCompromised bool
+	// ReencryptionPending is set while the paper waits for a fresh AES key
+	ReencryptionPending bool
+}
+
+type PaperStatusChange struct {
+	ID            int
+	PaperID       int
+	FromStatus    string
+	ToStatus      string
+	ChangedBy     int
+	ChangedByName string
+	Comments      string
+	ChangedAt     time.Time
 }
 
 type ExamSession struct {
 	ID              int
 	PaperID         int
+	PaperTitle      string
 	SessionName     string
 	ScheduledTime   time.Time
 	DurationMinutes int
@@ -47,3 +106,17 @@ type ExamSession struct {
 	CreatedBy       int
 	CreatedAt       time.Time
 }
+
+// OutboxMessage is a queued outbound email
+type OutboxMessage struct {
+	ID            int
+	Recipient     string
+	Subject       string
+	Template      string
+	Status        string
+	Attempts      int
+	NextAttemptAt time.Time
+	LastError     string
+	CreatedAt     time.Time
+	SentAt        *time.Time
+}
@@ -0,0 +1,73 @@
+package services
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+
+	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/internal/crypto"
+	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/pkg/blobstore"
+)
+
+// MoveInlinePapers copies the base64 ciphertext of papers stored before the
+// blob store into it and clears the inline copy, returning how many moved.
+// Each paper is written to the store before its row is updated, so an
+// interrupted run only leaves an orphaned blob and can simply be repeated.
+func MoveInlinePapers(db *sql.DB, store blobstore.BlobStore) (int, error) {
+	rows, err := db.Query(`
+        SELECT id FROM question_papers
+        WHERE blob_ref IS NULL AND encrypted_content <> ''
+        ORDER BY id
+    `)
+	if err != nil {
+		return 0, fmt.Errorf("failed to find inline papers: %w", err)
+	}
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	moved := 0
+	for _, id := range ids {
+		var contentB64 string
+		err := db.QueryRow(`SELECT encrypted_content FROM question_papers WHERE id = ? AND blob_ref IS NULL`, id).Scan(&contentB64)
+		if err == sql.ErrNoRows {
+			continue
+		} else if err != nil {
+			return moved, fmt.Errorf("failed to read paper %d: %w", id, err)
+		}
+
+		content, err := crypto.DecodeBase64(contentB64)
+		if err != nil {
+			return moved, fmt.Errorf("paper %d has corrupt inline content: %w", id, err)
+		}
+
+		blob, err := store.Put(bytes.NewReader(content))
+		if err != nil {
+			return moved, fmt.Errorf("failed to store paper %d: %w", id, err)
+		}
+
+		result, err := db.Exec(`
+            UPDATE question_papers
+            SET blob_ref = ?, blob_size = ?, blob_sha256 = ?, encrypted_content = ''
+            WHERE id = ? AND blob_ref IS NULL
+        `, blob.Ref, blob.Size, blob.SHA256, id)
+		if err != nil {
+			return moved, fmt.Errorf("failed to update paper %d: %w", id, err)
+		}
+		if n, _ := result.RowsAffected(); n == 1 {
+			moved++
+		}
+	}
+	return moved, nil
+}
@@ -0,0 +1,288 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/internal/acl"
+	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/internal/crypto"
+	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/internal/keyfile"
+)
+
+// EscrowKey is the public half of the offline escrow key pair. Every paper
+// key is also wrapped to it, so papers stay recoverable if every ExamCell
+// account or key is lost. The private half never touches the server; it is
+// only read from a file by the escrow recovery command.
+type EscrowKey struct {
+	PublicKey   crypto.PublicKey
+	Fingerprint string
+}
+
+var defaultEscrow *EscrowKey
+
+// LoadEscrowKey reads an escrow public key from a PEM file
+func LoadEscrowKey(path string) (*EscrowKey, error) {
+	publicKey, err := keyfile.LoadPublicKey(path)
+	if err != nil {
+		return nil, fmt.Errorf("escrow key: %w", err)
+	}
+	fingerprint, err := crypto.PublicKeyFingerprint(publicKey)
+	if err != nil {
+		return nil, err
+	}
+	return &EscrowKey{PublicKey: publicKey, Fingerprint: fingerprint}, nil
+}
+
+// InitEscrowFromEnv loads the escrow public key named by
+// ESCROW_PUBLIC_KEY_FILE and makes it the process default. Escrow is off
+// (nil, nil) when the variable is unset.
+func InitEscrowFromEnv() (*EscrowKey, error) {
+	path := strings.TrimSpace(os.Getenv("ESCROW_PUBLIC_KEY_FILE"))
+	if path == "" {
+		defaultEscrow = nil
+		return nil, nil
+	}
+
+	escrow, err := LoadEscrowKey(path)
+	if err != nil {
+		return nil, err
+	}
+	defaultEscrow = escrow
+	return escrow, nil
+}
+
+// DefaultEscrow returns the escrow key set by InitEscrowFromEnv, if any
+func DefaultEscrow() *EscrowKey {
+	return defaultEscrow
+}
+
+// storeEscrowKey wraps a paper's AES key to the escrow key, replacing any
+// earlier escrow entry for the paper
+func storeEscrowKey(tx *sql.Tx, paperID int64, aesKey []byte, escrow *EscrowKey) error {
+	if _, err := tx.Exec(`DELETE FROM paper_key_escrow WHERE paper_id = ?`, paperID); err != nil {
+		return fmt.Errorf("failed to replace escrowed key: %w", err)
+	}
+	if escrow == nil {
+		return nil
+	}
+
+	encryptedKey, keyWrap, err := escrow.PublicKey.Wrap(aesKey)
+	if err != nil {
+		return fmt.Errorf("failed to wrap key for escrow: %w", err)
+	}
+	query := `
+        INSERT INTO paper_key_escrow (paper_id, encrypted_aes_key, key_wrap, escrow_fingerprint, created_at)
+        VALUES (?, ?, ?, ?, ?)
+    `
+	if _, err := tx.Exec(query, paperID, crypto.EncodeBase64(encryptedKey), keyWrap, escrow.Fingerprint, time.Now()); err != nil {
+		return fmt.Errorf("failed to store escrowed key: %w", err)
+	}
+	return nil
+}
+
+// EscrowStatus counts papers by escrow coverage
+type EscrowStatus struct {
+	Papers int
+	// Escrowed counts papers per escrow key fingerprint
+	Escrowed map[string]int
+	// Missing lists papers with no escrowed key, e.g. uploaded before escrow was configured
+	Missing []int
+}
+
+// GetEscrowStatus reports which papers have an escrowed key
+func GetEscrowStatus(db *sql.DB) (*EscrowStatus, error) {
+	query := `
+        SELECT qp.id, e.escrow_fingerprint
+        FROM question_papers qp
+        LEFT JOIN paper_key_escrow e ON e.paper_id = qp.id
+        ORDER BY qp.id
+    `
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read escrow status: %w", err)
+	}
+	defer rows.Close()
+
+	status := &EscrowStatus{Escrowed: make(map[string]int)}
+	for rows.Next() {
+		var paperID int
+		var fingerprint sql.NullString
+		if err := rows.Scan(&paperID, &fingerprint); err != nil {
+			return nil, fmt.Errorf("failed to scan escrow status: %w", err)
+		}
+		status.Papers++
+		if fingerprint.Valid {
+			status.Escrowed[fingerprint.String]++
+		} else {
+			status.Missing = append(status.Missing, paperID)
+		}
+	}
+	return status, rows.Err()
+}
+
+// EscrowRecovery summarises an escrow recovery run
+type EscrowRecovery struct {
+	Fingerprint string
+	UserID      int
+	Username    string
+	Restored    []int          // papers whose key (or a fresh share set) was wrapped for the user
+	Skipped     int            // papers the user could already open
+	Failed      map[int]string // paper ID to reason
+}
+
+// RecoverFromEscrow restores an ExamCell member's access to papers using the
+// escrow private key. For ordinary papers the escrowed AES key is wrapped for
+// the member; for threshold papers the key is split again among the current
+// ExamCell members with the paper's threshold, replacing the old shares and
+// any submitted ones. paperID limits recovery to one paper (0 means all).
+// Papers the member can already open are left alone, and each paper is
+// restored in its own transaction. Every restored or failed paper is audited
+// against the member's account, as is the run as a whole.
+func RecoverFromEscrow(db *sql.DB, escrowKey crypto.PrivateKey, username string, paperID int) (*EscrowRecovery, error) {
+	fingerprint, err := crypto.PublicKeyFingerprint(escrowKey.Public())
+	if err != nil {
+		return nil, err
+	}
+
+	recipients, err := getExamCellRecipients(db)
+	if err != nil {
+		return nil, err
+	}
+	var member *KeyRecipient
+	for i := range recipients {
+		if recipients[i].Username == username {
+			member = &recipients[i]
+		}
+	}
+	if member == nil {
+		return nil, fmt.Errorf("%s is not an ExamCell member with a usable key pair", username)
+	}
+
+	recovery := &EscrowRecovery{
+		Fingerprint: fingerprint,
+		UserID:      member.UserID,
+		Username:    member.Username,
+		Failed:      make(map[int]string),
+	}
+
+	query := `
+        SELECT e.paper_id, e.encrypted_aes_key, e.key_wrap, qp.release_threshold
+        FROM paper_key_escrow e
+        JOIN question_papers qp ON qp.id = e.paper_id
+        WHERE e.escrow_fingerprint = ? AND (? = 0 OR e.paper_id = ?)
+        ORDER BY e.paper_id
+    `
+	rows, err := db.Query(query, fingerprint, paperID, paperID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find escrowed keys: %w", err)
+	}
+
+	type escrowedKey struct {
+		paperID   int
+		wrapped   string
+		keyWrap   string
+		threshold int
+	}
+	var keys []escrowedKey
+	for rows.Next() {
+		var key escrowedKey
+		if err := rows.Scan(&key.paperID, &key.wrapped, &key.keyWrap, &key.threshold); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan escrowed key: %w", err)
+		}
+		keys = append(keys, key)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no papers are escrowed under %s", fingerprint)
+	}
+
+	for _, key := range keys {
+		restored, err := restoreEscrowedKey(db, key.paperID, key.wrapped, key.keyWrap, key.threshold, escrowKey, *member, recipients)
+		pid := key.paperID
+		switch {
+		case err != nil:
+			recovery.Failed[key.paperID] = err.Error()
+			acl.LogAction(db, member.UserID, "escrow_recover", "QuestionPaper", &pid, false,
+				fmt.Sprintf("escrow key %s: %v", fingerprint, err))
+		case restored:
+			recovery.Restored = append(recovery.Restored, key.paperID)
+			acl.LogAction(db, member.UserID, "escrow_recover", "QuestionPaper", &pid, true,
+				fmt.Sprintf("access for %s restored from escrow key %s", member.Username, fingerprint))
+		default:
+			recovery.Skipped++
+		}
+	}
+
+	acl.LogAction(db, member.UserID, "escrow_recovery", "EncryptionKey", nil, len(recovery.Failed) == 0,
+		fmt.Sprintf("escrow key %s used for %s by OS user %q: %d restored, %d already accessible, %d failed",
+			fingerprint, member.Username, os.Getenv("USER"), len(recovery.Restored), recovery.Skipped, len(recovery.Failed)))
+	return recovery, nil
+}
+
+// restoreEscrowedKey gives member access to one paper, reporting false if
+// they already had it
+func restoreEscrowedKey(db *sql.DB, paperID int, wrappedB64, keyWrap string, threshold int,
+	escrowKey crypto.PrivateKey, member KeyRecipient, recipients []KeyRecipient) (bool, error) {
+	holds := `SELECT COUNT(*) FROM paper_key_recipients WHERE paper_id = ? AND user_id = ?`
+	if threshold > 0 {
+		holds = `SELECT COUNT(*) FROM paper_key_shares WHERE paper_id = ? AND user_id = ?`
+	}
+	var existing int
+	if err := db.QueryRow(holds, paperID, member.UserID).Scan(&existing); err != nil {
+		return false, fmt.Errorf("failed to check existing keys: %w", err)
+	}
+	if existing > 0 {
+		return false, nil
+	}
+
+	ciphertext, err := crypto.DecodeBase64(wrappedB64)
+	if err != nil {
+		return false, fmt.Errorf("failed to decode escrowed key: %w", err)
+	}
+	aesKey, err := escrowKey.Unwrap(keyWrap, ciphertext)
+	if err != nil {
+		return false, fmt.Errorf("failed to open escrowed key: %w", err)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if threshold > 0 {
+		shares, err := splitKeyForRecipients(aesKey, recipients, threshold)
+		if err != nil {
+			return false, err
+		}
+		if _, err := tx.Exec(`DELETE FROM paper_key_shares WHERE paper_id = ?`, paperID); err != nil {
+			return false, fmt.Errorf("failed to remove old key shares: %w", err)
+		}
+		if _, err := tx.Exec(`DELETE FROM paper_share_submissions WHERE paper_id = ?`, paperID); err != nil {
+			return false, fmt.Errorf("failed to remove submitted shares: %w", err)
+		}
+		if err := storeKeyShares(tx, int64(paperID), shares); err != nil {
+			return false, err
+		}
+	} else {
+		wrapped, err := wrapKeyForRecipients(aesKey, []KeyRecipient{member})
+		if err != nil {
+			return false, err
+		}
+		if err := storeKeyRecipients(tx, int64(paperID), wrapped); err != nil {
+			return false, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit restored key: %w", err)
+	}
+	return true, nil
+}
@@ -0,0 +1,376 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/internal/acl"
+	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/internal/models"
+	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/pkg/email"
+)
+
+// Exam session statuses
+const (
+	SessionScheduled = "scheduled"
+	SessionActive    = "active"
+	SessionCompleted = "completed"
+	SessionCancelled = "cancelled"
+
+	MaxSessionDurationMins = 24 * 60
+)
+
+// ErrReleaseMovedEarlier is returned for a session change that would open the
+// paper earlier than it opens now; such changes go through RequestNewSession
+// or RequestReschedule and need a second ExamCell member's approval
+var ErrReleaseMovedEarlier = errors.New("this would open the paper earlier and needs a second ExamCell member's approval")
+
+// ExamSessionService schedules and runs exam sessions
+type ExamSessionService struct {
+	DB   *sql.DB
+	User *models.User
+}
+
+// NewExamSessionService creates a new exam session service
+func NewExamSessionService(db *sql.DB, user *models.User) *ExamSessionService {
+	return &ExamSessionService{
+		DB:   db,
+		User: user,
+	}
+}
+
+// ScheduleSession creates a session for an approved paper
+func (s *ExamSessionService) ScheduleSession(paperID int, name string, scheduledTime time.Time, durationMins int) (int, error) {
+	if err := acl.EnforcePermission(s.DB, s.User, "ExamSession", "create", nil); err != nil {
+		return 0, err
+	}
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return 0, fmt.Errorf("session name cannot be empty")
+	}
+	if err := validateSessionTiming(scheduledTime, durationMins); err != nil {
+		return 0, err
+	}
+
+	title, facultyID, err := s.schedulablePaper(paperID)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := s.checkOverlap(paperID, 0, scheduledTime, durationMins); err != nil {
+		return 0, err
+	}
+	if err := s.checkReleaseNotEarlier(paperID, scheduledTime); err != nil {
+		return 0, err
+	}
+
+	query := `
+        INSERT INTO exam_sessions (paper_id, session_name, scheduled_time, duration_minutes, status, created_by)
+        VALUES (?, ?, ?, ?, ?, ?)
+    `
+	result, err := s.DB.Exec(query, paperID, name, scheduledTime, durationMins, SessionScheduled, s.User.ID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create session: %w", err)
+	}
+
+	sessionID, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get session ID: %w", err)
+	}
+
+	notifyUser(s.DB, facultyID, email.TemplateSessionScheduled, email.SessionData{
+		SessionID:       int(sessionID),
+		SessionName:     name,
+		PaperTitle:      title,
+		ScheduledTime:   scheduledTime,
+		DurationMinutes: durationMins,
+		ScheduledBy:     s.User.Username,
+	})
+
+	return int(sessionID), nil
+}
+
+// RescheduleSession moves a session that has not started yet
+func (s *ExamSessionService) RescheduleSession(sessionID int, scheduledTime time.Time, durationMins int) error {
+	if err := acl.EnforcePermission(s.DB, s.User, "ExamSession", "update", &sessionID); err != nil {
+		return err
+	}
+
+	if err := validateSessionTiming(scheduledTime, durationMins); err != nil {
+		return err
+	}
+
+	session, err := s.getSession(sessionID)
+	if err != nil {
+		return err
+	}
+	if session.Status != SessionScheduled {
+		return fmt.Errorf("only scheduled sessions can be rescheduled (status: %s)", session.Status)
+	}
+
+	if err := s.checkOverlap(session.PaperID, sessionID, scheduledTime, durationMins); err != nil {
+		return err
+	}
+	if err := s.checkReleaseNotEarlier(session.PaperID, scheduledTime); err != nil {
+		return err
+	}
+
+	query := `UPDATE exam_sessions SET scheduled_time = ?, duration_minutes = ? WHERE id = ? AND status = ?`
+	return s.updateSession(query, scheduledTime, durationMins, sessionID, SessionScheduled)
+}
+
+// StartSession marks a scheduled session as active
+func (s *ExamSessionService) StartSession(sessionID int) error {
+	if err := acl.EnforcePermission(s.DB, s.User, "ExamSession", "update", &sessionID); err != nil {
+		return err
+	}
+
+	session, err := s.getSession(sessionID)
+	if err != nil {
+		return err
+	}
+	if session.Status != SessionScheduled {
+		return fmt.Errorf("only scheduled sessions can be started (status: %s)", session.Status)
+	}
+
+	query := `UPDATE exam_sessions SET status = ? WHERE id = ? AND status = ?`
+	return s.updateSession(query, SessionActive, sessionID, SessionScheduled)
+}
+
+// CancelSession cancels a session that has not completed
+func (s *ExamSessionService) CancelSession(sessionID int) error {
+	if err := acl.EnforcePermission(s.DB, s.User, "ExamSession", "delete", &sessionID); err != nil {
+		return err
+	}
+
+	session, err := s.getSession(sessionID)
+	if err != nil {
+		return err
+	}
+	if session.Status != SessionScheduled && session.Status != SessionActive {
+		return fmt.Errorf("session is already %s", session.Status)
+	}
+
+	query := `UPDATE exam_sessions SET status = ? WHERE id = ? AND status = ?`
+	return s.updateSession(query, SessionCancelled, sessionID, session.Status)
+}
+
+// CompleteElapsedSessions marks active sessions as completed once their duration has passed
+func CompleteElapsedSessions(db *sql.DB) (int, error) {
+	rows, err := db.Query(`SELECT id, scheduled_time, duration_minutes FROM exam_sessions WHERE status = ?`, SessionActive)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get active sessions: %w", err)
+	}
+
+	now := time.Now()
+	var elapsed []int
+	for rows.Next() {
+		var id, durationMins int
+		var scheduledTime time.Time
+		if err := rows.Scan(&id, &scheduledTime, &durationMins); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan session: %w", err)
+		}
+		if !now.Before(scheduledTime.Add(time.Duration(durationMins) * time.Minute)) {
+			elapsed = append(elapsed, id)
+		}
+	}
+	rows.Close()
+
+	for _, id := range elapsed {
+		_, err := db.Exec(`UPDATE exam_sessions SET status = ? WHERE id = ? AND status = ?`, SessionCompleted, id, SessionActive)
+		if err != nil {
+			return 0, fmt.Errorf("failed to complete session: %w", err)
+		}
+	}
+
+	return len(elapsed), nil
+}
+
+// GetSessions lists all sessions, completing any that have elapsed first
+func (s *ExamSessionService) GetSessions() ([]models.ExamSession, error) {
+	if err := acl.EnforcePermission(s.DB, s.User, "ExamSession", "read", nil); err != nil {
+		return nil, err
+	}
+
+	if _, err := CompleteElapsedSessions(s.DB); err != nil {
+		return nil, err
+	}
+
+	query := `
+        SELECT es.id, es.paper_id, qp.title, es.session_name, es.scheduled_time, es.duration_minutes,
+               es.status, es.created_by, es.created_at
+        FROM exam_sessions es
+        JOIN question_papers qp ON es.paper_id = qp.id
+        ORDER BY es.scheduled_time ASC
+    `
+
+	rows, err := s.DB.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sessions: %w", err)
+	}
+	defer rows.Close()
+
+	var sessions []models.ExamSession
+	for rows.Next() {
+		var session models.ExamSession
+		err := rows.Scan(
+			&session.ID,
+			&session.PaperID,
+			&session.PaperTitle,
+			&session.SessionName,
+			&session.ScheduledTime,
+			&session.DurationMinutes,
+			&session.Status,
+			&session.CreatedBy,
+			&session.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan session: %w", err)
+		}
+		sessions = append(sessions, session)
+	}
+
+	return sessions, nil
+}
+
+// getSession loads a single session
+func (s *ExamSessionService) getSession(sessionID int) (*models.ExamSession, error) {
+	if _, err := CompleteElapsedSessions(s.DB); err != nil {
+		return nil, err
+	}
+
+	var session models.ExamSession
+	query := `
+        SELECT id, paper_id, session_name, scheduled_time, duration_minutes, status, created_by, created_at
+        FROM exam_sessions
+        WHERE id = ?
+    `
+	err := s.DB.QueryRow(query, sessionID).Scan(
+		&session.ID,
+		&session.PaperID,
+		&session.SessionName,
+		&session.ScheduledTime,
+		&session.DurationMinutes,
+		&session.Status,
+		&session.CreatedBy,
+		&session.CreatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("session not found")
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to fetch session: %w", err)
+	}
+
+	return &session, nil
+}
+
+// updateSession runs a guarded update and reports concurrent changes
+func (s *ExamSessionService) updateSession(query string, args ...interface{}) error {
+	result, err := s.DB.Exec(query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to update session: %w", err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return fmt.Errorf("session changed concurrently; please retry")
+	}
+	return nil
+}
+
+// checkOverlap rejects a time slot that overlaps another live session for the same paper
+func (s *ExamSessionService) checkOverlap(paperID, excludeSessionID int, start time.Time, durationMins int) error {
+	query := `
+        SELECT id, session_name, scheduled_time, duration_minutes
+        FROM exam_sessions
+        WHERE paper_id = ? AND id <> ? AND status <> ?
+    `
+	rows, err := s.DB.Query(query, paperID, excludeSessionID, SessionCancelled)
+	if err != nil {
+		return fmt.Errorf("failed to check session overlap: %w", err)
+	}
+	defer rows.Close()
+
+	end := start.Add(time.Duration(durationMins) * time.Minute)
+	for rows.Next() {
+		var id, otherDuration int
+		var name string
+		var otherStart time.Time
+		if err := rows.Scan(&id, &name, &otherStart, &otherDuration); err != nil {
+			return fmt.Errorf("failed to scan session: %w", err)
+		}
+
+		otherEnd := otherStart.Add(time.Duration(otherDuration) * time.Minute)
+		if start.Before(otherEnd) && otherStart.Before(end) {
+			return fmt.Errorf("overlaps session #%d %q (%s - %s)", id, name,
+				otherStart.Local().Format("2006-01-02 15:04"), otherEnd.Local().Format("15:04"))
+		}
+	}
+
+	return nil
+}
+
+// schedulablePaper returns the title and author of a paper that sessions can
+// be scheduled for
+func (s *ExamSessionService) schedulablePaper(paperID int) (string, int, error) {
+	var status, title string
+	var facultyID int
+	query := `SELECT status, title, faculty_id FROM question_papers WHERE id = ?`
+	err := s.DB.QueryRow(query, paperID).Scan(&status, &title, &facultyID)
+	if err == sql.ErrNoRows {
+		return "", 0, fmt.Errorf("paper not found")
+	} else if err != nil {
+		return "", 0, fmt.Errorf("failed to fetch paper: %w", err)
+	}
+	if status != StatusApproved && status != StatusPublished {
+		return "", 0, fmt.Errorf("paper must be approved before a session can be scheduled (status: %s)", status)
+	}
+	return title, facultyID, nil
+}
+
+// checkReleaseNotEarlier refuses a session start that would bring the paper's
+// release forward: before its earliest live session, which is what
+// ReleasePolicy opens it for, or before its exam date. A paper with neither
+// has nothing holding its release back, so its first session is refused too.
+func (s *ExamSessionService) checkReleaseNotEarlier(paperID int, start time.Time) error {
+	var earliest sql.NullTime
+	query := `
+        SELECT scheduled_time FROM exam_sessions
+        WHERE paper_id = ? AND status <> ?
+        ORDER BY scheduled_time ASC
+        LIMIT 1
+    `
+	err := s.DB.QueryRow(query, paperID, SessionCancelled).Scan(&earliest)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("failed to fetch exam sessions: %w", err)
+	}
+
+	var examDate sql.NullTime
+	if err := s.DB.QueryRow(`SELECT exam_date FROM question_papers WHERE id = ?`, paperID).Scan(&examDate); err != nil {
+		return fmt.Errorf("failed to fetch paper: %w", err)
+	}
+
+	switch {
+	case earliest.Valid && start.Before(earliest.Time):
+		return fmt.Errorf("%w: the paper's earliest session starts %s", ErrReleaseMovedEarlier,
+			earliest.Time.Local().Format("2006-01-02 15:04"))
+	case examDate.Valid && start.Before(examDate.Time):
+		return fmt.Errorf("%w: the paper's exam date is %s", ErrReleaseMovedEarlier, examDate.Time.Format("2006-01-02"))
+	case !earliest.Valid && !examDate.Valid:
+		return fmt.Errorf("%w: the paper has no exam date", ErrReleaseMovedEarlier)
+	}
+	return nil
+}
+
+// validateSessionTiming checks a proposed start time and duration
+func validateSessionTiming(scheduledTime time.Time, durationMins int) error {
+	if durationMins <= 0 || durationMins > MaxSessionDurationMins {
+		return fmt.Errorf("duration must be between 1 and %d minutes", MaxSessionDurationMins)
+	}
+	if scheduledTime.Before(time.Now()) {
+		return fmt.Errorf("session must be scheduled in the future")
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/internal/database/dbtest"
+)
+
+func TestScheduleCannotMoveReleaseEarlier(t *testing.T) {
+	db := dbtest.Open(t)
+	examDate := time.Now().UTC().AddDate(0, 0, 7).Truncate(24 * time.Hour)
+	paperID, member, _ := seedPaper(t, db, examDate)
+	sessions := NewExamSessionService(db, member)
+	policy := &ReleasePolicy{DB: db, Window: 30 * time.Minute}
+
+	// One member cannot open the paper in a minute by scheduling a session then
+	soon := time.Now().Add(time.Hour)
+	if _, err := sessions.ScheduleSession(paperID, "Early", soon, 60); !errors.Is(err, ErrReleaseMovedEarlier) {
+		t.Fatalf("session before the exam date: got %v, want ErrReleaseMovedEarlier", err)
+	}
+
+	morning := examDate.Add(9 * time.Hour)
+	sessionID, err := sessions.ScheduleSession(paperID, "Morning", morning, 90)
+	if err != nil {
+		t.Fatalf("session on the exam date: %v", err)
+	}
+
+	if err := sessions.RescheduleSession(sessionID, morning.Add(-time.Hour), 90); !errors.Is(err, ErrReleaseMovedEarlier) {
+		t.Fatalf("moving the earliest session earlier: got %v, want ErrReleaseMovedEarlier", err)
+	}
+	if err := sessions.RescheduleSession(sessionID, morning.Add(time.Hour), 90); err != nil {
+		t.Fatalf("moving the earliest session later: %v", err)
+	}
+	if _, err := sessions.ScheduleSession(paperID, "Extra", morning, 30); !errors.Is(err, ErrReleaseMovedEarlier) {
+		t.Fatalf("new session before the earliest one: got %v, want ErrReleaseMovedEarlier", err)
+	}
+
+	releaseAt, err := policy.ReleaseTime(paperID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := morning.Add(time.Hour).Add(-policy.Window); !releaseAt.Equal(want) {
+		t.Fatalf("release at %s, want %s", releaseAt, want)
+	}
+}
+
+func TestEarlierScheduleNeedsSecondApprover(t *testing.T) {
+	db := dbtest.Open(t)
+	examDate := time.Now().UTC().AddDate(0, 0, 7).Truncate(24 * time.Hour)
+	paperID, requester, approver := seedPaper(t, db, examDate)
+	requesterSessions := NewExamSessionService(db, requester)
+	approverSessions := NewExamSessionService(db, approver)
+	policy := &ReleasePolicy{DB: db, Window: 30 * time.Minute}
+
+	sessionID, err := requesterSessions.ScheduleSession(paperID, "Morning", examDate.Add(9*time.Hour), 90)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	earlier := time.Now().Add(2 * time.Hour).Truncate(time.Second)
+	if _, err := requesterSessions.RequestReschedule(sessionID, earlier, 90, ""); err == nil {
+		t.Fatal("schedule request accepted without a reason")
+	}
+	requestID, err := requesterSessions.RequestReschedule(sessionID, earlier, 90, "exam moved forward")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pending, err := approverSessions.GetPendingScheduleChanges()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 1 || pending[0].ID != requestID || pending[0].SessionID != sessionID {
+		t.Fatalf("pending requests = %+v", pending)
+	}
+
+	// Nothing changes until a second member approves
+	releaseAt, err := policy.ReleaseTime(paperID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !releaseAt.After(earlier) {
+		t.Fatalf("release moved to %s before approval", releaseAt)
+	}
+	if _, err := requesterSessions.ApproveScheduleChange(requestID); err == nil {
+		t.Fatal("requester approved their own schedule change")
+	}
+
+	appliedID, err := approverSessions.ApproveScheduleChange(requestID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if appliedID != sessionID {
+		t.Fatalf("approval applied to session %d, want %d", appliedID, sessionID)
+	}
+	if _, err := approverSessions.ApproveScheduleChange(requestID); err == nil {
+		t.Fatal("schedule change approved twice")
+	}
+
+	releaseAt, err = policy.ReleaseTime(paperID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := earlier.Add(-policy.Window); !releaseAt.Equal(want) {
+		t.Fatalf("release at %s after approval, want %s", releaseAt, want)
+	}
+
+	// New sessions go through the same request
+	requestID, err = requesterSessions.RequestNewSession(paperID, "Resit", earlier.Add(-time.Hour), 60, "resit for a student")
+	if err != nil {
+		t.Fatal(err)
+	}
+	newID, err := approverSessions.ApproveScheduleChange(requestID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if newID == sessionID || newID == 0 {
+		t.Fatalf("new session request applied as session %d", newID)
+	}
+}
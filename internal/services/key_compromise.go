@@ -0,0 +1,714 @@
+package services
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/internal/acl"
+	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/internal/crypto"
+	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/internal/models"
+)
+
+// Re-encryption queue statuses
+const (
+	ReencryptionPending = "pending"
+	ReencryptionDone    = "done"
+)
+
+// compromiseFlagColumns selects a paper's compromise flags in listings that
+// alias question_papers as qp
+const compromiseFlagColumns = `
+               EXISTS (SELECT 1 FROM user_keys k
+                       WHERE k.id = qp.signer_key_id AND k.compromised_at IS NOT NULL) AS signer_compromised,
+               EXISTS (SELECT 1 FROM paper_reencryption_queue q
+                       WHERE q.paper_id = qp.id AND q.status = 'pending') AS reencryption_pending`
+
+// AffectedPaper is a paper exposed by a compromised key
+type AffectedPaper struct {
+	ID         int
+	Title      string
+	Subject    string
+	Status     string
+	ExamDate   time.Time
+	UploadDate time.Time
+}
+
+// CompromiseReport lists everything a compromised key touched
+type CompromiseReport struct {
+	Key      models.UserKey
+	Username string
+	// KeyActive is set while the owner has not yet rotated the key
+	KeyActive bool
+	// SignedPapers are refused by DecryptPaper until their faculty re-signs them
+	SignedPapers []AffectedPaper
+	// WrappedPapers had their AES key wrapped to the key and are queued for re-encryption
+	WrappedPapers []AffectedPaper
+	// Sessions are scheduled or running exam sessions of any affected paper
+	Sessions []models.ExamSession
+}
+
+// KeyCompromiseService handles the Exam Cell's response to a leaked key
+type KeyCompromiseService struct {
+	DB   *sql.DB
+	User *models.User
+}
+
+// NewKeyCompromiseService creates a new key compromise service
+func NewKeyCompromiseService(db *sql.DB, user *models.User) *KeyCompromiseService {
+	return &KeyCompromiseService{
+		DB:   db,
+		User: user,
+	}
+}
+
+// GetUserKeys lists a user's key history, newest first
+func (s *KeyCompromiseService) GetUserKeys(userID int) ([]models.UserKey, error) {
+	if err := acl.EnforcePermission(s.DB, s.User, "UserAccount", "read", &userID); err != nil {
+		return nil, err
+	}
+
+	query := `
+        SELECT id, user_id, public_key, key_algorithm, status, created_at, retired_at,
+               compromised_at, compromise_reason
+        FROM user_keys
+        WHERE user_id = ?
+        ORDER BY id DESC
+    `
+	rows, err := s.DB.Query(query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get keys: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []models.UserKey
+	for rows.Next() {
+		key, err := scanUserKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, *key)
+	}
+	return keys, rows.Err()
+}
+
+// getUserKey loads a single key history entry
+func (s *KeyCompromiseService) getUserKey(keyID int) (*models.UserKey, error) {
+	query := `
+        SELECT id, user_id, public_key, key_algorithm, status, created_at, retired_at,
+               compromised_at, compromise_reason
+        FROM user_keys
+        WHERE id = ?
+    `
+	key, err := scanUserKey(s.DB.QueryRow(query, keyID))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("key not found")
+	}
+	return key, err
+}
+
+func scanUserKey(row interface{ Scan(...interface{}) error }) (*models.UserKey, error) {
+	var key models.UserKey
+	var keyAlgorithm, reason sql.NullString
+	var retiredAt, compromisedAt sql.NullTime
+
+	err := row.Scan(&key.ID, &key.UserID, &key.PublicKey, &keyAlgorithm, &key.Status, &key.CreatedAt,
+		&retiredAt, &compromisedAt, &reason)
+	if err == sql.ErrNoRows {
+		return nil, err
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to scan key: %w", err)
+	}
+
+	key.KeyAlgorithm = keyAlgorithm.String
+	key.CompromiseReason = reason.String
+	if retiredAt.Valid {
+		key.RetiredAt = &retiredAt.Time
+	}
+	if compromisedAt.Valid {
+		key.CompromisedAt = &compromisedAt.Time
+	}
+	return &key, nil
+}
+
+// ReportCompromise marks a key as compromised and responds to it: papers it
+// signed are refused by DecryptPaper until re-signed, and papers whose AES key
+// was wrapped to it are queued for re-encryption under a fresh AES key. An
+// active key stops receiving new paper keys and cannot sign until its owner
+// rotates it. Every step is audited.
+func (s *KeyCompromiseService) ReportCompromise(keyID int, reason string) (*CompromiseReport, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return nil, fmt.Errorf("a reason is required when reporting a compromised key")
+	}
+
+	key, err := s.getUserKey(keyID)
+	if err != nil {
+		return nil, err
+	}
+	if err := acl.EnforcePermission(s.DB, s.User, "UserAccount", "update", &key.UserID); err != nil {
+		return nil, err
+	}
+	if key.CompromisedAt != nil {
+		return nil, fmt.Errorf("key #%d was already reported compromised on %s", keyID, key.CompromisedAt.Format("2006-01-02 15:04"))
+	}
+
+	report := &CompromiseReport{Key: *key, KeyActive: key.Status == "active"}
+	if err := s.DB.QueryRow(`SELECT username FROM users WHERE id = ?`, key.UserID).Scan(&report.Username); err != nil {
+		return nil, fmt.Errorf("failed to load key owner: %w", err)
+	}
+
+	report.SignedPapers, err = s.papersSignedBy(keyID)
+	if err != nil {
+		return nil, err
+	}
+	report.WrappedPapers, err = s.papersWrappedTo(key)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+        UPDATE user_keys SET compromised_at = ?, compromised_by = ?, compromise_reason = ?
+        WHERE id = ? AND compromised_at IS NULL
+    `
+	result, err := tx.Exec(query, now, s.User.ID, reason, keyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to mark key compromised: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return nil, fmt.Errorf("key #%d was already reported compromised", keyID)
+	}
+
+	var queued []int
+	for _, paper := range report.WrappedPapers {
+		var exists int
+		query := `SELECT COUNT(*) FROM paper_reencryption_queue WHERE paper_id = ? AND key_id = ?`
+		if err := tx.QueryRow(query, paper.ID, keyID).Scan(&exists); err != nil {
+			return nil, fmt.Errorf("failed to check re-encryption queue: %w", err)
+		}
+		if exists > 0 {
+			continue
+		}
+
+		query = `
+            INSERT INTO paper_reencryption_queue (paper_id, key_id, status, requested_by, requested_at)
+            VALUES (?, ?, ?, ?, ?)
+        `
+		if _, err := tx.Exec(query, paper.ID, keyID, ReencryptionPending, s.User.ID, now); err != nil {
+			return nil, fmt.Errorf("failed to queue paper %d for re-encryption: %w", paper.ID, err)
+		}
+		queued = append(queued, paper.ID)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit compromise report: %w", err)
+	}
+
+	// Audit entries are written after the commit; SQLite allows one writer at a time
+	acl.LogAction(s.DB, s.User.ID, "key_compromised", "EncryptionKey", &keyID, true,
+		fmt.Sprintf("key #%d (%s) of %s reported compromised: %s", keyID, key.KeyAlgorithm, report.Username, reason))
+	for _, paper := range report.SignedPapers {
+		acl.LogAction(s.DB, s.User.ID, "signature_flagged", "QuestionPaper", &paper.ID, true,
+			fmt.Sprintf("signed with compromised key #%d; decryption refused until re-signed", keyID))
+	}
+	for _, paperID := range queued {
+		acl.LogAction(s.DB, s.User.ID, "reencryption_queued", "QuestionPaper", &paperID, true,
+			fmt.Sprintf("AES key was wrapped to compromised key #%d", keyID))
+	}
+
+	report.Sessions, err = s.sessionsFor(report.SignedPapers, report.WrappedPapers)
+	if err != nil {
+		return report, err
+	}
+
+	acl.LogAction(s.DB, s.User.ID, "compromise_report", "EncryptionKey", &keyID, true,
+		fmt.Sprintf("%d signed paper(s) flagged, %d paper(s) queued for re-encryption, %d exam session(s) affected",
+			len(report.SignedPapers), len(queued), len(report.Sessions)))
+
+	report.Key.CompromisedAt = &now
+	report.Key.CompromiseReason = reason
+	return report, nil
+}
+
+// papersSignedBy lists papers whose current signature was made with keyID
+func (s *KeyCompromiseService) papersSignedBy(keyID int) ([]AffectedPaper, error) {
+	query := `
+        SELECT id, title, subject, status, exam_date, upload_date
+        FROM question_papers
+        WHERE signer_key_id = ?
+        ORDER BY id
+    `
+	return s.queryAffectedPapers(query, keyID)
+}
+
+// papersWrappedTo lists papers whose AES key (or a share of it) was wrapped to
+// key. Wrapped keys only record their owner, and rotation re-wraps them to each
+// new key, so every paper the owner holds a key for that was uploaded before
+// the key was retired has been wrapped to it. Keys in the pre-recipient
+// question_papers column record no owner at all and are counted for any
+// RSA-2048 key, the only kind they were wrapped to.
+func (s *KeyCompromiseService) papersWrappedTo(key *models.UserKey) ([]AffectedPaper, error) {
+	legacy := key.KeyAlgorithm == "" || key.KeyAlgorithm == crypto.KeyAlgRSA2048
+	query := `
+        SELECT qp.id, qp.title, qp.subject, qp.status, qp.exam_date, qp.upload_date
+        FROM question_papers qp
+        WHERE EXISTS (SELECT 1 FROM paper_key_recipients r WHERE r.paper_id = qp.id AND r.user_id = ?)
+           OR EXISTS (SELECT 1 FROM paper_key_shares ks WHERE ks.paper_id = qp.id AND ks.user_id = ?)
+           OR EXISTS (SELECT 1 FROM paper_share_submissions ss WHERE ss.paper_id = qp.id AND ss.recipient_id = ?)
+           OR (? AND qp.encrypted_aes_key <> '')
+        ORDER BY qp.id
+    `
+	papers, err := s.queryAffectedPapers(query, key.UserID, key.UserID, key.UserID, legacy)
+	if err != nil {
+		return nil, err
+	}
+	if key.RetiredAt == nil {
+		return papers, nil
+	}
+
+	// Upload times are compared here rather than in SQL, where the database
+	// default and a time written from Go may not be stored alike
+	var wrapped []AffectedPaper
+	for _, paper := range papers {
+		if paper.UploadDate.Before(*key.RetiredAt) {
+			wrapped = append(wrapped, paper)
+		}
+	}
+	return wrapped, nil
+}
+
+func (s *KeyCompromiseService) queryAffectedPapers(query string, args ...interface{}) ([]AffectedPaper, error) {
+	rows, err := s.DB.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find affected papers: %w", err)
+	}
+	defer rows.Close()
+
+	var papers []AffectedPaper
+	for rows.Next() {
+		var paper AffectedPaper
+		var examDate sql.NullTime
+		if err := rows.Scan(&paper.ID, &paper.Title, &paper.Subject, &paper.Status, &examDate, &paper.UploadDate); err != nil {
+			return nil, fmt.Errorf("failed to scan paper: %w", err)
+		}
+		if examDate.Valid {
+			paper.ExamDate = examDate.Time
+		}
+		papers = append(papers, paper)
+	}
+	return papers, rows.Err()
+}
+
+// sessionsFor lists scheduled or running sessions of any of the given papers
+func (s *KeyCompromiseService) sessionsFor(paperLists ...[]AffectedPaper) ([]models.ExamSession, error) {
+	var placeholders []string
+	var args []interface{}
+	seen := make(map[int]bool)
+	for _, papers := range paperLists {
+		for _, paper := range papers {
+			if !seen[paper.ID] {
+				seen[paper.ID] = true
+				placeholders = append(placeholders, "?")
+				args = append(args, paper.ID)
+			}
+		}
+	}
+	if len(args) == 0 {
+		return nil, nil
+	}
+
+	query := `
+        SELECT es.id, es.paper_id, qp.title, es.session_name, es.scheduled_time, es.duration_minutes,
+               es.status, es.created_by, es.created_at
+        FROM exam_sessions es
+        JOIN question_papers qp ON es.paper_id = qp.id
+        WHERE es.status IN ('scheduled', 'active') AND es.paper_id IN (` + strings.Join(placeholders, ", ") + `)
+        ORDER BY es.scheduled_time ASC
+    `
+	rows, err := s.DB.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find affected sessions: %w", err)
+	}
+	defer rows.Close()
+
+	var sessions []models.ExamSession
+	for rows.Next() {
+		var session models.ExamSession
+		err := rows.Scan(
+			&session.ID,
+			&session.PaperID,
+			&session.PaperTitle,
+			&session.SessionName,
+			&session.ScheduledTime,
+			&session.DurationMinutes,
+			&session.Status,
+			&session.CreatedBy,
+			&session.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan session: %w", err)
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
+
+// GetPendingReencryptions lists papers queued for a fresh AES key
+func (s *KeyCompromiseService) GetPendingReencryptions() ([]AffectedPaper, error) {
+	if err := acl.EnforcePermission(s.DB, s.User, "QuestionPaper", "read", nil); err != nil {
+		return nil, err
+	}
+
+	query := `
+        SELECT qp.id, qp.title, qp.subject, qp.status, qp.exam_date, qp.upload_date
+        FROM question_papers qp
+        WHERE EXISTS (SELECT 1 FROM paper_reencryption_queue q WHERE q.paper_id = qp.id AND q.status = ?)
+        ORDER BY qp.id
+    `
+	return s.queryAffectedPapers(query, ReencryptionPending)
+}
+
+// signerKeyCompromised reports whether a paper's signing key was reported compromised
+func signerKeyCompromised(db *sql.DB, signerKeyID sql.NullInt64) (bool, error) {
+	if !signerKeyID.Valid {
+		return false, nil
+	}
+	var compromisedAt sql.NullTime
+	err := db.QueryRow(`SELECT compromised_at FROM user_keys WHERE id = ?`, signerKeyID.Int64).Scan(&compromisedAt)
+	if err != nil {
+		return false, fmt.Errorf("failed to check signing key: %w", err)
+	}
+	return compromisedAt.Valid, nil
+}
+
+// ReencryptPaper moves a paper queued after a key compromise to a fresh AES
+// key. The ciphertext is decrypted and re-encrypted chunk by chunk inside the
+// process, the new key is wrapped (or split) for the current ExamCell members
+// and escrowed, and every old wrapped key, share and submitted share is
+// deleted along with the old blob. The new ciphertext is bound to the paper ID
+// like a fresh upload. The plaintext never leaves the process, so the time
+// lock does not apply; threshold papers need their shares submitted first.
+// Nothing is re-sealed unless the plaintext still matches the paper's
+// signature, and a paper whose signing key was compromised must be re-signed
+// first.
+func (ps *PaperService) ReencryptPaper(paperID int, user *models.User) error {
+	if err := acl.EnforcePermission(ps.DB, user, "QuestionPaper", "decrypt", &paperID); err != nil {
+		return err
+	}
+	if user.PrivateKey == nil {
+		return fmt.Errorf("private key is locked; please log in again")
+	}
+	if _, err := activeKeyID(ps.DB, user.ID, user.PrivateKey.Public()); err != nil {
+		return err
+	}
+
+	var pending int
+	query := `SELECT COUNT(*) FROM paper_reencryption_queue WHERE paper_id = ? AND status = ?`
+	if err := ps.DB.QueryRow(query, paperID, ReencryptionPending).Scan(&pending); err != nil {
+		return fmt.Errorf("failed to check re-encryption queue: %w", err)
+	}
+	if pending == 0 {
+		return fmt.Errorf("paper %d is not queued for re-encryption", paperID)
+	}
+
+	// The escrow entry is replaced with one for the new key, so an escrowed paper
+	// cannot be re-encrypted by a process without the escrow key
+	if ps.Escrow == nil {
+		var escrowed int
+		query = `SELECT COUNT(*) FROM paper_key_escrow WHERE paper_id = ?`
+		if err := ps.DB.QueryRow(query, paperID).Scan(&escrowed); err != nil {
+			return fmt.Errorf("failed to check escrowed key: %w", err)
+		}
+		if escrowed > 0 {
+			return fmt.Errorf("paper %d is escrowed but no escrow key is configured; set ESCROW_PUBLIC_KEY_FILE to re-encrypt it", paperID)
+		}
+	}
+
+	var paper struct {
+		Title               string
+		Subject             string
+		EncryptedContentB64 string
+		BlobRef             sql.NullString
+		BlobSize            sql.NullInt64
+		BlobSHA256          sql.NullString
+		ContentFormat       string
+		DigitalSignatureB64 string
+		SignatureAlg        string
+		SignatureScheme     string
+		SignerKeyID         sql.NullInt64
+		FacultyID           int
+		ReleaseThreshold    int
+		ExamDate            sql.NullTime
+		UploadDate          time.Time
+	}
+	query = `
+        SELECT title, subject, encrypted_content, blob_ref, blob_size, blob_sha256, content_format,
+               digital_signature, signature_alg, signature_scheme, signer_key_id, faculty_id, release_threshold,
+               exam_date, upload_date
+        FROM question_papers
+        WHERE id = ?
+    `
+	err := ps.DB.QueryRow(query, paperID).Scan(&paper.Title, &paper.Subject, &paper.EncryptedContentB64,
+		&paper.BlobRef, &paper.BlobSize, &paper.BlobSHA256, &paper.ContentFormat, &paper.DigitalSignatureB64,
+		&paper.SignatureAlg, &paper.SignatureScheme, &paper.SignerKeyID, &paper.FacultyID, &paper.ReleaseThreshold,
+		&paper.ExamDate, &paper.UploadDate)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("paper not found")
+	} else if err != nil {
+		return fmt.Errorf("failed to fetch paper: %w", err)
+	}
+
+	// A signature by a compromised key proves nothing, so it cannot vouch for the plaintext
+	compromised, err := signerKeyCompromised(ps.DB, paper.SignerKeyID)
+	if err != nil {
+		return err
+	}
+	if compromised {
+		return fmt.Errorf("paper was signed with a key reported compromised; its faculty must re-sign it before it can be re-encrypted")
+	}
+	signature, err := crypto.DecodeBase64(paper.DigitalSignatureB64)
+	if err != nil {
+		return fmt.Errorf("failed to decode signature: %w", err)
+	}
+	facultyPublicKey, err := signerPublicKey(ps.DB, paper.FacultyID, paper.SignerKeyID)
+	if err != nil {
+		return err
+	}
+
+	oldKey, err := ps.recoverPaperKey(paperID, paper.ReleaseThreshold, user)
+	if err != nil {
+		return err
+	}
+
+	ciphertext, err := ps.openCiphertext(paper.EncryptedContentB64, paper.BlobRef, paper.BlobSize, paper.BlobSHA256)
+	if err != nil {
+		return err
+	}
+	defer ciphertext.Close()
+
+	newKey, err := crypto.GenerateAESKey()
+	if err != nil {
+		return fmt.Errorf("failed to generate AES key: %w", err)
+	}
+
+	// Plaintext flows from the decrypting goroutine straight into the encrypting writer
+	additionalData := crypto.PaperAdditionalData(paperID)
+	pr, pw := io.Pipe()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, err := crypto.DecryptContent(paper.ContentFormat, ciphertext, oldKey, additionalData, pw)
+		pw.CloseWithError(err)
+	}()
+	blob, _, digest, err := ps.encryptToBlob(pr, newKey, additionalData)
+	pr.Close()
+	<-done
+	if err != nil {
+		return err
+	}
+
+	envelope := crypto.PaperEnvelope{
+		PaperID:       paperID,
+		ContentSHA256: digest,
+		Title:         paper.Title,
+		Subject:       paper.Subject,
+		ExamDate:      paper.ExamDate.Time,
+		FacultyID:     paper.FacultyID,
+		UploadedAt:    paper.UploadDate,
+	}
+	signedDigest, err := envelope.SignedDigest(paper.SignatureScheme)
+	if err == nil {
+		err = facultyPublicKey.VerifyDigest(paper.SignatureAlg, signedDigest, signature)
+	}
+	if err != nil {
+		if err := ps.blobs().Delete(blob.Ref); err != nil {
+			log.Printf("Failed to delete unused blob %s of paper %d: %v", blob.Ref, paperID, err)
+		}
+		acl.LogAction(ps.DB, user.ID, "paper_reencrypted", "QuestionPaper", &paperID, false,
+			"plaintext does not match the paper's signature")
+		return fmt.Errorf("signature verification failed: %w", err)
+	}
+
+	recipients, err := getExamCellRecipients(ps.DB)
+	if err != nil {
+		return err
+	}
+	var wrapped []WrappedKey
+	if paper.ReleaseThreshold > 0 {
+		wrapped, err = splitKeyForRecipients(newKey, recipients, paper.ReleaseThreshold)
+	} else {
+		wrapped, err = wrapKeyForRecipients(newKey, recipients)
+	}
+	if err != nil {
+		return err
+	}
+
+	tx, err := ps.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query = `
+        UPDATE question_papers
+        SET encrypted_content = '', encrypted_aes_key = '', blob_ref = ?, blob_size = ?, blob_sha256 = ?, content_format = ?
+        WHERE id = ?
+    `
+	if _, err := tx.Exec(query, blob.Ref, blob.Size, blob.SHA256, ContentFormatStream, paperID); err != nil {
+		return fmt.Errorf("failed to update paper: %w", err)
+	}
+	for _, t := range wrappedKeyTables {
+		if _, err := tx.Exec(fmt.Sprintf(`DELETE FROM %s WHERE paper_id = ?`, t.table), paperID); err != nil {
+			return fmt.Errorf("failed to remove old keys from %s: %w", t.table, err)
+		}
+	}
+	if paper.ReleaseThreshold > 0 {
+		err = storeKeyShares(tx, int64(paperID), wrapped)
+	} else {
+		err = storeKeyRecipients(tx, int64(paperID), wrapped)
+	}
+	if err != nil {
+		return err
+	}
+	// The old escrow entry opens only the old ciphertext; replace it
+	if err := storeEscrowKey(tx, int64(paperID), newKey, ps.Escrow); err != nil {
+		return err
+	}
+
+	query = `
+        UPDATE paper_reencryption_queue SET status = ?, completed_by = ?, completed_at = ?
+        WHERE paper_id = ? AND status = ?
+    `
+	if _, err := tx.Exec(query, ReencryptionDone, user.ID, time.Now(), paperID, ReencryptionPending); err != nil {
+		return fmt.Errorf("failed to update re-encryption queue: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit re-encrypted paper: %w", err)
+	}
+
+	// The old ciphertext opens with the exposed key; drop it unless another paper shares it
+	if paper.BlobRef.Valid && paper.BlobRef.String != blob.Ref {
+		var users int
+		err := ps.DB.QueryRow(`SELECT COUNT(*) FROM question_papers WHERE blob_ref = ?`, paper.BlobRef.String).Scan(&users)
+		if err == nil && users == 0 {
+			if err := ps.blobs().Delete(paper.BlobRef.String); err != nil {
+				log.Printf("Failed to delete old blob %s of paper %d: %v", paper.BlobRef.String, paperID, err)
+			}
+		}
+	}
+
+	acl.LogAction(ps.DB, user.ID, "paper_reencrypted", "QuestionPaper", &paperID, true,
+		fmt.Sprintf("re-encrypted under a fresh AES key for %d recipient(s) (%s)", len(wrapped), wrapAlgorithms(wrapped)))
+	return nil
+}
+
+// ResignPaper replaces the signature of one of the faculty's papers whose
+// signing key was reported compromised. The faculty supplies the original
+// file; it must still match the old signature, so a wrong file cannot lock
+// the paper, and the paper's envelope is then signed with their current key.
+// Papers that predate signed metadata get an envelope signature too.
+func (ps *PaperService) ResignPaper(paperID int, faculty *models.User, content io.Reader) error {
+	if err := acl.EnforcePermission(ps.DB, faculty, "QuestionPaper", "create", &paperID); err != nil {
+		return err
+	}
+	if faculty.PrivateKey == nil {
+		return fmt.Errorf("faculty private key is locked; please log in again")
+	}
+	keyID, err := activeKeyID(ps.DB, faculty.ID, faculty.PrivateKey.Public())
+	if err != nil {
+		return err
+	}
+
+	var facultyID int
+	var oldSignature, oldSignatureAlg, oldScheme string
+	var signerKeyID sql.NullInt64
+	envelope := crypto.PaperEnvelope{PaperID: paperID}
+	var examDate sql.NullTime
+	query := `
+        SELECT faculty_id, digital_signature, signature_alg, signature_scheme, signer_key_id,
+               title, subject, exam_date, upload_date
+        FROM question_papers
+        WHERE id = ?
+    `
+	err = ps.DB.QueryRow(query, paperID).Scan(&facultyID, &oldSignature, &oldSignatureAlg, &oldScheme, &signerKeyID,
+		&envelope.Title, &envelope.Subject, &examDate, &envelope.UploadedAt)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("paper not found")
+	} else if err != nil {
+		return fmt.Errorf("failed to fetch paper: %w", err)
+	}
+	if facultyID != faculty.ID {
+		return fmt.Errorf("you can only re-sign your own papers")
+	}
+	compromised, err := signerKeyCompromised(ps.DB, signerKeyID)
+	if err != nil {
+		return err
+	}
+	if !compromised {
+		return fmt.Errorf("paper %d was not signed with a compromised key", paperID)
+	}
+
+	digest := sha256.New()
+	size, err := io.Copy(digest, content)
+	if err != nil {
+		return fmt.Errorf("failed to read paper: %w", err)
+	}
+	if size == 0 {
+		return fmt.Errorf("paper content cannot be empty")
+	}
+	envelope.ContentSHA256 = digest.Sum(nil)
+	envelope.ExamDate = examDate.Time
+	envelope.FacultyID = facultyID
+	oldDigest, err := envelope.SignedDigest(oldScheme)
+	if err != nil {
+		return err
+	}
+	newDigest, err := envelope.SignedDigest(crypto.SignatureSchemeEnvelope)
+	if err != nil {
+		return err
+	}
+
+	oldPublicKey, err := signerPublicKey(ps.DB, facultyID, signerKeyID)
+	if err != nil {
+		return err
+	}
+	oldSignatureBytes, err := crypto.DecodeBase64(oldSignature)
+	if err != nil {
+		return fmt.Errorf("failed to decode signature: %w", err)
+	}
+	if err := oldPublicKey.VerifyDigest(oldSignatureAlg, oldDigest, oldSignatureBytes); err != nil {
+		acl.LogAction(ps.DB, faculty.ID, "paper_resigned", "QuestionPaper", &paperID, false,
+			"supplied file does not match the paper's signature")
+		return fmt.Errorf("the file or the paper's details do not match the paper as uploaded")
+	}
+
+	signature, signatureAlg, err := faculty.PrivateKey.SignDigest(newDigest)
+	if err != nil {
+		return fmt.Errorf("failed to create signature: %w", err)
+	}
+
+	query = `
+        UPDATE question_papers SET digital_signature = ?, signature_alg = ?, signature_scheme = ?, signer_key_id = ?
+        WHERE id = ?
+    `
+	_, err = ps.DB.Exec(query, crypto.EncodeBase64(signature), signatureAlg, crypto.SignatureSchemeEnvelope, keyID, paperID)
+	if err != nil {
+		return fmt.Errorf("failed to store signature: %w", err)
+	}
+
+	acl.LogAction(ps.DB, faculty.ID, "paper_resigned", "QuestionPaper", &paperID, true,
+		fmt.Sprintf("re-signed with key #%d (%s)", keyID, signatureAlg))
+	return nil
+}
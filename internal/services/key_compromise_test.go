@@ -0,0 +1,127 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/internal/crypto"
+	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/internal/database/dbtest"
+	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/pkg/blobstore"
+)
+
+func TestReencryptPaperVerifiesSignature(t *testing.T) {
+	db := dbtest.Open(t)
+	examCell := registerUnlocked(t, db, "examcell", "ExamCell")
+	faculty := registerUnlocked(t, db, "faculty", "Faculty")
+
+	ps := &PaperService{DB: db, Policy: &ReleasePolicy{DB: db, Window: 30 * time.Minute}, Blobs: blobstore.NewFileStore(t.TempDir())}
+	examDate := time.Now().AddDate(0, 0, 7).UTC().Truncate(24 * time.Hour)
+	paperID, err := ps.UploadPaperContent(faculty, "Final", "Physics", []byte("Q1. Define momentum."), examDate)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var keyID int
+	if err := db.QueryRow(`SELECT id FROM user_keys WHERE user_id = ?`, examCell.ID).Scan(&keyID); err != nil {
+		t.Fatal(err)
+	}
+	dbtest.Exec(t, db, `INSERT INTO paper_reencryption_queue (paper_id, key_id, status, requested_by, requested_at)
+        VALUES (?, ?, ?, ?, ?)`, paperID, keyID, ReencryptionPending, examCell.ID, time.Now())
+
+	blobRef := func() string {
+		var ref string
+		if err := db.QueryRow(`SELECT blob_ref FROM question_papers WHERE id = ?`, paperID).Scan(&ref); err != nil {
+			t.Fatal(err)
+		}
+		return ref
+	}
+	original := blobRef()
+
+	otherID, err := ps.UploadPaperContent(faculty, "Mock", "Physics", []byte("Q1. Define inertia."), examDate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signatureOf := func(id int) string {
+		var signature string
+		if err := db.QueryRow(`SELECT digital_signature FROM question_papers WHERE id = ?`, id).Scan(&signature); err != nil {
+			t.Fatal(err)
+		}
+		return signature
+	}
+	signature := signatureOf(paperID)
+
+	// The signature no longer matches the content: nothing is re-sealed
+	dbtest.Exec(t, db, `UPDATE question_papers SET digital_signature = ? WHERE id = ?`, signatureOf(otherID), paperID)
+	if err := ps.ReencryptPaper(paperID, examCell); err == nil {
+		t.Fatal("paper re-encrypted although it no longer matches its signature")
+	}
+	if blobRef() != original {
+		t.Fatal("unverified paper was re-sealed")
+	}
+
+	dbtest.Exec(t, db, `UPDATE question_papers SET digital_signature = ? WHERE id = ?`, signature, paperID)
+	if err := ps.ReencryptPaper(paperID, examCell); err != nil {
+		t.Fatal(err)
+	}
+	if blobRef() == original {
+		t.Fatal("paper still points at the old ciphertext")
+	}
+	var status string
+	if err := db.QueryRow(`SELECT status FROM paper_reencryption_queue WHERE paper_id = ?`, paperID).Scan(&status); err != nil {
+		t.Fatal(err)
+	}
+	if status != ReencryptionDone {
+		t.Fatalf("queue status = %q, want %q", status, ReencryptionDone)
+	}
+}
+
+func TestReencryptPaperKeepsEscrow(t *testing.T) {
+	db := dbtest.Open(t)
+	examCell := registerUnlocked(t, db, "examcell", "ExamCell")
+	faculty := registerUnlocked(t, db, "faculty", "Faculty")
+
+	escrowKey, err := crypto.GenerateKeyPair(crypto.KeyAlgX25519Ed25519)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fingerprint, err := crypto.PublicKeyFingerprint(escrowKey.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	escrow := &EscrowKey{PublicKey: escrowKey.Public(), Fingerprint: fingerprint}
+
+	ps := &PaperService{DB: db, Policy: &ReleasePolicy{DB: db, Window: 30 * time.Minute},
+		Blobs: blobstore.NewFileStore(t.TempDir()), Escrow: escrow}
+	examDate := time.Now().AddDate(0, 0, 7).UTC().Truncate(24 * time.Hour)
+	paperID, err := ps.UploadPaperContent(faculty, "Final", "Physics", []byte("Q1. Define momentum."), examDate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dbtest.Exec(t, db, `INSERT INTO paper_reencryption_queue (paper_id, key_id, status, requested_by, requested_at)
+        SELECT ?, id, ?, ?, ? FROM user_keys WHERE user_id = ?`, paperID, ReencryptionPending, examCell.ID, time.Now(), examCell.ID)
+
+	escrowedKey := func() string {
+		var key string
+		if err := db.QueryRow(`SELECT encrypted_aes_key FROM paper_key_escrow WHERE paper_id = ?`, paperID).Scan(&key); err != nil {
+			t.Fatal(err)
+		}
+		return key
+	}
+	original := escrowedKey()
+
+	// A process without the escrow key must not drop the paper's escrow entry
+	unescrowed := &PaperService{DB: db, Policy: ps.Policy, Blobs: ps.Blobs}
+	if err := unescrowed.ReencryptPaper(paperID, examCell); err == nil {
+		t.Fatal("escrowed paper re-encrypted without the escrow key")
+	}
+	if escrowedKey() != original {
+		t.Fatal("escrow entry changed by a refused re-encryption")
+	}
+
+	if err := ps.ReencryptPaper(paperID, examCell); err != nil {
+		t.Fatal(err)
+	}
+	if escrowedKey() == original {
+		t.Fatal("escrow entry still holds the old paper key")
+	}
+}
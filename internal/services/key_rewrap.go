@@ -0,0 +1,150 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+
+	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/internal/acl"
+	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/internal/crypto"
+	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/internal/models"
+)
+
+// wrappedKeyTable names a table of keys wrapped for a user and its columns
+type wrappedKeyTable struct {
+	table     string
+	keyColumn string
+	ownerCol  string
+}
+
+var wrappedKeyTables = []wrappedKeyTable{
+	{"paper_key_recipients", "encrypted_aes_key", "user_id"},
+	{"paper_key_shares", "encrypted_share", "user_id"},
+	{"paper_share_submissions", "encrypted_share", "recipient_id"},
+}
+
+// RewrapLegacyKeys re-encrypts every PKCS#1 v1.5 key and key share wrapped
+// for user with RSA-OAEP, returning how many were upgraded. Only the holder's
+// private key can open them, so this runs for each member after login.
+// Keys in the pre-recipient question_papers.encrypted_aes_key column carry no
+// owner; those the user's key opens are moved into paper_key_recipients under
+// OAEP and the legacy column is cleared.
+func RewrapLegacyKeys(db *sql.DB, user *models.User) (int, error) {
+	if user.PrivateKey == nil {
+		return 0, fmt.Errorf("private key is locked")
+	}
+	// Only RSA keys ever had PKCS#1 v1.5 wraps
+	if _, isRSA := user.PrivateKey.(crypto.RSAPrivateKey); !isRSA {
+		return 0, nil
+	}
+
+	upgraded := 0
+	for _, t := range wrappedKeyTables {
+		n, err := rewrapTable(db, user, t)
+		upgraded += n
+		if err != nil {
+			return upgraded, err
+		}
+	}
+
+	n, err := adoptOwnLegacyPaperKeys(db, user)
+	upgraded += n
+	if err != nil {
+		return upgraded, err
+	}
+
+	if upgraded > 0 {
+		acl.LogAction(db, user.ID, "rewrap_keys", "EncryptionKey", nil, true,
+			fmt.Sprintf("%d wrapped key(s) upgraded to %s", upgraded, crypto.KeyWrapOAEP))
+	}
+	return upgraded, nil
+}
+
+func rewrapTable(db *sql.DB, user *models.User, t wrappedKeyTable) (int, error) {
+	query := fmt.Sprintf(`SELECT id, %s FROM %s WHERE %s = ? AND key_wrap = ?`, t.keyColumn, t.table, t.ownerCol)
+	rows, err := db.Query(query, user.ID, crypto.KeyWrapPKCS1v15)
+	if err != nil {
+		return 0, fmt.Errorf("failed to find legacy keys in %s: %w", t.table, err)
+	}
+
+	type legacyKey struct {
+		id      int
+		wrapped string
+	}
+	var keys []legacyKey
+	for rows.Next() {
+		var key legacyKey
+		if err := rows.Scan(&key.id, &key.wrapped); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		keys = append(keys, key)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	update := fmt.Sprintf(`UPDATE %s SET %s = ?, key_wrap = ? WHERE id = ? AND key_wrap = ?`, t.table, t.keyColumn)
+	upgraded := 0
+	for _, key := range keys {
+		ciphertext, err := crypto.DecodeBase64(key.wrapped)
+		if err != nil {
+			return upgraded, fmt.Errorf("failed to decode %s #%d: %w", t.table, key.id, err)
+		}
+		plaintext, err := user.PrivateKey.Unwrap(crypto.KeyWrapPKCS1v15, ciphertext)
+		if err != nil {
+			return upgraded, fmt.Errorf("failed to open %s #%d: %w", t.table, key.id, err)
+		}
+		rewrapped, keyWrap, err := user.PrivateKey.Public().Wrap(plaintext)
+		if err != nil {
+			return upgraded, err
+		}
+
+		result, err := db.Exec(update, crypto.EncodeBase64(rewrapped), keyWrap, key.id, crypto.KeyWrapPKCS1v15)
+		if err != nil {
+			return upgraded, fmt.Errorf("failed to update %s #%d: %w", t.table, key.id, err)
+		}
+		if n, _ := result.RowsAffected(); n == 1 {
+			upgraded++
+		}
+	}
+	return upgraded, nil
+}
+
+// adoptOwnLegacyPaperKeys moves the legacy paper keys the user's key opens
+// into paper_key_recipients, re-wrapped for the same key
+func adoptOwnLegacyPaperKeys(db *sql.DB, user *models.User) (int, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	adopted, err := adoptLegacyPaperKeys(tx, user.ID, user.PrivateKey, user.PrivateKey.Public())
+	if err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit legacy paper keys: %w", err)
+	}
+	return adopted, nil
+}
+
+// StartKeyRewrap upgrades the user's legacy wrapped keys in the background
+// after login, logging the outcome
+func StartKeyRewrap(db *sql.DB, user *models.User) {
+	if user.PrivateKey == nil {
+		return
+	}
+	// Work on a copy so the caller may clear its key (e.g. at logout) meanwhile
+	snapshot := *user
+	go func() {
+		n, err := RewrapLegacyKeys(db, &snapshot)
+		if err != nil {
+			log.Printf("Key re-wrap for %s stopped after %d key(s): %v", snapshot.Username, n, err)
+		} else if n > 0 {
+			log.Printf("Upgraded %d wrapped key(s) for %s to RSA-OAEP", n, snapshot.Username)
+		}
+	}()
+}
@@ -0,0 +1,82 @@
+package services
+
+import (
+	"bytes"
+	"crypto/rand"
+	stdrsa "crypto/rsa"
+	"testing"
+	"time"
+
+	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/internal/crypto"
+	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/internal/database/dbtest"
+)
+
+func TestRewrapAdoptsLegacyPaperKeys(t *testing.T) {
+	db := dbtest.Open(t)
+	paperID, member, other := seedPaper(t, db, time.Now().AddDate(0, 0, 7))
+
+	key, err := crypto.GenerateKeyPair(crypto.KeyAlgRSA2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := crypto.GenerateKeyPair(crypto.KeyAlgRSA2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	member.PrivateKey = key
+	other.PrivateKey = otherKey
+
+	// A paper key wrapped before per-recipient keys, with PKCS#1 v1.5
+	aesKey, err := crypto.GenerateAESKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacy, err := stdrsa.EncryptPKCS1v15(rand.Reader, &key.(crypto.RSAPrivateKey).PublicKey, aesKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dbtest.Exec(t, db, `UPDATE question_papers SET encrypted_aes_key = ? WHERE id = ?`, crypto.EncodeBase64(legacy), paperID)
+
+	// Someone else's key cannot open it, so it stays where it is
+	if n, err := RewrapLegacyKeys(db, other); err != nil || n != 0 {
+		t.Fatalf("other member adopted %d key(s): %v", n, err)
+	}
+
+	n, err := RewrapLegacyKeys(db, member)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Fatalf("adopted %d legacy key(s), want 1", n)
+	}
+
+	var remaining, wrapped, keyWrap string
+	if err := db.QueryRow(`SELECT encrypted_aes_key FROM question_papers WHERE id = ?`, paperID).Scan(&remaining); err != nil {
+		t.Fatal(err)
+	}
+	if remaining != "" {
+		t.Fatal("legacy key column not cleared")
+	}
+	query := `SELECT encrypted_aes_key, key_wrap FROM paper_key_recipients WHERE paper_id = ? AND user_id = ?`
+	if err := db.QueryRow(query, paperID, member.ID).Scan(&wrapped, &keyWrap); err != nil {
+		t.Fatal(err)
+	}
+	if keyWrap != crypto.KeyWrapOAEP {
+		t.Fatalf("adopted key wrapped with %s, want %s", keyWrap, crypto.KeyWrapOAEP)
+	}
+	ciphertext, err := crypto.DecodeBase64(wrapped)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opened, err := key.Unwrap(keyWrap, ciphertext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(opened, aesKey) {
+		t.Fatal("adopted key does not match the paper key")
+	}
+
+	if n, err := RewrapLegacyKeys(db, member); err != nil || n != 0 {
+		t.Fatalf("second run upgraded %d key(s): %v", n, err)
+	}
+}
@@ -0,0 +1,287 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/internal/acl"
+	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/internal/auth"
+	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/internal/crypto"
+	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/internal/models"
+)
+
+// KeyRotation summarises a completed key rotation
+type KeyRotation struct {
+	KeyID        int // user_keys ID of the new key pair
+	OldAlgorithm string
+	NewAlgorithm string
+	Rewrapped    int // paper keys and key shares re-wrapped to the new key
+	Adopted      int // legacy question_papers keys moved into paper_key_recipients
+}
+
+// RotateUserKeys replaces the user's key pair with a new one of the named
+// algorithm (empty keeps the current suite). The password unlocks the current
+// private key and protects the new one. Every paper key and key share wrapped
+// for the user is re-wrapped to the new key in the transaction that retires
+// the old one, so a failure leaves the old key pair in place. The retired
+// public key stays in user_keys so papers it signed still verify.
+func RotateUserKeys(db *sql.DB, user *models.User, password, algorithm string) (*KeyRotation, error) {
+	rotation, newKey, err := rotateUserKeys(db, user, password, algorithm)
+	if err != nil {
+		acl.LogAction(db, user.ID, "rotate_keys", "EncryptionKey", nil, false, err.Error())
+		return nil, err
+	}
+
+	acl.LogAction(db, user.ID, "rotate_keys", "EncryptionKey", &rotation.KeyID, true,
+		fmt.Sprintf("%s key pair replaced with %s; %d wrapped key(s) re-wrapped, %d legacy key(s) adopted",
+			rotation.OldAlgorithm, rotation.NewAlgorithm, rotation.Rewrapped, rotation.Adopted))
+
+	user.PrivateKey = newKey
+	return rotation, nil
+}
+
+func rotateUserKeys(db *sql.DB, user *models.User, password, algorithm string) (*KeyRotation, crypto.PrivateKey, error) {
+	if user.Role != "Faculty" && user.Role != "ExamCell" {
+		return nil, nil, fmt.Errorf("only Faculty and ExamCell accounts have key pairs")
+	}
+
+	// Unlock the stored key rather than trusting the session's copy, which
+	// also proves the password before anything is changed
+	var privateKeyPEM sql.NullString
+	err := db.QueryRow(`SELECT private_key_encrypted FROM users WHERE id = ?`, user.ID).Scan(&privateKeyPEM)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load private key: %w", err)
+	}
+	if !privateKeyPEM.Valid || !crypto.IsEncryptedPrivateKeyPEM(privateKeyPEM.String) {
+		return nil, nil, fmt.Errorf("no password-encrypted key pair found for %s; log in once before rotating", user.Username)
+	}
+	oldKey, err := crypto.DecryptPrivateKeyFromPEM(privateKeyPEM.String, password)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if algorithm == "" {
+		algorithm = oldKey.Algorithm()
+	} else if algorithm, err = crypto.ParseKeyAlgorithm(algorithm); err != nil {
+		return nil, nil, err
+	}
+
+	newKey, err := crypto.GenerateKeyPair(algorithm)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate key pair: %w", err)
+	}
+	newPrivateKeyPEM, err := crypto.EncryptPrivateKeyToPEM(newKey, password)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encrypt private key: %w", err)
+	}
+	newPublicKeyPEM, err := crypto.EncodePublicKey(newKey.Public())
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode public key: %w", err)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	rotation := &KeyRotation{OldAlgorithm: oldKey.Algorithm(), NewAlgorithm: algorithm}
+	for _, t := range wrappedKeyTables {
+		n, err := rewrapTableTo(tx, user.ID, t, oldKey, newKey.Public())
+		if err != nil {
+			return nil, nil, err
+		}
+		rotation.Rewrapped += n
+	}
+
+	rotation.Adopted, err = adoptLegacyPaperKeys(tx, user.ID, oldKey, newKey.Public())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	query := `UPDATE users SET public_key = ?, private_key_encrypted = ?, key_algorithm = ? WHERE id = ?`
+	if _, err := tx.Exec(query, newPublicKeyPEM, newPrivateKeyPEM, algorithm, user.ID); err != nil {
+		return nil, nil, fmt.Errorf("failed to store keys: %w", err)
+	}
+	keyID, err := auth.RecordActiveKey(tx, user.ID, newPublicKeyPEM, algorithm)
+	if err != nil {
+		return nil, nil, err
+	}
+	rotation.KeyID = int(keyID)
+
+	if err := tx.Commit(); err != nil {
+		return nil, nil, fmt.Errorf("failed to commit key rotation: %w", err)
+	}
+
+	user.PublicKey = newPublicKeyPEM
+	user.PrivateKeyEncrypted = newPrivateKeyPEM
+	user.KeyAlgorithm = algorithm
+	return rotation, newKey, nil
+}
+
+// rewrapTableTo re-wraps every key in t owned by ownerID from oldKey to newPublic
+func rewrapTableTo(tx *sql.Tx, ownerID int, t wrappedKeyTable, oldKey crypto.PrivateKey, newPublic crypto.PublicKey) (int, error) {
+	query := fmt.Sprintf(`SELECT id, %s, key_wrap FROM %s WHERE %s = ?`, t.keyColumn, t.table, t.ownerCol)
+	rows, err := tx.Query(query, ownerID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to find wrapped keys in %s: %w", t.table, err)
+	}
+
+	type wrappedRow struct {
+		id      int
+		wrapped string
+		keyWrap string
+	}
+	var keys []wrappedRow
+	for rows.Next() {
+		var key wrappedRow
+		if err := rows.Scan(&key.id, &key.wrapped, &key.keyWrap); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		keys = append(keys, key)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	update := fmt.Sprintf(`UPDATE %s SET %s = ?, key_wrap = ? WHERE id = ?`, t.table, t.keyColumn)
+	for _, key := range keys {
+		ciphertext, err := crypto.DecodeBase64(key.wrapped)
+		if err != nil {
+			return 0, fmt.Errorf("failed to decode %s #%d: %w", t.table, key.id, err)
+		}
+		plaintext, err := oldKey.Unwrap(key.keyWrap, ciphertext)
+		if err != nil {
+			return 0, fmt.Errorf("failed to open %s #%d with the current key: %w", t.table, key.id, err)
+		}
+		rewrapped, keyWrap, err := newPublic.Wrap(plaintext)
+		if err != nil {
+			return 0, err
+		}
+		if _, err := tx.Exec(update, crypto.EncodeBase64(rewrapped), keyWrap, key.id); err != nil {
+			return 0, fmt.Errorf("failed to update %s #%d: %w", t.table, key.id, err)
+		}
+	}
+	return len(keys), nil
+}
+
+// adoptLegacyPaperKeys moves keys in the pre-recipient question_papers
+// column that oldKey opens into paper_key_recipients under newPublic, and
+// clears the column. Those keys record no owner, so opening them is the only
+// way to tell they were wrapped for this user; left alone, the retired key
+// would still open them.
+func adoptLegacyPaperKeys(tx *sql.Tx, userID int, oldKey crypto.PrivateKey, newPublic crypto.PublicKey) (int, error) {
+	// Only RSA keys were used before per-recipient wrapping
+	if _, isRSA := oldKey.(crypto.RSAPrivateKey); !isRSA {
+		return 0, nil
+	}
+
+	rows, err := tx.Query(`SELECT id, encrypted_aes_key FROM question_papers WHERE encrypted_aes_key <> ''`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to find legacy paper keys: %w", err)
+	}
+
+	type legacyKey struct {
+		paperID int64
+		wrapped string
+	}
+	var keys []legacyKey
+	for rows.Next() {
+		var key legacyKey
+		if err := rows.Scan(&key.paperID, &key.wrapped); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		keys = append(keys, key)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	adopted := 0
+	for _, key := range keys {
+		ciphertext, err := crypto.DecodeBase64(key.wrapped)
+		if err != nil {
+			return adopted, fmt.Errorf("failed to decode key of paper %d: %w", key.paperID, err)
+		}
+		aesKey, err := oldKey.Unwrap(crypto.KeyWrapPKCS1v15, ciphertext)
+		if err != nil {
+			continue // wrapped for someone else
+		}
+
+		var existing int
+		query := `SELECT COUNT(*) FROM paper_key_recipients WHERE paper_id = ? AND user_id = ?`
+		if err := tx.QueryRow(query, key.paperID, userID).Scan(&existing); err != nil {
+			return adopted, fmt.Errorf("failed to check wrapped keys of paper %d: %w", key.paperID, err)
+		}
+		if existing == 0 {
+			rewrapped, keyWrap, err := newPublic.Wrap(aesKey)
+			if err != nil {
+				return adopted, err
+			}
+			wrapped := []WrappedKey{{UserID: userID, EncryptedKey: rewrapped, Algorithm: keyWrap}}
+			if err := storeKeyRecipients(tx, key.paperID, wrapped); err != nil {
+				return adopted, err
+			}
+		}
+
+		if _, err := tx.Exec(`UPDATE question_papers SET encrypted_aes_key = '' WHERE id = ?`, key.paperID); err != nil {
+			return adopted, fmt.Errorf("failed to clear legacy key of paper %d: %w", key.paperID, err)
+		}
+		adopted++
+	}
+	return adopted, nil
+}
+
+// activeKeyID returns the user_keys ID of the key pair publicKey belongs to,
+// refusing keys that have been rotated out (e.g. one unlocked by a session
+// that predates the rotation) or reported compromised
+func activeKeyID(db *sql.DB, userID int, publicKey crypto.PublicKey) (int, error) {
+	publicKeyPEM, err := crypto.EncodePublicKey(publicKey)
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode public key: %w", err)
+	}
+
+	var keyID int
+	var status string
+	var compromisedAt sql.NullTime
+	query := `SELECT id, status, compromised_at FROM user_keys WHERE user_id = ? AND public_key = ?`
+	err = db.QueryRow(query, userID, publicKeyPEM).Scan(&keyID, &status, &compromisedAt)
+	if err == sql.ErrNoRows {
+		return 0, fmt.Errorf("your key pair is not on record; please log in again")
+	} else if err != nil {
+		return 0, fmt.Errorf("failed to look up key pair: %w", err)
+	}
+	if status != auth.KeyStatusActive {
+		return 0, fmt.Errorf("your key pair has been rotated; please log in again")
+	}
+	if compromisedAt.Valid {
+		return 0, fmt.Errorf("your key pair was reported compromised; rotate it before continuing")
+	}
+	return keyID, nil
+}
+
+// signerPublicKey loads the public key a paper was signed with. Papers from
+// before the key history have no signer key and use the faculty's current key.
+func signerPublicKey(db *sql.DB, facultyID int, signerKeyID sql.NullInt64) (crypto.PublicKey, error) {
+	var publicKeyPEM string
+	var err error
+	if signerKeyID.Valid {
+		query := `SELECT public_key FROM user_keys WHERE id = ? AND user_id = ?`
+		err = db.QueryRow(query, signerKeyID.Int64, facultyID).Scan(&publicKeyPEM)
+	} else {
+		err = db.QueryRow(`SELECT public_key FROM users WHERE id = ?`, facultyID).Scan(&publicKeyPEM)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get faculty public key: %w", err)
+	}
+
+	publicKey, err := crypto.ParsePublicKey(publicKeyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode faculty public key: %w", err)
+	}
+	return publicKey, nil
+}
@@ -0,0 +1,235 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/internal/acl"
+	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/internal/crypto"
+	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/internal/models"
+)
+
+// splitKeyForRecipients splits an AES key into one Shamir share per recipient
+// and wraps each share with that recipient's public key
+func splitKeyForRecipients(aesKey []byte, recipients []KeyRecipient, threshold int) ([]WrappedKey, error) {
+	if threshold > len(recipients) {
+		return nil, fmt.Errorf("threshold %d exceeds the number of ExamCell members (%d)", threshold, len(recipients))
+	}
+
+	shares, err := crypto.SplitSecret(aesKey, len(recipients), threshold)
+	if err != nil {
+		return nil, fmt.Errorf("failed to split AES key: %w", err)
+	}
+
+	wrapped := make([]WrappedKey, 0, len(recipients))
+	for i, recipient := range recipients {
+		encryptedShare, keyWrap, err := recipient.PublicKey.Wrap(shares[i])
+		if err != nil {
+			return nil, fmt.Errorf("failed to wrap share for %s: %w", recipient.Username, err)
+		}
+		wrapped = append(wrapped, WrappedKey{UserID: recipient.UserID, EncryptedKey: encryptedShare, Algorithm: keyWrap})
+	}
+	return wrapped, nil
+}
+
+// storeKeyShares writes one wrapped key share row per ExamCell member
+func storeKeyShares(tx *sql.Tx, paperID int64, wrapped []WrappedKey) error {
+	query := `INSERT INTO paper_key_shares (paper_id, user_id, encrypted_share, key_wrap) VALUES (?, ?, ?, ?)`
+	for _, share := range wrapped {
+		_, err := tx.Exec(query, paperID, share.UserID, crypto.EncodeBase64(share.EncryptedKey), share.Algorithm)
+		if err != nil {
+			return fmt.Errorf("failed to store key share: %w", err)
+		}
+	}
+	return nil
+}
+
+// getShareHolders loads the public keys of everyone holding a share of a paper's key
+func getShareHolders(db *sql.DB, paperID int) ([]KeyRecipient, error) {
+	query := `
+        SELECT u.id, u.username, u.public_key, u.key_algorithm
+        FROM paper_key_shares pks
+        JOIN users u ON pks.user_id = u.id
+        WHERE pks.paper_id = ?
+        ORDER BY u.id
+    `
+
+	rows, err := db.Query(query, paperID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch share holders: %w", err)
+	}
+	defer rows.Close()
+
+	var holders []KeyRecipient
+	for rows.Next() {
+		var holder KeyRecipient
+		var publicKeyPEM string
+		var keyAlgorithm sql.NullString
+
+		if err := rows.Scan(&holder.UserID, &holder.Username, &publicKeyPEM, &keyAlgorithm); err != nil {
+			return nil, fmt.Errorf("failed to scan share holder: %w", err)
+		}
+
+		holder.PublicKey, err = parseRecipientKey(holder.Username, publicKeyPEM, keyAlgorithm)
+		if err != nil {
+			return nil, err
+		}
+
+		holders = append(holders, holder)
+	}
+
+	return holders, nil
+}
+
+// GetShareStatus reports how many key shares have been submitted for a paper
+func (ps *PaperService) GetShareStatus(paperID int) (submitted int, threshold int, err error) {
+	err = ps.DB.QueryRow(`SELECT release_threshold FROM question_papers WHERE id = ?`, paperID).Scan(&threshold)
+	if err == sql.ErrNoRows {
+		return 0, 0, fmt.Errorf("paper not found")
+	} else if err != nil {
+		return 0, 0, fmt.Errorf("failed to fetch paper: %w", err)
+	}
+
+	query := `SELECT COUNT(DISTINCT submitted_by) FROM paper_share_submissions WHERE paper_id = ?`
+	if err := ps.DB.QueryRow(query, paperID).Scan(&submitted); err != nil {
+		return 0, 0, fmt.Errorf("failed to count submitted shares: %w", err)
+	}
+
+	return submitted, threshold, nil
+}
+
+// SubmitKeyShare releases the caller's share of a threshold-protected paper key.
+// The share is re-wrapped for every share holder so it can be combined later
+// from a separate login without ever being stored in the clear.
+func (ps *PaperService) SubmitKeyShare(paperID int, user *models.User) error {
+	if err := acl.EnforcePermission(ps.DB, user, "QuestionPaper", "decrypt", &paperID); err != nil {
+		return err
+	}
+
+	if user.PrivateKey == nil {
+		return fmt.Errorf("private key is locked; please log in again")
+	}
+
+	submitted, threshold, err := ps.GetShareStatus(paperID)
+	if err != nil {
+		return err
+	}
+	if threshold == 0 {
+		return fmt.Errorf("paper does not use threshold key release")
+	}
+
+	var encryptedShareB64, keyWrap string
+	query := `SELECT encrypted_share, key_wrap FROM paper_key_shares WHERE paper_id = ? AND user_id = ?`
+	err = ps.DB.QueryRow(query, paperID, user.ID).Scan(&encryptedShareB64, &keyWrap)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("you do not hold a key share for this paper")
+	} else if err != nil {
+		return fmt.Errorf("failed to fetch key share: %w", err)
+	}
+
+	var alreadySubmitted int
+	query = `SELECT COUNT(*) FROM paper_share_submissions WHERE paper_id = ? AND submitted_by = ?`
+	if err := ps.DB.QueryRow(query, paperID, user.ID).Scan(&alreadySubmitted); err != nil {
+		return fmt.Errorf("failed to check submissions: %w", err)
+	}
+	if alreadySubmitted > 0 {
+		return fmt.Errorf("you have already submitted your share for this paper")
+	}
+
+	encryptedShare, err := crypto.DecodeBase64(encryptedShareB64)
+	if err != nil {
+		return fmt.Errorf("failed to decode key share: %w", err)
+	}
+
+	share, err := user.PrivateKey.Unwrap(keyWrap, encryptedShare)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt key share: %w", err)
+	}
+
+	holders, err := getShareHolders(ps.DB, paperID)
+	if err != nil {
+		return err
+	}
+
+	wrapped, err := wrapKeyForRecipients(share, holders)
+	if err != nil {
+		return err
+	}
+
+	tx, err := ps.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	insertQuery := `
+        INSERT INTO paper_share_submissions (paper_id, submitted_by, recipient_id, encrypted_share, key_wrap)
+        VALUES (?, ?, ?, ?, ?)
+    `
+	for _, w := range wrapped {
+		_, err := tx.Exec(insertQuery, paperID, user.ID, w.UserID, crypto.EncodeBase64(w.EncryptedKey), w.Algorithm)
+		if err != nil {
+			return fmt.Errorf("failed to store share submission: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit share submission: %w", err)
+	}
+
+	acl.LogAction(ps.DB, user.ID, "submit_key_share", "QuestionPaper", &paperID, true,
+		fmt.Sprintf("key share submitted (%d of %d)", submitted+1, threshold))
+
+	return nil
+}
+
+// recoverThresholdKey combines submitted shares into the paper's AES key
+func (ps *PaperService) recoverThresholdKey(paperID, threshold int, user *models.User) ([]byte, error) {
+	var submitted int
+	query := `SELECT COUNT(DISTINCT submitted_by) FROM paper_share_submissions WHERE paper_id = ?`
+	if err := ps.DB.QueryRow(query, paperID).Scan(&submitted); err != nil {
+		return nil, fmt.Errorf("failed to count submitted shares: %w", err)
+	}
+
+	if submitted < threshold {
+		return nil, fmt.Errorf("%d of %d key shares submitted; %d more ExamCell member(s) must submit their share",
+			submitted, threshold, threshold-submitted)
+	}
+
+	query = `SELECT encrypted_share, key_wrap FROM paper_share_submissions WHERE paper_id = ? AND recipient_id = ?`
+	rows, err := ps.DB.Query(query, paperID, user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch submitted shares: %w", err)
+	}
+	defer rows.Close()
+
+	var shares [][]byte
+	for rows.Next() {
+		var encryptedShareB64, keyWrap string
+		if err := rows.Scan(&encryptedShareB64, &keyWrap); err != nil {
+			return nil, fmt.Errorf("failed to scan share: %w", err)
+		}
+
+		encryptedShare, err := crypto.DecodeBase64(encryptedShareB64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode share: %w", err)
+		}
+
+		share, err := user.PrivateKey.Unwrap(keyWrap, encryptedShare)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt share: %w", err)
+		}
+		shares = append(shares, share)
+	}
+
+	if len(shares) < threshold {
+		return nil, fmt.Errorf("you are not a key holder for this paper")
+	}
+
+	aesKey, err := crypto.CombineShares(shares)
+	if err != nil {
+		return nil, fmt.Errorf("failed to combine key shares: %w", err)
+	}
+
+	return aesKey, nil
+}
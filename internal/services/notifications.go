@@ -0,0 +1,46 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/pkg/email"
+)
+
+// notifyUser sends a templated notification to one user.
+// Notification failures never fail the operation that triggered them.
+func notifyUser(db *sql.DB, userID int, templateName string, data interface{}) {
+	var address string
+	if err := db.QueryRow(`SELECT email FROM users WHERE id = ?`, userID).Scan(&address); err != nil {
+		fmt.Printf("  Warning: Failed to look up notification recipient: %v\n", err)
+		return
+	}
+
+	if err := email.Notify(address, templateName, data); err != nil {
+		fmt.Printf("  Warning: Failed to send %s notification: %v\n", templateName, err)
+	}
+}
+
+// notifyRole sends a templated notification to every user with a role
+func notifyRole(db *sql.DB, role, templateName string, data interface{}) {
+	rows, err := db.Query(`SELECT email FROM users WHERE role = ?`, role)
+	if err != nil {
+		fmt.Printf("  Warning: Failed to look up notification recipients: %v\n", err)
+		return
+	}
+
+	var addresses []string
+	for rows.Next() {
+		var address string
+		if err := rows.Scan(&address); err == nil {
+			addresses = append(addresses, address)
+		}
+	}
+	rows.Close()
+
+	for _, address := range addresses {
+		if err := email.Notify(address, templateName, data); err != nil {
+			fmt.Printf("  Warning: Failed to send %s notification: %v\n", templateName, err)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/internal/acl"
+	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/internal/models"
+	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/pkg/email"
+)
+
+// OutboxService lets the Exam Cell inspect the outbound email queue
+type OutboxService struct {
+	DB   *sql.DB
+	User *models.User
+}
+
+// NewOutboxService creates a new outbox service
+func NewOutboxService(db *sql.DB, user *models.User) *OutboxService {
+	return &OutboxService{
+		DB:   db,
+		User: user,
+	}
+}
+
+// GetQueueCounts returns the number of messages in each status
+func (s *OutboxService) GetQueueCounts() (map[string]int, error) {
+	if err := acl.EnforcePermission(s.DB, s.User, "EmailOutbox", "read", nil); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.DB.Query(`SELECT status, COUNT(*) FROM email_outbox GROUP BY status`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count outbox: %w", err)
+	}
+	defer rows.Close()
+
+	counts := map[string]int{email.OutboxPending: 0, email.OutboxSent: 0, email.OutboxDead: 0}
+	for rows.Next() {
+		var status string
+		var count int
+		if err := rows.Scan(&status, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan outbox count: %w", err)
+		}
+		counts[status] = count
+	}
+
+	return counts, nil
+}
+
+// GetUndelivered lists pending and dead messages, oldest first.
+// Bodies are left out because they may contain OTPs.
+func (s *OutboxService) GetUndelivered(limit int) ([]models.OutboxMessage, error) {
+	if err := acl.EnforcePermission(s.DB, s.User, "EmailOutbox", "read", nil); err != nil {
+		return nil, err
+	}
+
+	query := `
+        SELECT id, recipient, subject, template, status, attempts, next_attempt_at, last_error, created_at
+        FROM email_outbox
+        WHERE status IN (?, ?)
+        ORDER BY created_at ASC, id ASC
+        LIMIT ?
+    `
+	rows, err := s.DB.Query(query, email.OutboxPending, email.OutboxDead, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get outbox: %w", err)
+	}
+	defer rows.Close()
+
+	var messages []models.OutboxMessage
+	for rows.Next() {
+		var msg models.OutboxMessage
+		var template, lastError sql.NullString
+		err := rows.Scan(
+			&msg.ID,
+			&msg.Recipient,
+			&msg.Subject,
+			&template,
+			&msg.Status,
+			&msg.Attempts,
+			&msg.NextAttemptAt,
+			&lastError,
+			&msg.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan outbox message: %w", err)
+		}
+		msg.Template = template.String
+		msg.LastError = lastError.String
+		messages = append(messages, msg)
+	}
+
+	return messages, nil
+}
@@ -0,0 +1,171 @@
+package services
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/internal/acl"
+	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/internal/bundle"
+	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/internal/crypto"
+	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/internal/models"
+)
+
+// ExportPaper writes a self-contained bundle of an approved or published
+// paper for a recipient outside the portal, such as a printing centre. The
+// stored ciphertext is copied as-is and the paper's AES key is wrapped to the
+// recipient's public key; nothing is decrypted, so the release window does
+// not apply. Threshold papers need their shares submitted first.
+func (ps *PaperService) ExportPaper(paperID int, user *models.User, recipient crypto.PublicKey, w io.Writer) (*bundle.Manifest, error) {
+	manifest, err := ps.exportPaper(paperID, user, recipient, w)
+	if err != nil {
+		acl.LogAction(ps.DB, user.ID, "export", "QuestionPaper", &paperID, false, err.Error())
+		return nil, err
+	}
+
+	acl.LogAction(ps.DB, user.ID, "export", "QuestionPaper", &paperID, true,
+		fmt.Sprintf("bundle exported for %s (%s)", manifest.Recipient.Fingerprint, manifest.Recipient.KeyWrap))
+	return manifest, nil
+}
+
+func (ps *PaperService) exportPaper(paperID int, user *models.User, recipient crypto.PublicKey, w io.Writer) (*bundle.Manifest, error) {
+	if err := acl.EnforcePermission(ps.DB, user, "QuestionPaper", "decrypt", &paperID); err != nil {
+		return nil, err
+	}
+	if user.PrivateKey == nil {
+		return nil, fmt.Errorf("private key is locked; please log in again")
+	}
+	if _, err := activeKeyID(ps.DB, user.ID, user.PrivateKey.Public()); err != nil {
+		return nil, err
+	}
+
+	info := bundle.Paper{ID: paperID}
+	var examDate sql.NullTime
+	var paper struct {
+		EncryptedContentB64 string
+		BlobRef             sql.NullString
+		BlobSize            sql.NullInt64
+		BlobSHA256          sql.NullString
+		ContentFormat       string
+		Signature           string
+		SignatureAlg        string
+		SignatureScheme     string
+		SignerKeyID         sql.NullInt64
+		ReleaseThreshold    int
+	}
+	query := `
+        SELECT qp.title, qp.subject, qp.exam_date, qp.faculty_id, u.username, qp.upload_date, qp.status,
+               qp.encrypted_content, qp.blob_ref, qp.blob_size, qp.blob_sha256, qp.content_format,
+               qp.digital_signature, qp.signature_alg, qp.signature_scheme, qp.signer_key_id, qp.release_threshold
+        FROM question_papers qp
+        JOIN users u ON qp.faculty_id = u.id
+        WHERE qp.id = ?
+    `
+	err := ps.DB.QueryRow(query, paperID).Scan(&info.Title, &info.Subject, &examDate, &info.FacultyID,
+		&info.Faculty, &info.UploadDate, &info.Status, &paper.EncryptedContentB64, &paper.BlobRef, &paper.BlobSize,
+		&paper.BlobSHA256, &paper.ContentFormat, &paper.Signature, &paper.SignatureAlg, &paper.SignatureScheme,
+		&paper.SignerKeyID, &paper.ReleaseThreshold)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("paper not found")
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to fetch paper: %w", err)
+	}
+
+	if examDate.Valid {
+		// The envelope signs the UTC date
+		info.ExamDate = examDate.Time.UTC().Format("2006-01-02")
+	}
+	if info.Status != StatusApproved && info.Status != StatusPublished {
+		return nil, fmt.Errorf("only approved or published papers can be exported (paper is %s)", info.Status)
+	}
+	compromised, err := signerKeyCompromised(ps.DB, paper.SignerKeyID)
+	if err != nil {
+		return nil, err
+	}
+	if compromised {
+		return nil, fmt.Errorf("paper was signed with a key reported compromised; its faculty must re-sign it first")
+	}
+	var pending int
+	query = `SELECT COUNT(*) FROM paper_reencryption_queue WHERE paper_id = ? AND status = ?`
+	if err := ps.DB.QueryRow(query, paperID, ReencryptionPending).Scan(&pending); err != nil {
+		return nil, fmt.Errorf("failed to check re-encryption queue: %w", err)
+	}
+	if pending > 0 {
+		return nil, fmt.Errorf("paper is queued for re-encryption; re-encrypt it before exporting")
+	}
+
+	signerKey, err := signerPublicKey(ps.DB, info.FacultyID, paper.SignerKeyID)
+	if err != nil {
+		return nil, err
+	}
+	signerPEM, err := crypto.EncodePublicKey(signerKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode signer public key: %w", err)
+	}
+	signerFingerprint, err := crypto.PublicKeyFingerprint(signerKey)
+	if err != nil {
+		return nil, err
+	}
+	recipientFingerprint, err := crypto.PublicKeyFingerprint(recipient)
+	if err != nil {
+		return nil, err
+	}
+
+	aesKey, err := ps.recoverPaperKey(paperID, paper.ReleaseThreshold, user)
+	if err != nil {
+		return nil, err
+	}
+	wrappedKey, keyWrap, err := recipient.Wrap(aesKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to wrap key for recipient: %w", err)
+	}
+
+	ciphertext, err := ps.openCiphertext(paper.EncryptedContentB64, paper.BlobRef, paper.BlobSize, paper.BlobSHA256)
+	if err != nil {
+		return nil, err
+	}
+	defer ciphertext.Close()
+
+	var ciphertextInfo bundle.Ciphertext
+	var content io.Reader = ciphertext
+	if paper.BlobRef.Valid {
+		ciphertextInfo = bundle.Ciphertext{Size: paper.BlobSize.Int64, SHA256: paper.BlobSHA256.String}
+	} else {
+		// Inline papers predate the blob store and are small enough to hash in memory
+		inline, err := io.ReadAll(ciphertext)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load encrypted content: %w", err)
+		}
+		sum := sha256.Sum256(inline)
+		ciphertextInfo = bundle.Ciphertext{Size: int64(len(inline)), SHA256: hex.EncodeToString(sum[:])}
+		content = bytes.NewReader(inline)
+	}
+	ciphertextInfo.Format = paper.ContentFormat
+
+	manifest := &bundle.Manifest{
+		Paper:      info,
+		ExportedAt: time.Now().UTC(),
+		ExportedBy: user.Username,
+		Ciphertext: ciphertextInfo,
+		Recipient: bundle.Recipient{
+			Fingerprint: recipientFingerprint,
+			KeyWrap:     keyWrap,
+			WrappedKey:  crypto.EncodeBase64(wrappedKey),
+		},
+		Signature: bundle.Signature{
+			Scheme:            paper.SignatureScheme,
+			Algorithm:         paper.SignatureAlg,
+			Value:             paper.Signature,
+			SignerPublicKey:   signerPEM,
+			SignerFingerprint: signerFingerprint,
+		},
+	}
+	if err := bundle.Write(w, manifest, content); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
@@ -1,117 +1,380 @@
 package services
 
 import (
+	"bytes"
+	"crypto/sha256"
 	"database/sql"
 	"fmt"
-	"io/ioutil"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/internal/acl"
 	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/internal/crypto"
 	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/internal/models"
+	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/pkg/blobstore"
+	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/pkg/email"
+)
+
+// Content formats of a paper's ciphertext, recorded per paper in content_format
+const (
+	ContentFormatGCM      = crypto.ContentFormatGCM
+	ContentFormatStreamV1 = crypto.ContentFormatStreamV1
+	ContentFormatStream   = crypto.ContentFormatStream
 )
 
 type PaperService struct {
 	DB *sql.DB
+
+	// Threshold enables k-of-n key release when greater than zero:
+	// the AES key is split so that Threshold ExamCell members must each submit a share
+	Threshold int
+
+	// Policy gates decryption on the exam schedule
+	Policy *ReleasePolicy
+
+	// Blobs holds paper ciphertext; the database keeps only its ref, size and hash
+	Blobs blobstore.BlobStore
+
+	// Escrow, when set, also receives every paper key (see InitEscrowFromEnv)
+	Escrow *EscrowKey
+}
+
+// ThresholdFromEnv reads PAPER_KEY_THRESHOLD: unset or 0 disables threshold
+// release, and k-of-n release needs k of at least 2
+func ThresholdFromEnv() (int, error) {
+	value := strings.TrimSpace(os.Getenv("PAPER_KEY_THRESHOLD"))
+	if value == "" {
+		return 0, nil
+	}
+	threshold, err := strconv.Atoi(value)
+	if err != nil || threshold < 0 {
+		return 0, fmt.Errorf("PAPER_KEY_THRESHOLD must be 0 (disabled) or at least 2, got %q", value)
+	}
+	if threshold == 1 {
+		return 0, fmt.Errorf("PAPER_KEY_THRESHOLD=1 would let a single member release the key; use 0 to disable threshold release or at least 2")
+	}
+	return threshold, nil
+}
+
+// NewPaperService creates a paper service, reading PAPER_KEY_THRESHOLD from the
+// environment; an invalid value is reported at startup by ThresholdFromEnv
+func NewPaperService(db *sql.DB) *PaperService {
+	threshold, err := ThresholdFromEnv()
+	if err != nil {
+		threshold = 0
+	}
+
+	return &PaperService{
+		DB:        db,
+		Threshold: threshold,
+		Policy:    NewReleasePolicy(db),
+		Blobs:     blobstore.Default(),
+		Escrow:    DefaultEscrow(),
+	}
+}
+
+// releasePolicy returns the configured policy, never allowing it to be skipped
+func (ps *PaperService) releasePolicy() *ReleasePolicy {
+	if ps.Policy == nil {
+		ps.Policy = NewReleasePolicy(ps.DB)
+	}
+	return ps.Policy
+}
+
+// blobs returns the configured store, falling back to the process default
+func (ps *PaperService) blobs() blobstore.BlobStore {
+	if ps.Blobs == nil {
+		ps.Blobs = blobstore.Default()
+	}
+	return ps.Blobs
 }
 
 // UploadPaper handles the complete paper upload with encryption
 func (ps *PaperService) UploadPaper(faculty *models.User, title, subject, filePath string, examDate time.Time) error {
 	fmt.Println("\n📄 Reading question paper from file...")
 
-	// Step 1: Read file from path
-	fileContent, err := ioutil.ReadFile(filePath)
+	// Step 1: Open the file; it is streamed, never read into memory whole
+	file, err := os.Open(filePath)
 	if err != nil {
 		return fmt.Errorf("failed to read file: %w (make sure path is correct)", err)
 	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to read file: %w", err)
+	}
+	fmt.Printf(" File opened (%.2f KB)\n", float64(info.Size())/1024.0)
+
+	_, err = ps.UploadPaperStream(faculty, title, subject, file, examDate)
+	return err
+}
+
+// UploadPaperContent encrypts, signs and stores paper content, returning the new paper ID
+func (ps *PaperService) UploadPaperContent(faculty *models.User, title, subject string, fileContent []byte, examDate time.Time) (int, error) {
+	if len(fileContent) == 0 {
+		return 0, fmt.Errorf("paper content cannot be empty")
+	}
+	return ps.UploadPaperStream(faculty, title, subject, bytes.NewReader(fileContent), examDate)
+}
+
+// encryptToBlob streams content through the chunked AES-GCM writer, bound to
+// additionalData, into the blob store, returning the blob with the plaintext's
+// size and SHA-256
+func (ps *PaperService) encryptToBlob(content io.Reader, aesKey, additionalData []byte) (blobstore.Blob, int64, []byte, error) {
+	type result struct {
+		size int64
+		err  error
+	}
+
+	digest := sha256.New()
+	pr, pw := io.Pipe()
+	done := make(chan result, 1)
+	go func() {
+		var res result
+		enc, err := crypto.NewEncryptWriter(pw, aesKey, additionalData)
+		if err == nil {
+			res.size, err = io.Copy(enc, io.TeeReader(content, digest))
+			if err == nil {
+				err = enc.Close()
+			}
+		}
+		res.err = err
+		pw.CloseWithError(err)
+		done <- res
+	}()
+
+	blob, err := ps.blobs().Put(pr)
+	// Unblock the encrypting goroutine if the store gave up early
+	pr.Close()
+	res := <-done
+	if res.err != nil {
+		return blobstore.Blob{}, 0, nil, fmt.Errorf("failed to encrypt content: %w", res.err)
+	}
+	if err != nil {
+		return blobstore.Blob{}, 0, nil, fmt.Errorf("failed to store encrypted paper: %w", err)
+	}
+	return blob, res.size, digest.Sum(nil), nil
+}
+
+// UploadPaperStream encrypts, signs and stores paper content read from r,
+// returning the new paper ID. Memory use does not grow with the paper size.
+func (ps *PaperService) UploadPaperStream(faculty *models.User, title, subject string, content io.Reader, examDate time.Time) (int, error) {
+	return ps.uploadPaper(faculty, title, subject, content, examDate, 0, "")
+}
+
+// ResubmitPaper uploads revised content for a rejected paper. The revision is a
+// new paper linked to the rejected one and goes through review from the start;
+// comments are the faculty's notes for the reviewer. Returns the revision's ID.
+func (ps *PaperService) ResubmitPaper(faculty *models.User, paperID int, content io.Reader, comments string) (int, error) {
+	if err := acl.EnforcePermission(ps.DB, faculty, "QuestionPaper", "update", &paperID); err != nil {
+		return 0, err
+	}
+
+	var facultyID int
+	var status, title, subject string
+	var examDate sql.NullTime
+	query := `SELECT faculty_id, status, title, subject, exam_date FROM question_papers WHERE id = ?`
+	err := ps.DB.QueryRow(query, paperID).Scan(&facultyID, &status, &title, &subject, &examDate)
+	if err == sql.ErrNoRows {
+		return 0, fmt.Errorf("paper not found")
+	} else if err != nil {
+		return 0, fmt.Errorf("failed to fetch paper: %w", err)
+	}
+	if facultyID != faculty.ID {
+		return 0, fmt.Errorf("you can only resubmit your own papers")
+	}
+	if status != StatusRejected {
+		return 0, fmt.Errorf("only rejected papers can be resubmitted; paper %d is %s", paperID, status)
+	}
+
+	var revisions int
+	if err := ps.DB.QueryRow(`SELECT COUNT(*) FROM paper_revisions WHERE revision_of = ?`, paperID).Scan(&revisions); err != nil {
+		return 0, fmt.Errorf("failed to check revisions: %w", err)
+	}
+	if revisions > 0 {
+		return 0, fmt.Errorf("paper %d has already been resubmitted", paperID)
+	}
+
+	return ps.uploadPaper(faculty, title, subject, content, examDate.Time, paperID, comments)
+}
 
-	fileSize := float64(len(fileContent)) / 1024.0 // KB
-	fmt.Printf(" File read successfully (%.2f KB)\n", fileSize)
+// uploadPaper stores a new paper, recorded as a revision of revisionOf when
+// that is non-zero
+func (ps *PaperService) uploadPaper(faculty *models.User, title, subject string, content io.Reader, examDate time.Time, revisionOf int, comments string) (int, error) {
+	// The signing key is needed at the end; fail before doing any work without it
+	facultyPrivateKey := faculty.PrivateKey
+	if facultyPrivateKey == nil {
+		return 0, fmt.Errorf("faculty private key is locked; please log in again")
+	}
+	signerKeyID, err := activeKeyID(ps.DB, faculty.ID, facultyPrivateKey.Public())
+	if err != nil {
+		return 0, err
+	}
 
 	// Step 2: Generate AES key
 	fmt.Println("\n Generating AES-256 key for encryption...")
 	aesKey, err := crypto.GenerateAESKey()
 	if err != nil {
-		return fmt.Errorf("failed to generate AES key: %w", err)
+		return 0, fmt.Errorf("failed to generate AES key: %w", err)
 	}
 	fmt.Printf(" AES key generated (%d bytes)\n", len(aesKey))
 
-	// Step 3: Encrypt paper content with AES
-	fmt.Println("\n Encrypting question paper with AES-GCM...")
-	encryptedContent, err := crypto.EncryptAES(fileContent, aesKey)
+	// Step 3: Get every ExamCell member's public key
+	fmt.Println("\n Fetching ExamCell public keys...")
+	recipients, err := getExamCellRecipients(ps.DB)
 	if err != nil {
-		return fmt.Errorf("failed to encrypt content: %w", err)
+		return 0, err
 	}
-	fmt.Printf(" Paper encrypted (size: %.2f KB)\n", float64(len(encryptedContent))/1024.0)
+	fmt.Printf(" %d ExamCell public key(s) retrieved\n", len(recipients))
 
-	// Step 4: Get ExamCell's public key
-	fmt.Println("\n Fetching ExamCell's public key...")
-	var examCellPublicKeyPEM string
-	query := `SELECT public_key FROM users WHERE role = 'ExamCell' LIMIT 1`
-	err = ps.DB.QueryRow(query).Scan(&examCellPublicKeyPEM)
-	if err == sql.ErrNoRows {
-		return fmt.Errorf("no ExamCell user found. Please register an ExamCell user first")
-	} else if err != nil {
-		return fmt.Errorf("failed to fetch ExamCell public key: %w", err)
+	// Step 4: Encrypt AES key (or one Shamir share of it) for each ExamCell member
+	var wrappedKeys []WrappedKey
+	if ps.Threshold > 0 {
+		fmt.Printf("\n Splitting AES key into %d-of-%d shares...\n", ps.Threshold, len(recipients))
+		wrappedKeys, err = splitKeyForRecipients(aesKey, recipients, ps.Threshold)
+		if err != nil {
+			return 0, err
+		}
+		fmt.Printf(" Key shares wrapped for %d recipient(s)\n", len(wrappedKeys))
+	} else {
+		fmt.Println("\n Encrypting AES key for each ExamCell member...")
+		wrappedKeys, err = wrapKeyForRecipients(aesKey, recipients)
+		if err != nil {
+			return 0, fmt.Errorf("failed to encrypt AES key: %w", err)
+		}
+		fmt.Printf(" AES key wrapped for %d recipient(s)\n", len(wrappedKeys))
 	}
 
-	examCellPublicKey, err := crypto.DecodePublicKeyFromPEM(examCellPublicKeyPEM)
+	// Step 5: Create the paper record first: its ID is bound into the
+	// ciphertext and the signature. Everything below happens in one
+	// transaction, so a failure leaves no paper behind.
+	fmt.Println("\n Storing paper record in database...")
+	tx, err := ps.DB.Begin()
 	if err != nil {
-		return fmt.Errorf("failed to decode ExamCell public key: %w", err)
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
 	}
-	fmt.Println(" ExamCell public key retrieved")
+	defer tx.Rollback()
 
-	// Step 5: Encrypt AES key with ExamCell's RSA public key
-	fmt.Println("\n Encrypting AES key with ExamCell's RSA public key...")
-	encryptedAESKey, err := crypto.EncryptWithPublicKey(aesKey, examCellPublicKey)
+	// The upload time is signed, so it is set here rather than by the database,
+	// at the precision every backend stores
+	uploadedAt := time.Now().UTC().Truncate(time.Second)
+
+	// encrypted_content and encrypted_aes_key are left empty: the ciphertext is in
+	// the blob store and keys live in paper_key_recipients or paper_key_shares.
+	insertQuery := `
+        INSERT INTO question_papers 
+        (title, subject, faculty_id, encrypted_content, content_format, encrypted_aes_key,
+         digital_signature, signature_scheme, signer_key_id, upload_date, exam_date, status, release_threshold) 
+        VALUES (?, ?, ?, '', ?, '', '', ?, ?, ?, ?, 'pending', ?)
+    `
+
+	result, err := tx.Exec(insertQuery, title, subject, faculty.ID, ContentFormatStream, crypto.SignatureSchemeEnvelope,
+		signerKeyID, uploadedAt, examDate, ps.Threshold)
 	if err != nil {
-		return fmt.Errorf("failed to encrypt AES key: %w", err)
+		return 0, fmt.Errorf("failed to store paper: %w", err)
 	}
-	fmt.Println(" AES key encrypted with RSA")
 
-	// Step 6: Get Faculty's private key for signing
-	fmt.Println("\n  Creating digital signature...")
-	var facultyPrivateKeyPEM string
-	query = `SELECT private_key_encrypted FROM users WHERE id = ?`
-	err = ps.DB.QueryRow(query, faculty.ID).Scan(&facultyPrivateKeyPEM)
+	paperID, err := result.LastInsertId()
 	if err != nil {
-		return fmt.Errorf("failed to get faculty private key: %w", err)
+		return 0, fmt.Errorf("failed to get paper ID: %w", err)
 	}
 
-	facultyPrivateKey, err := crypto.DecodePrivateKeyFromPEM(facultyPrivateKeyPEM)
+	// Step 6: Encrypt paper content in chunks straight into the blob store,
+	// bound to the paper ID. A failure after this leaves an orphaned blob,
+	// which is harmless.
+	fmt.Println("\n Encrypting question paper with chunked AES-GCM...")
+	blob, plainSize, digest, err := ps.encryptToBlob(content, aesKey, crypto.PaperAdditionalData(int(paperID)))
 	if err != nil {
-		return fmt.Errorf("failed to decode faculty private key: %w", err)
+		return 0, err
+	}
+	if plainSize == 0 {
+		return 0, fmt.Errorf("paper content cannot be empty")
 	}
+	fmt.Printf(" Paper encrypted and stored (%.2f KB, %s)\n", float64(blob.Size)/1024.0, blob.Ref)
 
-	// Step 7: Create digital signature of original content
-	signature, err := crypto.CreateSignature(fileContent, facultyPrivateKey)
+	// Step 7: Sign the envelope of the paper's metadata and the SHA-256 of the
+	// original content computed while streaming, using the Faculty's private
+	// key (unlocked in memory at login)
+	fmt.Println("\n  Creating digital signature...")
+	envelope := crypto.PaperEnvelope{
+		PaperID:       int(paperID),
+		ContentSHA256: digest,
+		Title:         title,
+		Subject:       subject,
+		ExamDate:      examDate,
+		FacultyID:     faculty.ID,
+		UploadedAt:    uploadedAt,
+	}
+	signedDigest, err := envelope.SignedDigest(crypto.SignatureSchemeEnvelope)
 	if err != nil {
-		return fmt.Errorf("failed to create signature: %w", err)
+		return 0, err
+	}
+	signature, signatureAlg, err := facultyPrivateKey.SignDigest(signedDigest)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create signature: %w", err)
 	}
 	fmt.Println(" Digital signature created")
 
-	// Step 8: Encode everything to Base64 for storage
-	fmt.Println("\n Encoding data to Base64...")
-	encryptedContentB64 := crypto.EncodeBase64(encryptedContent)
-	encryptedAESKeyB64 := crypto.EncodeBase64(encryptedAESKey)
 	signatureB64 := crypto.EncodeBase64(signature)
-	fmt.Println(" All data encoded to Base64")
 
-	// Step 9: Store in database
-	fmt.Println("\n Storing encrypted paper in database...")
-	insertQuery := `
-        INSERT INTO question_papers 
-        (title, subject, faculty_id, encrypted_content, encrypted_aes_key, digital_signature, exam_date, status) 
-        VALUES (?, ?, ?, ?, ?, ?, ?, 'pending')
+	// Step 8: Store the blob, signature and wrapped keys
+	updateQuery := `
+        UPDATE question_papers
+        SET blob_ref = ?, blob_size = ?, blob_sha256 = ?, digital_signature = ?, signature_alg = ?
+        WHERE id = ?
     `
+	if _, err := tx.Exec(updateQuery, blob.Ref, blob.Size, blob.SHA256, signatureB64, signatureAlg, paperID); err != nil {
+		return 0, fmt.Errorf("failed to store paper: %w", err)
+	}
+
+	// Uploading submits the paper for review. The unique revision_of makes
+	// a concurrent second resubmission fail here.
+	if revisionOf != 0 {
+		_, err := tx.Exec(`INSERT INTO paper_revisions (paper_id, revision_of, created_at) VALUES (?, ?, ?)`,
+			paperID, revisionOf, uploadedAt)
+		if err != nil {
+			return 0, fmt.Errorf("failed to link revision to paper %d: %w", revisionOf, err)
+		}
+	}
+	if err := recordStatusChange(tx, paperID, "", StatusPending, faculty.ID, comments); err != nil {
+		return 0, err
+	}
 
-	result, err := ps.DB.Exec(insertQuery, title, subject, faculty.ID, encryptedContentB64, encryptedAESKeyB64, signatureB64, examDate)
+	if ps.Threshold > 0 {
+		err = storeKeyShares(tx, paperID, wrappedKeys)
+	} else {
+		err = storeKeyRecipients(tx, paperID, wrappedKeys)
+	}
 	if err != nil {
-		return fmt.Errorf("failed to store paper: %w", err)
+		return 0, err
+	}
+	if ps.Escrow != nil {
+		if err := storeEscrowKey(tx, paperID, aesKey, ps.Escrow); err != nil {
+			return 0, err
+		}
 	}
 
-	paperID, _ := result.LastInsertId()
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit paper: %w", err)
+	}
 	fmt.Printf(" Paper stored successfully (Paper ID: %d)\n", paperID)
+	if revisionOf != 0 {
+		acl.LogAction(ps.DB, faculty.ID, "resubmit", "QuestionPaper", &revisionOf, true,
+			fmt.Sprintf("revised as paper #%d", paperID))
+	}
+
+	notifyRole(ps.DB, "ExamCell", email.TemplatePaperSubmitted, email.PaperData{
+		PaperID:  int(paperID),
+		Title:    title,
+		Subject:  subject,
+		ExamDate: examDate,
+		Actor:    faculty.Username,
+	})
 
 	// Summary
 	fmt.Println("\n" + strings.Repeat("=", 50))
@@ -120,22 +383,36 @@ func (ps *PaperService) UploadPaper(faculty *models.User, title, subject, filePa
 	fmt.Printf(" Title: %s\n", title)
 	fmt.Printf(" Subject: %s\n", subject)
 	fmt.Printf(" Exam Date: %s\n", examDate.Format("2006-01-02"))
-	fmt.Printf(" Encryption: AES-256-GCM\n")
-	fmt.Printf(" Key Exchange: RSA-2048\n")
-	fmt.Printf("  Digital Signature: SHA-256 + RSA\n")
-	fmt.Printf(" Encoding: Base64\n")
+	fmt.Printf(" Encryption: AES-256-GCM, %d KB chunks\n", crypto.StreamChunkSize/1024)
+	fmt.Printf(" Key Exchange: %s\n", wrapAlgorithms(wrappedKeys))
+	if ps.Threshold > 0 {
+		fmt.Printf(" Key Release: %d-of-%d ExamCell members\n", ps.Threshold, len(recipients))
+	}
+	fmt.Printf("  Digital Signature: SHA-256 + %s over content and metadata\n", signatureAlg)
+	if ps.Escrow != nil {
+		fmt.Printf(" Key Escrow: %s\n", ps.Escrow.Fingerprint)
+	}
+	fmt.Printf(" Storage: blob store (%.2f KB, SHA-256 verified on read)\n", float64(blob.Size)/1024.0)
+	fmt.Printf(" Paper Size: %.2f KB\n", float64(plainSize)/1024.0)
 	fmt.Println("\n" + strings.Repeat("=", 50))
 
-	return nil
+	return int(paperID), nil
 }
 
 // GetFacultyPapers retrieves all papers uploaded by a faculty member
 func (ps *PaperService) GetFacultyPapers(facultyID int) ([]models.QuestionPaper, error) {
+	// Latest reviewer comment is shown alongside each paper; notes left by
+	// faculty with a resubmitted revision are not reviewer comments
 	query := `
-        SELECT id, title, subject, upload_date, exam_date, status 
-        FROM question_papers 
-        WHERE faculty_id = ? 
-        ORDER BY upload_date DESC
+        SELECT qp.id, qp.title, qp.subject, qp.upload_date, qp.exam_date, qp.status,
+               (SELECT h.comments FROM paper_status_history h
+                WHERE h.paper_id = qp.id AND h.comments <> '' AND h.to_status <> 'pending'
+                ORDER BY h.changed_at DESC, h.id DESC LIMIT 1) AS review_comments,
+               (SELECT r.revision_of FROM paper_revisions r WHERE r.paper_id = qp.id) AS revision_of,
+               ` + compromiseFlagColumns + `
+        FROM question_papers qp
+        WHERE qp.faculty_id = ? 
+        ORDER BY qp.upload_date DESC
     `
 
 	rows, err := ps.DB.Query(query, facultyID)
@@ -148,8 +425,11 @@ func (ps *PaperService) GetFacultyPapers(facultyID int) ([]models.QuestionPaper,
 	for rows.Next() {
 		var paper models.QuestionPaper
 		var examDate sql.NullTime
+		var reviewComments sql.NullString
+		var revisionOf sql.NullInt64
 
-		err := rows.Scan(&paper.ID, &paper.Title, &paper.Subject, &paper.UploadDate, &examDate, &paper.Status)
+		err := rows.Scan(&paper.ID, &paper.Title, &paper.Subject, &paper.UploadDate, &examDate, &paper.Status, &reviewComments,
+			&revisionOf, &paper.SignerCompromised, &paper.ReencryptionPending)
 		if err != nil {
 			return nil, err
 		}
@@ -157,6 +437,8 @@ func (ps *PaperService) GetFacultyPapers(facultyID int) ([]models.QuestionPaper,
 		if examDate.Valid {
 			paper.ExamDate = examDate.Time
 		}
+		paper.ReviewComments = reviewComments.String
+		paper.RevisionOf = int(revisionOf.Int64)
 
 		papers = append(papers, paper)
 	}
@@ -167,7 +449,8 @@ func (ps *PaperService) GetFacultyPapers(facultyID int) ([]models.QuestionPaper,
 // GetAllPapers retrieves all question papers (for ExamCell)
 func (ps *PaperService) GetAllPapers() ([]models.QuestionPaper, error) {
 	query := `
-        SELECT qp.id, qp.title, qp.subject, qp.upload_date, qp.exam_date, qp.status, u.username as faculty_name
+        SELECT qp.id, qp.title, qp.subject, qp.upload_date, qp.exam_date, qp.status, qp.release_threshold,
+               u.username as faculty_name, ` + compromiseFlagColumns + `
         FROM question_papers qp
         JOIN users u ON qp.faculty_id = u.id
         ORDER BY qp.upload_date DESC
@@ -185,7 +468,8 @@ func (ps *PaperService) GetAllPapers() ([]models.QuestionPaper, error) {
 		var examDate sql.NullTime
 		var facultyName string
 
-		err := rows.Scan(&paper.ID, &paper.Title, &paper.Subject, &paper.UploadDate, &examDate, &paper.Status, &facultyName)
+		err := rows.Scan(&paper.ID, &paper.Title, &paper.Subject, &paper.UploadDate, &examDate, &paper.Status,
+			&paper.ReleaseThreshold, &facultyName, &paper.SignerCompromised, &paper.ReencryptionPending)
 		if err != nil {
 			return nil, err
 		}
@@ -203,113 +487,197 @@ func (ps *PaperService) GetAllPapers() ([]models.QuestionPaper, error) {
 
 // DecryptPaper decrypts a question paper for ExamCell
 func (ps *PaperService) DecryptPaper(paperID int, examCellUser *models.User) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := ps.decryptPaperTo(paperID, examCellUser, &buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// DecryptPaperToFile streams a decrypted paper to path without holding it in
+// memory. The plaintext goes to a temporary file next to path, which is only
+// renamed into place once the signature has been verified.
+func (ps *PaperService) DecryptPaperToFile(paperID int, examCellUser *models.User, path string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".paper-*")
+	if err != nil {
+		return fmt.Errorf("failed to create output file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := ps.decryptPaperTo(paperID, examCellUser, tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write output file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write output file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to save decrypted paper: %w", err)
+	}
+	return nil
+}
+
+// decryptPaperTo writes the plaintext to w and verifies the faculty signature
+// once all of it has been written; output is untrusted unless it returns nil
+func (ps *PaperService) decryptPaperTo(paperID int, examCellUser *models.User, w io.Writer) (err error) {
 	fmt.Println("\n" + strings.Repeat("=", 60))
 	fmt.Println(" DECRYPTING QUESTION PAPER")
 	fmt.Println(strings.Repeat("=", 60))
 
+	// Step 0: Enforce the time lock before touching any key material
+	fmt.Println("\n Checking release window...")
+	overrideID, err := ps.releasePolicy().CheckDecryption(paperID, examCellUser)
+	if err != nil {
+		return err
+	}
+	// An override is only spent by a decryption that succeeds
+	defer func() {
+		if err != nil {
+			ps.releasePolicy().ReleaseOverride(overrideID, paperID, examCellUser, err)
+		}
+	}()
+	fmt.Println(" Release window open")
+
 	// Step 1: Fetch paper details
 	fmt.Println("\n Fetching encrypted paper from database...")
 	var paper struct {
 		Title               string
 		Subject             string
 		EncryptedContentB64 string
-		EncryptedAESKeyB64  string
+		BlobRef             sql.NullString
+		BlobSize            sql.NullInt64
+		BlobSHA256          sql.NullString
+		ContentFormat       string
 		DigitalSignatureB64 string
+		SignatureAlg        string
+		SignatureScheme     string
+		SignerKeyID         sql.NullInt64
 		FacultyID           int
+		ReleaseThreshold    int
+		ExamDate            sql.NullTime
+		UploadDate          time.Time
 	}
 
 	query := `
-        SELECT title, subject, encrypted_content, encrypted_aes_key, digital_signature, faculty_id
+        SELECT title, subject, encrypted_content, blob_ref, blob_size, blob_sha256, content_format,
+               digital_signature, signature_alg, signature_scheme, signer_key_id, faculty_id, release_threshold,
+               exam_date, upload_date
         FROM question_papers 
         WHERE id = ?
     `
 
-	err := ps.DB.QueryRow(query, paperID).Scan(
+	err = ps.DB.QueryRow(query, paperID).Scan(
 		&paper.Title,
 		&paper.Subject,
 		&paper.EncryptedContentB64,
-		&paper.EncryptedAESKeyB64,
+		&paper.BlobRef,
+		&paper.BlobSize,
+		&paper.BlobSHA256,
+		&paper.ContentFormat,
 		&paper.DigitalSignatureB64,
+		&paper.SignatureAlg,
+		&paper.SignatureScheme,
+		&paper.SignerKeyID,
 		&paper.FacultyID,
+		&paper.ReleaseThreshold,
+		&paper.ExamDate,
+		&paper.UploadDate,
 	)
 
 	if err == sql.ErrNoRows {
-		return nil, fmt.Errorf("paper not found")
+		return fmt.Errorf("paper not found")
 	} else if err != nil {
-		return nil, fmt.Errorf("failed to fetch paper: %w", err)
+		return fmt.Errorf("failed to fetch paper: %w", err)
 	}
 
 	fmt.Printf(" Paper retrieved: %s\n", paper.Title)
 
-	// Step 2: Decode from Base64
-	fmt.Println("\n Decoding Base64 data...")
-	encryptedContent, err := crypto.DecodeBase64(paper.EncryptedContentB64)
+	compromised, err := signerKeyCompromised(ps.DB, paper.SignerKeyID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to decode content: %w", err)
+		return err
+	}
+	if compromised {
+		acl.LogAction(ps.DB, examCellUser.ID, "decrypt", "QuestionPaper", &paperID, false,
+			"signed with a key reported compromised")
+		return fmt.Errorf("paper was signed with a key reported compromised; its faculty must re-sign it before it can be decrypted")
 	}
 
-	encryptedAESKey, err := crypto.DecodeBase64(paper.EncryptedAESKeyB64)
+	// Step 2: Open the ciphertext, from the blob store unless it predates it
+	fmt.Println("\n Opening encrypted paper...")
+	encryptedContent, err := ps.openCiphertext(paper.EncryptedContentB64, paper.BlobRef, paper.BlobSize, paper.BlobSHA256)
 	if err != nil {
-		return nil, fmt.Errorf("failed to decode AES key: %w", err)
+		return err
 	}
+	defer encryptedContent.Close()
 
 	signature, err := crypto.DecodeBase64(paper.DigitalSignatureB64)
 	if err != nil {
-		return nil, fmt.Errorf("failed to decode signature: %w", err)
+		return fmt.Errorf("failed to decode signature: %w", err)
 	}
 	fmt.Println(" Base64 decoding complete")
 
-	// Step 3: Get ExamCell's private key
+	// Step 3: Use ExamCell's private key (unlocked in memory at login)
 	fmt.Println("\n Loading ExamCell's private key...")
-	var privateKeyPEM string
-	query = `SELECT private_key_encrypted FROM users WHERE id = ?`
-	err = ps.DB.QueryRow(query, examCellUser.ID).Scan(&privateKeyPEM)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get private key: %w", err)
-	}
-
-	privateKey, err := crypto.DecodePrivateKeyFromPEM(privateKeyPEM)
-	if err != nil {
-		return nil, fmt.Errorf("failed to decode private key: %w", err)
+	privateKey := examCellUser.PrivateKey
+	if privateKey == nil {
+		return fmt.Errorf("private key is locked; please log in again")
 	}
 	fmt.Println(" Private key loaded")
 
-	// Step 4: Decrypt AES key using RSA private key
-	fmt.Println("\n Decrypting AES key with RSA private key...")
-	aesKey, err := crypto.DecryptWithPrivateKey(encryptedAESKey, privateKey)
+	// Step 4: Recover the AES key
+	if paper.ReleaseThreshold > 0 {
+		fmt.Printf("\n Combining %d-of-n submitted key shares...\n", paper.ReleaseThreshold)
+	} else {
+		fmt.Printf("\n Decrypting AES key with %s private key...\n", privateKey.Algorithm())
+	}
+	aesKey, err := ps.recoverPaperKey(paperID, paper.ReleaseThreshold, examCellUser)
 	if err != nil {
-		return nil, fmt.Errorf("failed to decrypt AES key: %w", err)
+		return err
 	}
-	fmt.Printf(" AES key decrypted (%d bytes)\n", len(aesKey))
+	fmt.Printf(" AES key recovered (%d bytes)\n", len(aesKey))
 
-	// Step 5: Decrypt content using AES key
+	// Step 5: Decrypt content using AES key, hashing the plaintext for the
+	// signature check. The ciphertext only opens under this paper's ID.
 	fmt.Println("\n Decrypting paper content with AES key...")
-	decryptedContent, err := crypto.DecryptAES(encryptedContent, aesKey)
+	digest := sha256.New()
+	written, err := crypto.DecryptContent(paper.ContentFormat, encryptedContent, aesKey,
+		crypto.PaperAdditionalData(paperID), io.MultiWriter(w, digest))
 	if err != nil {
-		return nil, fmt.Errorf("failed to decrypt content: %w", err)
+		return fmt.Errorf("failed to decrypt content: %w", err)
 	}
-	fmt.Printf(" Content decrypted (%.2f KB)\n", float64(len(decryptedContent))/1024.0)
+	fmt.Printf(" Content decrypted (%.2f KB)\n", float64(written)/1024.0)
 
-	// Step 6: Get Faculty's public key for signature verification
+	// Step 6: Get the public key the paper was signed with, which may since
+	// have been rotated out
 	fmt.Println("\n Verifying digital signature...")
-	var facultyPublicKeyPEM string
-	query = `SELECT public_key FROM users WHERE id = ?`
-	err = ps.DB.QueryRow(query, paper.FacultyID).Scan(&facultyPublicKeyPEM)
+	facultyPublicKey, err := signerPublicKey(ps.DB, paper.FacultyID, paper.SignerKeyID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get faculty public key: %w", err)
+		return err
 	}
 
-	facultyPublicKey, err := crypto.DecodePublicKeyFromPEM(facultyPublicKeyPEM)
+	// Step 7: Verify the signature over the content and the metadata as stored now
+	envelope := crypto.PaperEnvelope{
+		PaperID:       paperID,
+		ContentSHA256: digest.Sum(nil),
+		Title:         paper.Title,
+		Subject:       paper.Subject,
+		ExamDate:      paper.ExamDate.Time,
+		FacultyID:     paper.FacultyID,
+		UploadedAt:    paper.UploadDate,
+	}
+	signedDigest, err := envelope.SignedDigest(paper.SignatureScheme)
 	if err != nil {
-		return nil, fmt.Errorf("failed to decode faculty public key: %w", err)
+		return err
 	}
-
-	// Step 7: Verify signature
-	err = crypto.VerifySignature(decryptedContent, signature, facultyPublicKey)
+	err = facultyPublicKey.VerifyDigest(paper.SignatureAlg, signedDigest, signature)
 	if err != nil {
 		fmt.Println(" SIGNATURE VERIFICATION FAILED!")
 		fmt.Println("  WARNING: Paper may have been tampered with!")
-		return nil, fmt.Errorf("signature verification failed: %w", err)
+		return fmt.Errorf("signature verification failed: %w", err)
 	}
 	fmt.Println(" Digital signature verified - paper is authentic!")
 
@@ -321,8 +689,57 @@ func (ps *PaperService) DecryptPaper(paperID int, examCellUser *models.User) ([]
 	fmt.Printf(" Subject: %s\n", paper.Subject)
 	fmt.Println(" Decryption: Successful")
 	fmt.Println(" Signature: Verified")
+	if paper.SignatureScheme == crypto.SignatureSchemeContent {
+		fmt.Println(" Metadata: Not signed (legacy paper)")
+	} else {
+		fmt.Println(" Metadata: Verified")
+	}
 	fmt.Println(" Integrity: Confirmed")
 	fmt.Println(strings.Repeat("=", 60))
 
-	return decryptedContent, nil
+	notifyUser(ps.DB, paper.FacultyID, email.TemplateDecryption, email.PaperData{
+		PaperID: paperID,
+		Title:   paper.Title,
+		Subject: paper.Subject,
+		Actor:   examCellUser.Username,
+		At:      time.Now(),
+	})
+
+	return nil
+}
+
+// openCiphertext opens a paper's ciphertext, from the blob store unless it
+// was stored inline before the blob store existed
+func (ps *PaperService) openCiphertext(inlineB64 string, blobRef sql.NullString, blobSize sql.NullInt64, blobSHA256 sql.NullString) (io.ReadCloser, error) {
+	if blobRef.Valid {
+		encryptedContent, err := blobstore.OpenVerified(ps.blobs(), blobRef.String, blobSize.Int64, blobSHA256.String)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load encrypted content: %w", err)
+		}
+		return encryptedContent, nil
+	}
+
+	inline, err := crypto.DecodeBase64(inlineB64)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load encrypted content: %w", err)
+	}
+	return io.NopCloser(bytes.NewReader(inline)), nil
+}
+
+// recoverPaperKey returns a paper's AES key: threshold papers need k
+// submitted shares, others are unwrapped with the user's own private key
+func (ps *PaperService) recoverPaperKey(paperID, threshold int, user *models.User) ([]byte, error) {
+	if threshold > 0 {
+		return ps.recoverThresholdKey(paperID, threshold, user)
+	}
+
+	encryptedAESKey, keyWrap, err := getWrappedKeyForUser(ps.DB, paperID, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	aesKey, err := user.PrivateKey.Unwrap(keyWrap, encryptedAESKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt AES key: %w", err)
+	}
+	return aesKey, nil
 }
@@ -0,0 +1,26 @@
+package services
+
+import "testing"
+
+func TestThresholdFromEnv(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    int
+		wantErr bool
+	}{
+		{"", 0, false},
+		{"0", 0, false},
+		{"2", 2, false},
+		{" 3 ", 3, false},
+		{"1", 0, true},
+		{"-2", 0, true},
+		{"two", 0, true},
+	}
+	for _, tt := range tests {
+		t.Setenv("PAPER_KEY_THRESHOLD", tt.value)
+		got, err := ThresholdFromEnv()
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("PAPER_KEY_THRESHOLD=%q: %d, %v", tt.value, got, err)
+		}
+	}
+}
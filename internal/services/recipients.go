@@ -0,0 +1,147 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/internal/crypto"
+)
+
+// KeyRecipient is a user a paper's AES key is wrapped for. Keys are wrapped
+// with whatever suite the recipient's key pair uses.
+type KeyRecipient struct {
+	UserID    int
+	Username  string
+	PublicKey crypto.PublicKey
+}
+
+// WrappedKey is a paper AES key (or key share) encrypted for a single recipient
+type WrappedKey struct {
+	UserID       int
+	EncryptedKey []byte
+	Algorithm    string // key wrap algorithm, stored in key_wrap
+}
+
+// parseRecipientKey decodes a user's public key and checks it is of the suite
+// recorded in users.key_algorithm
+func parseRecipientKey(username, publicKeyPEM string, keyAlgorithm sql.NullString) (crypto.PublicKey, error) {
+	publicKey, err := crypto.ParsePublicKey(publicKeyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode public key of %s: %w", username, err)
+	}
+	if keyAlgorithm.Valid && keyAlgorithm.String != publicKey.Algorithm() {
+		return nil, fmt.Errorf("public key of %s is %s but the account uses %s",
+			username, publicKey.Algorithm(), keyAlgorithm.String)
+	}
+	return publicKey, nil
+}
+
+// getExamCellRecipients loads the public keys of every ExamCell member.
+// Members whose current key was reported compromised are skipped until they
+// rotate it.
+func getExamCellRecipients(db *sql.DB) ([]KeyRecipient, error) {
+	query := `
+        SELECT id, username, public_key, key_algorithm
+        FROM users
+        WHERE role = 'ExamCell' AND public_key IS NOT NULL AND public_key <> ''
+          AND NOT EXISTS (SELECT 1 FROM user_keys k
+                          WHERE k.user_id = users.id AND k.status = 'active' AND k.compromised_at IS NOT NULL)
+        ORDER BY id
+    `
+
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch ExamCell public keys: %w", err)
+	}
+	defer rows.Close()
+
+	var recipients []KeyRecipient
+	for rows.Next() {
+		var recipient KeyRecipient
+		var publicKeyPEM string
+		var keyAlgorithm sql.NullString
+
+		if err := rows.Scan(&recipient.UserID, &recipient.Username, &publicKeyPEM, &keyAlgorithm); err != nil {
+			return nil, fmt.Errorf("failed to scan ExamCell user: %w", err)
+		}
+
+		recipient.PublicKey, err = parseRecipientKey(recipient.Username, publicKeyPEM, keyAlgorithm)
+		if err != nil {
+			return nil, err
+		}
+
+		recipients = append(recipients, recipient)
+	}
+
+	if len(recipients) == 0 {
+		return nil, fmt.Errorf("no ExamCell user found. Please register an ExamCell user first")
+	}
+
+	return recipients, nil
+}
+
+// wrapKeyForRecipients encrypts an AES key with each recipient's public key
+func wrapKeyForRecipients(aesKey []byte, recipients []KeyRecipient) ([]WrappedKey, error) {
+	wrapped := make([]WrappedKey, 0, len(recipients))
+	for _, recipient := range recipients {
+		encryptedKey, keyWrap, err := recipient.PublicKey.Wrap(aesKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to wrap key for %s: %w", recipient.Username, err)
+		}
+		wrapped = append(wrapped, WrappedKey{UserID: recipient.UserID, EncryptedKey: encryptedKey, Algorithm: keyWrap})
+	}
+	return wrapped, nil
+}
+
+// wrapAlgorithms lists the distinct key wrap algorithms used for a paper
+func wrapAlgorithms(wrapped []WrappedKey) string {
+	var algorithms []string
+	for _, key := range wrapped {
+		if !slices.Contains(algorithms, key.Algorithm) {
+			algorithms = append(algorithms, key.Algorithm)
+		}
+	}
+	return strings.Join(algorithms, ", ")
+}
+
+// storeKeyRecipients writes one wrapped AES key row per recipient
+func storeKeyRecipients(tx *sql.Tx, paperID int64, wrapped []WrappedKey) error {
+	query := `INSERT INTO paper_key_recipients (paper_id, user_id, encrypted_aes_key, key_wrap) VALUES (?, ?, ?, ?)`
+	for _, key := range wrapped {
+		_, err := tx.Exec(query, paperID, key.UserID, crypto.EncodeBase64(key.EncryptedKey), key.Algorithm)
+		if err != nil {
+			return fmt.Errorf("failed to store wrapped key: %w", err)
+		}
+	}
+	return nil
+}
+
+// getWrappedKeyForUser returns the AES key wrapped for the given user and the
+// algorithm it was wrapped with. Papers uploaded before per-recipient wrapping
+// fall back to the legacy column, which was always RSA PKCS#1 v1.5.
+func getWrappedKeyForUser(db *sql.DB, paperID, userID int) ([]byte, string, error) {
+	var encryptedKeyB64, keyWrap string
+	query := `SELECT encrypted_aes_key, key_wrap FROM paper_key_recipients WHERE paper_id = ? AND user_id = ?`
+	err := db.QueryRow(query, paperID, userID).Scan(&encryptedKeyB64, &keyWrap)
+	if err == sql.ErrNoRows {
+		query = `SELECT encrypted_aes_key FROM question_papers WHERE id = ?`
+		err = db.QueryRow(query, paperID).Scan(&encryptedKeyB64)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to fetch paper key: %w", err)
+		}
+		if encryptedKeyB64 == "" {
+			return nil, "", fmt.Errorf("paper key was not wrapped for your account")
+		}
+		keyWrap = crypto.KeyWrapPKCS1v15
+	} else if err != nil {
+		return nil, "", fmt.Errorf("failed to fetch wrapped key: %w", err)
+	}
+
+	encryptedKey, err := crypto.DecodeBase64(encryptedKeyB64)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to decode AES key: %w", err)
+	}
+	return encryptedKey, keyWrap, nil
+}
@@ -0,0 +1,306 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/internal/acl"
+	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/internal/models"
+)
+
+const (
+	DefaultDecryptWindowMins = 30
+	MaxClockDrift            = 2 * time.Minute
+	OverrideValidity         = 15 * time.Minute
+)
+
+// processStart anchors the monotonic clock reference used by trustedNow
+var processStart = time.Now()
+
+// trustedNow returns the current time measured on the monotonic clock since
+// process start, refusing to continue if the wall clock has been moved
+func trustedNow() (time.Time, error) {
+	monotonicNow := processStart.Round(0).Add(time.Since(processStart))
+	wallNow := time.Now().Round(0)
+
+	drift := wallNow.Sub(monotonicNow)
+	if drift < 0 {
+		drift = -drift
+	}
+	if drift > MaxClockDrift {
+		return time.Time{}, fmt.Errorf("system clock differs from monotonic reference by %s", drift.Round(time.Second))
+	}
+
+	return monotonicNow, nil
+}
+
+// ReleasePolicy refuses paper decryption until shortly before the exam
+type ReleasePolicy struct {
+	DB     *sql.DB
+	Window time.Duration
+}
+
+// NewReleasePolicy creates a policy, reading DECRYPT_WINDOW_MINUTES from the environment
+func NewReleasePolicy(db *sql.DB) *ReleasePolicy {
+	windowMins, err := strconv.Atoi(os.Getenv("DECRYPT_WINDOW_MINUTES"))
+	if err != nil || windowMins < 0 {
+		windowMins = DefaultDecryptWindowMins
+	}
+
+	return &ReleasePolicy{
+		DB:     db,
+		Window: time.Duration(windowMins) * time.Minute,
+	}
+}
+
+// ReleaseTime returns the earliest time a paper may be decrypted.
+// It uses the paper's earliest exam session, falling back to its exam date.
+func (p *ReleasePolicy) ReleaseTime(paperID int) (time.Time, error) {
+	// ORDER BY rather than MIN() so the column keeps its time type on every backend
+	var scheduled sql.NullTime
+	query := `
+        SELECT scheduled_time FROM exam_sessions
+        WHERE paper_id = ? AND status <> 'cancelled'
+        ORDER BY scheduled_time ASC
+        LIMIT 1
+    `
+	err := p.DB.QueryRow(query, paperID).Scan(&scheduled)
+	if err != nil && err != sql.ErrNoRows {
+		return time.Time{}, fmt.Errorf("failed to fetch exam sessions: %w", err)
+	}
+
+	if !scheduled.Valid {
+		var examDate sql.NullTime
+		err := p.DB.QueryRow(`SELECT exam_date FROM question_papers WHERE id = ?`, paperID).Scan(&examDate)
+		if err == sql.ErrNoRows {
+			return time.Time{}, fmt.Errorf("paper not found")
+		} else if err != nil {
+			return time.Time{}, fmt.Errorf("failed to fetch paper: %w", err)
+		}
+		if !examDate.Valid {
+			return time.Time{}, fmt.Errorf("no exam is scheduled for this paper")
+		}
+		scheduled = examDate
+	}
+
+	return scheduled.Time.Add(-p.Window), nil
+}
+
+// CheckDecryption allows decryption of a paper in review, inside the release window or
+// with an approved override, returning the ID of the override it claimed, if any.
+// A caller whose decryption then fails must hand the override back with ReleaseOverride.
+// Every refusal is written to the audit log with its reason.
+func (p *ReleasePolicy) CheckDecryption(paperID int, user *models.User) (int, error) {
+	now, err := trustedNow()
+	if err != nil {
+		acl.LogAction(p.DB, user.ID, "decrypt", "QuestionPaper", &paperID, false, "time lock: "+err.Error())
+		return 0, fmt.Errorf("decryption refused: %w", err)
+	}
+
+	// Reviewers read a paper before it is approved; the time lock guards
+	// approved and published papers
+	var status string
+	err = p.DB.QueryRow(`SELECT status FROM question_papers WHERE id = ?`, paperID).Scan(&status)
+	if err != nil && err != sql.ErrNoRows {
+		return 0, fmt.Errorf("failed to fetch paper: %w", err)
+	}
+	if status == StatusInReview {
+		acl.LogAction(p.DB, user.ID, "decrypt_for_review", "QuestionPaper", &paperID, true, "paper is in review")
+		return 0, nil
+	}
+
+	releaseAt, err := p.ReleaseTime(paperID)
+	if err != nil {
+		acl.LogAction(p.DB, user.ID, "decrypt", "QuestionPaper", &paperID, false, "time lock: "+err.Error())
+		return 0, fmt.Errorf("decryption refused: %w", err)
+	}
+
+	if !now.Before(releaseAt) {
+		return 0, nil
+	}
+
+	overrideID, err := p.useApprovedOverride(paperID, user, now)
+	if err != nil {
+		return 0, err
+	}
+	if overrideID > 0 {
+		acl.LogAction(p.DB, user.ID, "emergency_override_used", "QuestionPaper", &paperID, true,
+			fmt.Sprintf("override #%d used %s before release window", overrideID, releaseAt.Sub(now).Round(time.Minute)))
+		return overrideID, nil
+	}
+
+	reason := fmt.Sprintf("time lock: paper locked until %s", releaseAt.Local().Format("2006-01-02 15:04"))
+	acl.LogAction(p.DB, user.ID, "decrypt", "QuestionPaper", &paperID, false, reason)
+	return 0, fmt.Errorf("decryption refused: paper is locked until %s (%s before the exam)",
+		releaseAt.Local().Format("2006-01-02 15:04"), p.Window)
+}
+
+// ReleaseOverride returns an override claimed by CheckDecryption after the
+// decryption it allowed failed, so it can be used again
+func (p *ReleasePolicy) ReleaseOverride(overrideID, paperID int, user *models.User, reason error) {
+	if overrideID == 0 {
+		return
+	}
+	_, err := p.DB.Exec(`UPDATE decryption_overrides SET used_at = NULL WHERE id = ?`, overrideID)
+	if err != nil {
+		fmt.Printf("  Warning: Failed to release override #%d: %v\n", overrideID, err)
+		return
+	}
+	acl.LogAction(p.DB, user.ID, "emergency_override_released", "QuestionPaper", &paperID, true,
+		fmt.Sprintf("override #%d released after a failed decryption: %v", overrideID, reason))
+}
+
+// useApprovedOverride consumes an approved, unexpired override for this user and paper
+func (p *ReleasePolicy) useApprovedOverride(paperID int, user *models.User, now time.Time) (int, error) {
+	var overrideID int
+	query := `
+        SELECT id FROM decryption_overrides
+        WHERE paper_id = ? AND requested_by = ? AND approved_by IS NOT NULL
+          AND used_at IS NULL AND expires_at > ?
+        ORDER BY approved_at DESC
+        LIMIT 1
+    `
+	err := p.DB.QueryRow(query, paperID, user.ID, now).Scan(&overrideID)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	} else if err != nil {
+		return 0, fmt.Errorf("failed to check emergency overrides: %w", err)
+	}
+
+	// Only the request whose update lands may use the override, so two
+	// concurrent decryptions cannot both spend it
+	result, err := p.DB.Exec(`UPDATE decryption_overrides SET used_at = ? WHERE id = ? AND used_at IS NULL`, now, overrideID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to mark override as used: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to mark override as used: %w", err)
+	}
+	if affected == 0 {
+		return 0, nil
+	}
+
+	return overrideID, nil
+}
+
+// DecryptionOverride is an emergency request to decrypt a paper before its release window
+type DecryptionOverride struct {
+	ID            int
+	PaperID       int
+	PaperTitle    string
+	RequestedBy   int
+	RequesterName string
+	Reason        string
+	RequestedAt   time.Time
+}
+
+// RequestOverride asks for emergency early decryption of a paper
+func (p *ReleasePolicy) RequestOverride(paperID int, user *models.User, reason string) (int, error) {
+	if err := acl.EnforcePermission(p.DB, user, "QuestionPaper", "decrypt", &paperID); err != nil {
+		return 0, err
+	}
+
+	if reason == "" {
+		return 0, fmt.Errorf("a reason is required for an emergency override")
+	}
+
+	var exists int
+	if err := p.DB.QueryRow(`SELECT COUNT(*) FROM question_papers WHERE id = ?`, paperID).Scan(&exists); err != nil {
+		return 0, fmt.Errorf("failed to fetch paper: %w", err)
+	}
+	if exists == 0 {
+		return 0, fmt.Errorf("paper not found")
+	}
+
+	query := `INSERT INTO decryption_overrides (paper_id, requested_by, reason) VALUES (?, ?, ?)`
+	result, err := p.DB.Exec(query, paperID, user.ID, reason)
+	if err != nil {
+		return 0, fmt.Errorf("failed to store override request: %w", err)
+	}
+
+	overrideID, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get override ID: %w", err)
+	}
+
+	acl.LogAction(p.DB, user.ID, "emergency_override_requested", "QuestionPaper", &paperID, true,
+		fmt.Sprintf("override #%d requested: %s", overrideID, reason))
+
+	return int(overrideID), nil
+}
+
+// ApproveOverride lets a second ExamCell member approve an override request
+func (p *ReleasePolicy) ApproveOverride(overrideID int, approver *models.User) error {
+	var paperID, requestedBy int
+	var approvedBy sql.NullInt64
+	query := `SELECT paper_id, requested_by, approved_by FROM decryption_overrides WHERE id = ?`
+	err := p.DB.QueryRow(query, overrideID).Scan(&paperID, &requestedBy, &approvedBy)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("override request not found")
+	} else if err != nil {
+		return fmt.Errorf("failed to fetch override request: %w", err)
+	}
+
+	if err := acl.EnforcePermission(p.DB, approver, "QuestionPaper", "decrypt", &paperID); err != nil {
+		return err
+	}
+
+	if approvedBy.Valid {
+		return fmt.Errorf("override request already approved")
+	}
+
+	if requestedBy == approver.ID {
+		acl.LogAction(p.DB, approver.ID, "emergency_override_approved", "QuestionPaper", &paperID, false,
+			fmt.Sprintf("override #%d: requester cannot approve their own request", overrideID))
+		return fmt.Errorf("a second ExamCell member must approve this request")
+	}
+
+	now := time.Now()
+	update := `UPDATE decryption_overrides SET approved_by = ?, approved_at = ?, expires_at = ? WHERE id = ? AND approved_by IS NULL`
+	result, err := p.DB.Exec(update, approver.ID, now, now.Add(OverrideValidity), overrideID)
+	if err != nil {
+		return fmt.Errorf("failed to approve override: %w", err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return fmt.Errorf("override request already approved")
+	}
+
+	acl.LogAction(p.DB, approver.ID, "emergency_override_approved", "QuestionPaper", &paperID, true,
+		fmt.Sprintf("override #%d approved for user %d, valid for %s", overrideID, requestedBy, OverrideValidity))
+
+	return nil
+}
+
+// GetPendingOverrides lists override requests awaiting a second approver
+func (p *ReleasePolicy) GetPendingOverrides() ([]DecryptionOverride, error) {
+	query := `
+        SELECT o.id, o.paper_id, qp.title, o.requested_by, u.username, o.reason, o.requested_at
+        FROM decryption_overrides o
+        JOIN question_papers qp ON o.paper_id = qp.id
+        JOIN users u ON o.requested_by = u.id
+        WHERE o.approved_by IS NULL
+        ORDER BY o.requested_at ASC
+    `
+
+	rows, err := p.DB.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get override requests: %w", err)
+	}
+	defer rows.Close()
+
+	var overrides []DecryptionOverride
+	for rows.Next() {
+		var o DecryptionOverride
+		err := rows.Scan(&o.ID, &o.PaperID, &o.PaperTitle, &o.RequestedBy, &o.RequesterName, &o.Reason, &o.RequestedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan override request: %w", err)
+		}
+		overrides = append(overrides, o)
+	}
+
+	return overrides, nil
+}
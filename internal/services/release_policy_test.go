@@ -0,0 +1,134 @@
+package services
+
+import (
+	"database/sql"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/internal/database/dbtest"
+	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/internal/models"
+)
+
+// seedPaper inserts a faculty member, two ExamCell members and a paper for
+// release-policy tests, returning the paper ID and the ExamCell users
+func seedPaper(t *testing.T, db *sql.DB, examDate time.Time) (int, *models.User, *models.User) {
+	t.Helper()
+	dbtest.Exec(t, db, `INSERT INTO users (id, username, password_hash, salt, role, email) VALUES
+        (1, 'faculty', 'hash', 'salt', 'Faculty', 'faculty@example.com'),
+        (2, 'examcell1', 'hash', 'salt', 'ExamCell', 'examcell1@example.com'),
+        (3, 'examcell2', 'hash', 'salt', 'ExamCell', 'examcell2@example.com')`)
+	result := dbtest.Exec(t, db, `
+        INSERT INTO question_papers (title, subject, faculty_id, encrypted_content, encrypted_aes_key, digital_signature, exam_date, status)
+        VALUES ('Midterm', 'Maths', 1, '', '', 'signature', ?, 'published')`, examDate)
+	paperID, err := result.LastInsertId()
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := &models.User{ID: 2, Username: "examcell1", Role: "ExamCell"}
+	second := &models.User{ID: 3, Username: "examcell2", Role: "ExamCell"}
+	return int(paperID), first, second
+}
+
+func TestApprovedOverrideIsSingleUse(t *testing.T) {
+	db := dbtest.Open(t)
+	paperID, requester, approver := seedPaper(t, db, time.Now().AddDate(0, 0, 7))
+	policy := &ReleasePolicy{DB: db, Window: 30 * time.Minute}
+
+	if _, err := policy.CheckDecryption(paperID, requester); err == nil {
+		t.Fatal("paper a week from its exam was released without an override")
+	}
+
+	overrideID, err := policy.RequestOverride(paperID, requester, "exam moved forward")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := policy.ApproveOverride(overrideID, requester); err == nil {
+		t.Fatal("requester approved their own override")
+	}
+	if err := policy.ApproveOverride(overrideID, approver); err != nil {
+		t.Fatal(err)
+	}
+	if err := policy.ApproveOverride(overrideID, approver); err == nil {
+		t.Fatal("override approved twice")
+	}
+
+	if id, err := policy.CheckDecryption(paperID, requester); err != nil || id != overrideID {
+		t.Fatalf("approved override refused: %d, %v", id, err)
+	}
+	if _, err := policy.CheckDecryption(paperID, requester); err == nil {
+		t.Fatal("override used twice")
+	}
+}
+
+func TestFailedDecryptionReleasesOverride(t *testing.T) {
+	db := dbtest.Open(t)
+	paperID, requester, approver := seedPaper(t, db, time.Now().AddDate(0, 0, 7))
+	policy := &ReleasePolicy{DB: db, Window: 30 * time.Minute}
+	ps := &PaperService{DB: db, Policy: policy}
+
+	overrideID, err := policy.RequestOverride(paperID, requester, "exam moved forward")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := policy.ApproveOverride(overrideID, approver); err != nil {
+		t.Fatal(err)
+	}
+
+	// The requester's key is locked, so the decryption fails after the time lock
+	if _, err := ps.DecryptPaper(paperID, requester); err == nil {
+		t.Fatal("decryption without a private key succeeded")
+	}
+	var usedAt sql.NullTime
+	if err := db.QueryRow(`SELECT used_at FROM decryption_overrides WHERE id = ?`, overrideID).Scan(&usedAt); err != nil {
+		t.Fatal(err)
+	}
+	if usedAt.Valid {
+		t.Fatal("override spent by a failed decryption")
+	}
+	if id, err := policy.CheckDecryption(paperID, requester); err != nil || id != overrideID {
+		t.Fatalf("released override refused: %d, %v", id, err)
+	}
+}
+
+func TestConcurrentDecryptionsSpendOverrideOnce(t *testing.T) {
+	db := dbtest.Open(t)
+	paperID, requester, approver := seedPaper(t, db, time.Now().AddDate(0, 0, 7))
+	policy := &ReleasePolicy{DB: db, Window: 30 * time.Minute}
+
+	overrideID, err := policy.RequestOverride(paperID, requester, "exam moved forward")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := policy.ApproveOverride(overrideID, approver); err != nil {
+		t.Fatal(err)
+	}
+
+	const attempts = 8
+	now := time.Now()
+	used := make(chan int, attempts)
+	var wg sync.WaitGroup
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			id, err := policy.useApprovedOverride(paperID, requester, now)
+			if err != nil {
+				t.Error(err)
+			}
+			used <- id
+		}()
+	}
+	wg.Wait()
+	close(used)
+
+	granted := 0
+	for id := range used {
+		if id != 0 {
+			granted++
+		}
+	}
+	if granted != 1 {
+		t.Fatalf("override granted %d times, want once", granted)
+	}
+}
@@ -0,0 +1,255 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/internal/acl"
+	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/pkg/email"
+)
+
+// ScheduleChangeRequest is a session change refused with ErrReleaseMovedEarlier
+// and held until a second ExamCell member approves it
+type ScheduleChangeRequest struct {
+	ID              int
+	PaperID         int
+	PaperTitle      string
+	SessionID       int // 0 for a new session
+	SessionName     string
+	ScheduledTime   time.Time
+	DurationMinutes int
+	Reason          string
+	RequestedBy     int
+	RequesterName   string
+	RequestedAt     time.Time
+}
+
+// RequestNewSession asks for a session that would open its paper earlier
+func (s *ExamSessionService) RequestNewSession(paperID int, name string, scheduledTime time.Time, durationMins int, reason string) (int, error) {
+	if err := acl.EnforcePermission(s.DB, s.User, "ExamSession", "create", nil); err != nil {
+		return 0, err
+	}
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return 0, fmt.Errorf("session name cannot be empty")
+	}
+	if err := validateSessionTiming(scheduledTime, durationMins); err != nil {
+		return 0, err
+	}
+	if _, _, err := s.schedulablePaper(paperID); err != nil {
+		return 0, err
+	}
+	if err := s.checkOverlap(paperID, 0, scheduledTime, durationMins); err != nil {
+		return 0, err
+	}
+
+	return s.storeScheduleRequest(paperID, 0, name, scheduledTime, durationMins, reason)
+}
+
+// RequestReschedule asks to move a session so that it opens its paper earlier
+func (s *ExamSessionService) RequestReschedule(sessionID int, scheduledTime time.Time, durationMins int, reason string) (int, error) {
+	if err := acl.EnforcePermission(s.DB, s.User, "ExamSession", "update", &sessionID); err != nil {
+		return 0, err
+	}
+
+	if err := validateSessionTiming(scheduledTime, durationMins); err != nil {
+		return 0, err
+	}
+
+	session, err := s.getSession(sessionID)
+	if err != nil {
+		return 0, err
+	}
+	if session.Status != SessionScheduled {
+		return 0, fmt.Errorf("only scheduled sessions can be rescheduled (status: %s)", session.Status)
+	}
+	if err := s.checkOverlap(session.PaperID, sessionID, scheduledTime, durationMins); err != nil {
+		return 0, err
+	}
+
+	return s.storeScheduleRequest(session.PaperID, sessionID, session.SessionName, scheduledTime, durationMins, reason)
+}
+
+func (s *ExamSessionService) storeScheduleRequest(paperID, sessionID int, name string, scheduledTime time.Time, durationMins int, reason string) (int, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return 0, fmt.Errorf("a reason is required to open a paper earlier")
+	}
+
+	var session sql.NullInt64
+	if sessionID > 0 {
+		session = sql.NullInt64{Int64: int64(sessionID), Valid: true}
+	}
+
+	query := `
+        INSERT INTO session_schedule_requests
+            (paper_id, session_id, session_name, scheduled_time, duration_minutes, reason, requested_by, requested_at)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+    `
+	result, err := s.DB.Exec(query, paperID, session, name, scheduledTime, durationMins, reason, s.User.ID, time.Now())
+	if err != nil {
+		return 0, fmt.Errorf("failed to store schedule request: %w", err)
+	}
+
+	requestID, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get schedule request ID: %w", err)
+	}
+
+	acl.LogAction(s.DB, s.User.ID, "session_schedule_requested", "ExamSession", &paperID, true,
+		fmt.Sprintf("request #%d: %q at %s: %s", requestID, name, scheduledTime.Format(time.RFC3339), reason))
+
+	return int(requestID), nil
+}
+
+// ApproveScheduleChange lets a second ExamCell member approve a request and
+// applies it, returning the new or moved session's ID
+func (s *ExamSessionService) ApproveScheduleChange(requestID int) (int, error) {
+	if err := acl.EnforcePermission(s.DB, s.User, "ExamSession", "update", nil); err != nil {
+		return 0, err
+	}
+
+	var req ScheduleChangeRequest
+	var sessionID sql.NullInt64
+	var approvedBy sql.NullInt64
+	query := `
+        SELECT paper_id, session_id, session_name, scheduled_time, duration_minutes, requested_by, approved_by
+        FROM session_schedule_requests
+        WHERE id = ?
+    `
+	err := s.DB.QueryRow(query, requestID).Scan(&req.PaperID, &sessionID, &req.SessionName,
+		&req.ScheduledTime, &req.DurationMinutes, &req.RequestedBy, &approvedBy)
+	if err == sql.ErrNoRows {
+		return 0, fmt.Errorf("schedule request not found")
+	} else if err != nil {
+		return 0, fmt.Errorf("failed to fetch schedule request: %w", err)
+	}
+	req.SessionID = int(sessionID.Int64)
+
+	if approvedBy.Valid {
+		return 0, fmt.Errorf("schedule request already approved")
+	}
+	if req.RequestedBy == s.User.ID {
+		acl.LogAction(s.DB, s.User.ID, "session_schedule_approved", "ExamSession", &req.PaperID, false,
+			fmt.Sprintf("request #%d: requester cannot approve their own request", requestID))
+		return 0, fmt.Errorf("a second ExamCell member must approve this request")
+	}
+
+	// The request was checked when it was made; check again now it is applied
+	if err := validateSessionTiming(req.ScheduledTime, req.DurationMinutes); err != nil {
+		return 0, err
+	}
+	var title string
+	var facultyID int
+	if req.SessionID == 0 {
+		if title, facultyID, err = s.schedulablePaper(req.PaperID); err != nil {
+			return 0, err
+		}
+	}
+	if err := s.checkOverlap(req.PaperID, req.SessionID, req.ScheduledTime, req.DurationMinutes); err != nil {
+		return 0, err
+	}
+
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	update := `UPDATE session_schedule_requests SET approved_by = ?, approved_at = ? WHERE id = ? AND approved_by IS NULL`
+	result, err := tx.Exec(update, s.User.ID, time.Now(), requestID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to approve schedule request: %w", err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return 0, fmt.Errorf("schedule request already approved")
+	}
+
+	appliedID := req.SessionID
+	if req.SessionID == 0 {
+		insert := `
+            INSERT INTO exam_sessions (paper_id, session_name, scheduled_time, duration_minutes, status, created_by)
+            VALUES (?, ?, ?, ?, ?, ?)
+        `
+		result, err := tx.Exec(insert, req.PaperID, req.SessionName, req.ScheduledTime, req.DurationMinutes, SessionScheduled, req.RequestedBy)
+		if err != nil {
+			return 0, fmt.Errorf("failed to create session: %w", err)
+		}
+		newID, err := result.LastInsertId()
+		if err != nil {
+			return 0, fmt.Errorf("failed to get session ID: %w", err)
+		}
+		appliedID = int(newID)
+	} else {
+		move := `UPDATE exam_sessions SET scheduled_time = ?, duration_minutes = ? WHERE id = ? AND status = ?`
+		result, err := tx.Exec(move, req.ScheduledTime, req.DurationMinutes, req.SessionID, SessionScheduled)
+		if err != nil {
+			return 0, fmt.Errorf("failed to update session: %w", err)
+		}
+		if affected, _ := result.RowsAffected(); affected == 0 {
+			return 0, fmt.Errorf("session is no longer scheduled")
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit schedule change: %w", err)
+	}
+
+	acl.LogAction(s.DB, s.User.ID, "session_schedule_approved", "ExamSession", &req.PaperID, true,
+		fmt.Sprintf("request #%d by user %d approved: session #%d at %s", requestID, req.RequestedBy, appliedID,
+			req.ScheduledTime.Format(time.RFC3339)))
+
+	if req.SessionID == 0 {
+		notifyUser(s.DB, facultyID, email.TemplateSessionScheduled, email.SessionData{
+			SessionID:       appliedID,
+			SessionName:     req.SessionName,
+			PaperTitle:      title,
+			ScheduledTime:   req.ScheduledTime,
+			DurationMinutes: req.DurationMinutes,
+			ScheduledBy:     s.User.Username,
+		})
+	}
+
+	return appliedID, nil
+}
+
+// GetPendingScheduleChanges lists schedule requests awaiting a second approver
+func (s *ExamSessionService) GetPendingScheduleChanges() ([]ScheduleChangeRequest, error) {
+	if err := acl.EnforcePermission(s.DB, s.User, "ExamSession", "read", nil); err != nil {
+		return nil, err
+	}
+
+	query := `
+        SELECT r.id, r.paper_id, qp.title, r.session_id, r.session_name, r.scheduled_time,
+               r.duration_minutes, r.reason, r.requested_by, u.username, r.requested_at
+        FROM session_schedule_requests r
+        JOIN question_papers qp ON r.paper_id = qp.id
+        JOIN users u ON r.requested_by = u.id
+        WHERE r.approved_by IS NULL
+        ORDER BY r.requested_at ASC
+    `
+
+	rows, err := s.DB.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get schedule requests: %w", err)
+	}
+	defer rows.Close()
+
+	var requests []ScheduleChangeRequest
+	for rows.Next() {
+		var r ScheduleChangeRequest
+		var sessionID sql.NullInt64
+		err := rows.Scan(&r.ID, &r.PaperID, &r.PaperTitle, &sessionID, &r.SessionName, &r.ScheduledTime,
+			&r.DurationMinutes, &r.Reason, &r.RequestedBy, &r.RequesterName, &r.RequestedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan schedule request: %w", err)
+		}
+		r.SessionID = int(sessionID.Int64)
+		requests = append(requests, r)
+	}
+
+	return requests, rows.Err()
+}
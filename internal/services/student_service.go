@@ -39,6 +39,10 @@ func (s *StudentService) GetExamSchedule() ([]models.ExamSession, error) {
 		return nil, err
 	}
 
+	if _, err := CompleteElapsedSessions(s.DB); err != nil {
+		return nil, err
+	}
+
 	query := `
         SELECT es.id, es.session_name, es.scheduled_time, es.duration_minutes, 
                es.status, qp.title as paper_title, qp.subject
@@ -57,7 +61,7 @@ func (s *StudentService) GetExamSchedule() ([]models.ExamSession, error) {
 	var sessions []models.ExamSession
 	for rows.Next() {
 		var session models.ExamSession
-		var paperTitle, subject string
+		var subject string
 
 		err := rows.Scan(
 			&session.ID,
@@ -65,7 +69,7 @@ func (s *StudentService) GetExamSchedule() ([]models.ExamSession, error) {
 			&session.ScheduledTime,
 			&session.DurationMinutes,
 			&session.Status,
-			&paperTitle,
+			&session.PaperTitle,
 			&subject,
 		)
 		if err != nil {
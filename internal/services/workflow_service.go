@@ -0,0 +1,200 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/internal/acl"
+	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/internal/models"
+	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/pkg/email"
+)
+
+// Paper statuses
+const (
+	StatusPending   = "pending" // submitted by faculty, awaiting review
+	StatusInReview  = "in_review"
+	StatusApproved  = "approved"
+	StatusRejected  = "rejected"
+	StatusPublished = "published"
+)
+
+// paperTransitions lists the statuses each status may move to
+var paperTransitions = map[string][]string{
+	StatusPending:  {StatusInReview},
+	StatusInReview: {StatusApproved, StatusRejected},
+	StatusApproved: {StatusPublished},
+	// A rejected paper stays rejected; its faculty resubmits a revision
+	// (see PaperService.ResubmitPaper)
+}
+
+// WorkflowService drives question papers through review and publication
+type WorkflowService struct {
+	DB   *sql.DB
+	User *models.User
+}
+
+// NewWorkflowService creates a new workflow service
+func NewWorkflowService(db *sql.DB, user *models.User) *WorkflowService {
+	return &WorkflowService{
+		DB:   db,
+		User: user,
+	}
+}
+
+// StartReview moves a submitted paper into review
+func (s *WorkflowService) StartReview(paperID int) error {
+	return s.transition(paperID, StatusInReview, "")
+}
+
+// Approve accepts a paper under review
+func (s *WorkflowService) Approve(paperID int, comments string) error {
+	return s.transition(paperID, StatusApproved, comments)
+}
+
+// Reject sends a paper back to faculty with reviewer comments
+func (s *WorkflowService) Reject(paperID int, comments string) error {
+	if strings.TrimSpace(comments) == "" {
+		return fmt.Errorf("comments are required when rejecting a paper")
+	}
+	return s.transition(paperID, StatusRejected, comments)
+}
+
+// Publish releases an approved paper
+func (s *WorkflowService) Publish(paperID int) error {
+	return s.transition(paperID, StatusPublished, "")
+}
+
+// CanTransition reports whether a paper may move between two statuses
+func CanTransition(from, to string) bool {
+	for _, allowed := range paperTransitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// transition checks permission, validates the state change and records it.
+// Reviewing means reading the paper, so it takes the decrypt permission;
+// faculty update only their own papers, by resubmitting them.
+func (s *WorkflowService) transition(paperID int, to, comments string) error {
+	if err := acl.EnforcePermission(s.DB, s.User, "QuestionPaper", "decrypt", &paperID); err != nil {
+		return err
+	}
+
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var from, title, subject string
+	var facultyID int
+	query := `SELECT status, title, subject, faculty_id FROM question_papers WHERE id = ?`
+	err = tx.QueryRow(query, paperID).Scan(&from, &title, &subject, &facultyID)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("paper not found")
+	} else if err != nil {
+		return fmt.Errorf("failed to fetch paper status: %w", err)
+	}
+
+	if !CanTransition(from, to) {
+		return fmt.Errorf("cannot move paper from %s to %s", from, to)
+	}
+
+	// The status guard makes concurrent reviewers fail instead of both succeeding
+	result, err := tx.Exec(`UPDATE question_papers SET status = ? WHERE id = ? AND status = ?`, to, paperID, from)
+	if err != nil {
+		return fmt.Errorf("failed to update paper status: %w", err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return fmt.Errorf("paper status changed concurrently; please retry")
+	}
+
+	if err := recordStatusChange(tx, int64(paperID), from, to, s.User.ID, comments); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit status change: %w", err)
+	}
+
+	if to == StatusApproved || to == StatusRejected {
+		notifyUser(s.DB, facultyID, email.TemplatePaperReviewed, email.PaperData{
+			PaperID:  paperID,
+			Title:    title,
+			Subject:  subject,
+			Actor:    s.User.Username,
+			Status:   to,
+			Comments: comments,
+		})
+	}
+
+	return nil
+}
+
+// recordStatusChange appends a row to the paper's status history
+func recordStatusChange(tx *sql.Tx, paperID int64, from, to string, changedBy int, comments string) error {
+	var fromStatus interface{}
+	if from != "" {
+		fromStatus = from
+	}
+
+	query := `
+        INSERT INTO paper_status_history (paper_id, from_status, to_status, changed_by, comments)
+        VALUES (?, ?, ?, ?, ?)
+    `
+	_, err := tx.Exec(query, paperID, fromStatus, to, changedBy, comments)
+	if err != nil {
+		return fmt.Errorf("failed to record status change: %w", err)
+	}
+	return nil
+}
+
+// GetHistory returns every status change of a paper, oldest first
+func (s *WorkflowService) GetHistory(paperID int) ([]models.PaperStatusChange, error) {
+	if err := acl.EnforcePermission(s.DB, s.User, "QuestionPaper", "read", &paperID); err != nil {
+		return nil, err
+	}
+
+	query := `
+        SELECT h.id, h.paper_id, h.from_status, h.to_status, h.changed_by, u.username, h.comments, h.changed_at
+        FROM paper_status_history h
+        JOIN users u ON h.changed_by = u.id
+        WHERE h.paper_id = ?
+        ORDER BY h.changed_at ASC, h.id ASC
+    `
+
+	rows, err := s.DB.Query(query, paperID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get status history: %w", err)
+	}
+	defer rows.Close()
+
+	var history []models.PaperStatusChange
+	for rows.Next() {
+		var change models.PaperStatusChange
+		var fromStatus, comments sql.NullString
+
+		err := rows.Scan(
+			&change.ID,
+			&change.PaperID,
+			&fromStatus,
+			&change.ToStatus,
+			&change.ChangedBy,
+			&change.ChangedByName,
+			&comments,
+			&change.ChangedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan status change: %w", err)
+		}
+
+		change.FromStatus = fromStatus.String
+		change.Comments = comments.String
+		history = append(history, change)
+	}
+
+	return history, nil
+}
@@ -0,0 +1,124 @@
+package services
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/internal/acl"
+	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/internal/auth"
+	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/internal/database/dbtest"
+	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/internal/models"
+	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/pkg/blobstore"
+)
+
+const testPassword = "Correct#Horse1"
+
+// registerUnlocked creates an account with its key pair unlocked in memory
+func registerUnlocked(t *testing.T, db *sql.DB, username, role string) *models.User {
+	t.Helper()
+	user, err := auth.RegisterUser(db, username, testPassword, username+"@example.com", role)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := auth.UnlockPrivateKey(db, user, testPassword); err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+func TestRejectedPaperCanBeResubmitted(t *testing.T) {
+	db := dbtest.Open(t)
+	reviewer := registerUnlocked(t, db, "examcell", "ExamCell")
+	author := registerUnlocked(t, db, "faculty", "Faculty")
+	stranger := registerUnlocked(t, db, "faculty2", "Faculty")
+
+	ps := &PaperService{DB: db, Policy: &ReleasePolicy{DB: db, Window: 30 * time.Minute}, Blobs: blobstore.NewFileStore(t.TempDir())}
+	examDate := time.Now().AddDate(0, 0, 7).UTC().Truncate(24 * time.Hour)
+	paperID, err := ps.UploadPaperContent(author, "Final", "Physics", []byte("Q3. Explain it."), examDate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	review := NewWorkflowService(db, reviewer)
+
+	revised := func() *bytes.Reader { return bytes.NewReader([]byte("Q3. State Newton's third law.")) }
+	if _, err := ps.ResubmitPaper(author, paperID, revised(), ""); err == nil {
+		t.Fatal("pending paper resubmitted")
+	}
+
+	// Faculty may update their own papers, but only reviewers review
+	var denied *acl.AccessDeniedError
+	if err := NewWorkflowService(db, author).StartReview(paperID); !errors.As(err, &denied) {
+		t.Fatalf("faculty started a review: %v", err)
+	}
+	if err := review.StartReview(paperID); err != nil {
+		t.Fatal(err)
+	}
+
+	// The reviewer can read the paper in review, a week before its exam
+	content, err := ps.DecryptPaper(paperID, reviewer)
+	if err != nil {
+		t.Fatalf("reviewer could not read the paper in review: %v", err)
+	}
+	if string(content) != "Q3. Explain it." {
+		t.Fatalf("decrypted %q", content)
+	}
+	if err := review.Reject(paperID, "question 3 is ambiguous"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ps.ResubmitPaper(reviewer, paperID, revised(), ""); err == nil {
+		t.Fatal("ExamCell resubmitted a faculty paper")
+	}
+	if _, err := ps.ResubmitPaper(stranger, paperID, revised(), ""); err == nil {
+		t.Fatal("faculty resubmitted someone else's paper")
+	}
+	if _, err := ps.ResubmitPaper(author, paperID, bytes.NewReader(nil), ""); err == nil {
+		t.Fatal("resubmitted without revised content")
+	}
+	revisionID, err := ps.ResubmitPaper(author, paperID, revised(), "reworded question 3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ps.ResubmitPaper(author, paperID, revised(), ""); err == nil {
+		t.Fatal("paper resubmitted twice")
+	}
+
+	papers, err := ps.GetFacultyPapers(author.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	statuses := map[int]models.QuestionPaper{}
+	for _, p := range papers {
+		statuses[p.ID] = p
+	}
+	if statuses[paperID].Status != StatusRejected || statuses[paperID].ReviewComments != "question 3 is ambiguous" {
+		t.Fatalf("rejected paper = %+v", statuses[paperID])
+	}
+	revision := statuses[revisionID]
+	if revision.Status != StatusPending || revision.RevisionOf != paperID || revision.ReviewComments != "" {
+		t.Fatalf("revision = %+v", revision)
+	}
+
+	// The revision goes through review with its new content
+	if err := review.StartReview(revisionID); err != nil {
+		t.Fatal(err)
+	}
+	content, err = ps.DecryptPaper(revisionID, reviewer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "Q3. State Newton's third law." {
+		t.Fatalf("revision decrypted to %q", content)
+	}
+
+	// Once approved, the time lock applies again
+	if err := review.Approve(revisionID, ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ps.DecryptPaper(revisionID, reviewer); err == nil {
+		t.Fatal("approved paper decrypted a week before its exam")
+	}
+}
@@ -0,0 +1,180 @@
+package blobstore
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// ErrNotFound is returned when a referenced blob is missing from the store
+var ErrNotFound = errors.New("blob not found")
+
+// Blob identifies stored content; Ref is what the database keeps
+type Blob struct {
+	Ref    string
+	Size   int64
+	SHA256 string
+}
+
+// BlobStore keeps immutable, content-addressed blobs. Putting the same bytes
+// twice yields the same ref and stores them once.
+type BlobStore interface {
+	Put(r io.Reader) (Blob, error)
+	Open(ref string) (io.ReadCloser, error)
+	Delete(ref string) error
+}
+
+const refPrefix = "sha256:"
+
+var refPattern = regexp.MustCompile(`^sha256:[0-9a-f]{64}$`)
+
+// RefFor returns the ref of content with the given hex SHA-256
+func RefFor(digest string) string {
+	return refPrefix + digest
+}
+
+// refDigest validates a ref and returns its hex digest, so refs read back
+// from the database can never name paths or keys outside the store
+func refDigest(ref string) (string, error) {
+	if !refPattern.MatchString(ref) {
+		return "", fmt.Errorf("invalid blob ref %q", ref)
+	}
+	return strings.TrimPrefix(ref, refPrefix), nil
+}
+
+// spool copies r into a temporary file in dir while hashing it, leaving the
+// file positioned at the start
+func spool(dir string, r io.Reader) (*os.File, Blob, error) {
+	tmp, err := os.CreateTemp(dir, ".blob-*")
+	if err != nil {
+		return nil, Blob{}, fmt.Errorf("failed to create temp file: %w", err)
+	}
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hash), r)
+	if err == nil {
+		_, err = tmp.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return nil, Blob{}, fmt.Errorf("failed to buffer blob: %w", err)
+	}
+
+	digest := hex.EncodeToString(hash.Sum(nil))
+	return tmp, Blob{Ref: RefFor(digest), Size: size, SHA256: digest}, nil
+}
+
+// verifiedReader hashes a blob as it is read and fails at the end if it does
+// not match the size and hash recorded when it was stored
+type verifiedReader struct {
+	io.ReadCloser
+	ref    string
+	size   int64
+	digest string
+	read   int64
+	hash   hash.Hash
+}
+
+func (r *verifiedReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.read += int64(n)
+	r.hash.Write(p[:n])
+
+	if r.read > r.size {
+		return n, fmt.Errorf("blob %s is larger than the expected %d bytes", r.ref, r.size)
+	}
+	if err == io.EOF {
+		if r.read != r.size {
+			return n, fmt.Errorf("blob %s is %d bytes, expected %d", r.ref, r.read, r.size)
+		}
+		if hex.EncodeToString(r.hash.Sum(nil)) != r.digest {
+			return n, fmt.Errorf("blob %s failed its integrity check", r.ref)
+		}
+	}
+	return n, err
+}
+
+// OpenVerified opens a blob for streaming; reading it to the end returns an
+// error instead of io.EOF if the content differs from the recorded size and hash
+func OpenVerified(store BlobStore, ref string, size int64, digest string) (io.ReadCloser, error) {
+	rc, err := store.Open(ref)
+	if err != nil {
+		return nil, err
+	}
+	return &verifiedReader{ReadCloser: rc, ref: ref, size: size, digest: digest, hash: sha256.New()}, nil
+}
+
+// ReadVerified reads a whole blob and checks it against the size and hash
+// recorded when it was stored
+func ReadVerified(store BlobStore, ref string, size int64, digest string) ([]byte, error) {
+	rc, err := OpenVerified(store, ref, size, digest)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read blob: %w", err)
+	}
+	return data, nil
+}
+
+const DefaultBlobDir = "storage/blobs"
+
+// NewFromEnv builds the store selected by BLOB_BACKEND (fs by default, or s3)
+func NewFromEnv() (BlobStore, error) {
+	switch strings.ToLower(os.Getenv("BLOB_BACKEND")) {
+	case "", "fs", "file", "filesystem":
+		dir := os.Getenv("BLOB_DIR")
+		if dir == "" {
+			dir = DefaultBlobDir
+		}
+		return NewFileStore(dir), nil
+	case "s3":
+		return NewS3StoreFromEnv()
+	default:
+		return nil, fmt.Errorf("unknown BLOB_BACKEND %q (use fs or s3)", os.Getenv("BLOB_BACKEND"))
+	}
+}
+
+var (
+	defaultStore BlobStore
+	defaultMu    sync.Mutex
+)
+
+// InitFromEnv configures the process-wide store, failing on bad settings
+func InitFromEnv() error {
+	store, err := NewFromEnv()
+	if err != nil {
+		return err
+	}
+	SetDefault(store)
+	return nil
+}
+
+// Default returns the process-wide store, a filesystem store if none was set
+func Default() BlobStore {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+
+	if defaultStore == nil {
+		defaultStore = NewFileStore(DefaultBlobDir)
+	}
+	return defaultStore
+}
+
+// SetDefault replaces the process-wide store
+func SetDefault(store BlobStore) {
+	defaultMu.Lock()
+	defaultStore = store
+	defaultMu.Unlock()
+}
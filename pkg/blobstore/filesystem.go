@@ -0,0 +1,84 @@
+package blobstore
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// FileStore keeps blobs under Dir as sha256/<first two hex digits>/<digest>
+type FileStore struct {
+	Dir string
+}
+
+// NewFileStore returns a store rooted at dir; the directory is created on first write
+func NewFileStore(dir string) *FileStore {
+	return &FileStore{Dir: dir}
+}
+
+func (s *FileStore) path(digest string) string {
+	return filepath.Join(s.Dir, "sha256", digest[:2], digest)
+}
+
+// Put writes the blob to a temp file and renames it into place, so readers
+// never see a partial blob
+func (s *FileStore) Put(r io.Reader) (Blob, error) {
+	if err := os.MkdirAll(s.Dir, 0700); err != nil {
+		return Blob{}, fmt.Errorf("failed to create blob directory: %w", err)
+	}
+
+	tmp, blob, err := spool(s.Dir, r)
+	if err != nil {
+		return Blob{}, err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return Blob{}, fmt.Errorf("failed to sync blob: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return Blob{}, fmt.Errorf("failed to write blob: %w", err)
+	}
+
+	target := s.path(blob.SHA256)
+	if _, err := os.Stat(target); err == nil {
+		return blob, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0700); err != nil {
+		return Blob{}, fmt.Errorf("failed to create blob directory: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), target); err != nil {
+		return Blob{}, fmt.Errorf("failed to store blob: %w", err)
+	}
+	return blob, nil
+}
+
+// Open returns the blob's content
+func (s *FileStore) Open(ref string) (io.ReadCloser, error) {
+	digest, err := refDigest(ref)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(s.path(digest))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, ref)
+	}
+	return f, err
+}
+
+// Delete removes the blob; deleting a missing blob is not an error
+func (s *FileStore) Delete(ref string) error {
+	digest, err := refDigest(ref)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(s.path(digest))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to delete blob: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package blobstore
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// failingReader returns some data and then an error
+type failingReader struct{ sent bool }
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	if r.sent {
+		return 0, errors.New("connection reset")
+	}
+	r.sent = true
+	return copy(p, "partial"), nil
+}
+
+// tempFiles lists the spool files left in dir
+func tempFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	matches, err := filepath.Glob(filepath.Join(dir, ".blob-*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return matches
+}
+
+func TestSpool(t *testing.T) {
+	dir := t.TempDir()
+	content := []byte("encrypted question paper")
+
+	tmp, blob, err := spool(dir, bytes.NewReader(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	sum := sha256.Sum256(content)
+	if blob.SHA256 != hex.EncodeToString(sum[:]) || blob.Size != int64(len(content)) || blob.Ref != RefFor(blob.SHA256) {
+		t.Fatalf("blob = %+v", blob)
+	}
+	// The file is rewound so callers can upload it straight away
+	data, err := io.ReadAll(tmp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, content) {
+		t.Fatalf("spooled %q, want %q", data, content)
+	}
+
+	if _, _, err := spool(dir, &failingReader{}); err == nil {
+		t.Fatal("spool of a failing reader succeeded")
+	}
+	if left := tempFiles(t, dir); len(left) != 1 {
+		t.Fatalf("temp files after a failed spool: %v", left)
+	}
+}
+
+func TestFileStorePutRenamesIntoPlace(t *testing.T) {
+	store := NewFileStore(filepath.Join(t.TempDir(), "blobs"))
+	content := []byte("encrypted question paper")
+
+	blob, err := store.Put(bytes.NewReader(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored, err := os.ReadFile(store.path(blob.SHA256))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(stored, content) {
+		t.Fatalf("stored %q, want %q", stored, content)
+	}
+
+	// Storing the same bytes again keeps one copy and no temp files
+	again, err := store.Put(bytes.NewReader(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again != blob {
+		t.Fatalf("second Put = %+v, want %+v", again, blob)
+	}
+	if left := tempFiles(t, store.Dir); len(left) != 0 {
+		t.Fatalf("temp files left behind: %v", left)
+	}
+
+	if _, err := store.Put(&failingReader{}); err == nil {
+		t.Fatal("Put of a failing reader succeeded")
+	}
+	if left := tempFiles(t, store.Dir); len(left) != 0 {
+		t.Fatalf("temp files left after a failed Put: %v", left)
+	}
+}
+
+func TestFileStoreVerifiedRead(t *testing.T) {
+	store := NewFileStore(t.TempDir())
+	content := []byte("encrypted question paper")
+	blob, err := store.Put(bytes.NewReader(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ReadVerified(store, blob.Ref, blob.Size, blob.SHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, content) {
+		t.Fatalf("read %q, want %q", data, content)
+	}
+
+	tampered := append([]byte(nil), content...)
+	tampered[len(tampered)-1] ^= 0xff
+	tests := []struct {
+		name    string
+		content []byte
+		wantErr string
+	}{
+		{"same size, different bytes", tampered, "integrity"},
+		{"truncated", content[:len(content)-4], "expected"},
+		{"extended", append(append([]byte(nil), content...), "extra"...), "larger than"},
+	}
+	for _, tt := range tests {
+		if err := os.WriteFile(store.path(blob.SHA256), tt.content, 0600); err != nil {
+			t.Fatal(err)
+		}
+		_, err := ReadVerified(store, blob.Ref, blob.Size, blob.SHA256)
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%s: %v, want an error containing %q", tt.name, err, tt.wantErr)
+		}
+	}
+
+	if err := store.Delete(blob.Ref); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Delete(blob.Ref); err != nil {
+		t.Fatalf("deleting a missing blob: %v", err)
+	}
+	if _, err := OpenVerified(store, blob.Ref, blob.Size, blob.SHA256); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("OpenVerified after Delete: %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,250 @@
+package blobstore
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// emptyPayloadHash is the SHA-256 of an empty body, used for GET/HEAD/DELETE
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// S3Store keeps blobs in an S3-compatible bucket (AWS, MinIO, Ceph, ...) using
+// path-style URLs and Signature Version 4
+type S3Store struct {
+	Endpoint  string // e.g. https://s3.eu-west-1.amazonaws.com or http://localhost:9000
+	Bucket    string
+	Region    string
+	Prefix    string
+	AccessKey string
+	SecretKey string
+	Client    *http.Client
+}
+
+// NewS3StoreFromEnv reads S3_ENDPOINT, S3_BUCKET, S3_REGION, S3_PREFIX,
+// S3_ACCESS_KEY and S3_SECRET_KEY
+func NewS3StoreFromEnv() (*S3Store, error) {
+	store := &S3Store{
+		Endpoint:  strings.TrimSuffix(os.Getenv("S3_ENDPOINT"), "/"),
+		Bucket:    os.Getenv("S3_BUCKET"),
+		Region:    os.Getenv("S3_REGION"),
+		Prefix:    os.Getenv("S3_PREFIX"),
+		AccessKey: os.Getenv("S3_ACCESS_KEY"),
+		SecretKey: os.Getenv("S3_SECRET_KEY"),
+		Client:    &http.Client{Timeout: 5 * time.Minute},
+	}
+	if store.Region == "" {
+		store.Region = "us-east-1"
+	}
+	if store.Endpoint == "" || store.Bucket == "" || store.AccessKey == "" || store.SecretKey == "" {
+		return nil, fmt.Errorf("S3 blob store needs S3_ENDPOINT, S3_BUCKET, S3_ACCESS_KEY and S3_SECRET_KEY")
+	}
+	if _, err := url.Parse(store.Endpoint); err != nil {
+		return nil, fmt.Errorf("invalid S3_ENDPOINT: %w", err)
+	}
+	return store, nil
+}
+
+func (s *S3Store) objectURL(digest string) string {
+	return s.Endpoint + "/" + s.Bucket + "/" + s.Prefix + "sha256/" + digest
+}
+
+func (s *S3Store) do(method, digest string, body io.Reader, size int64, payloadHash string) (*http.Response, error) {
+	req, err := http.NewRequest(method, s.objectURL(digest), body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.ContentLength = size
+		req.Header.Set("Content-Type", "application/octet-stream")
+	}
+	signV4(req, payloadHash, s.AccessKey, s.SecretKey, s.Region, time.Now())
+
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return client.Do(req)
+}
+
+// s3Error turns a failed response into an error carrying the start of its body
+func s3Error(op string, resp *http.Response) error {
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return fmt.Errorf("S3 %s failed: %s %s", op, resp.Status, strings.TrimSpace(string(msg)))
+}
+
+// Put spools the blob to disk to learn its hash, skips the upload if the
+// object already exists, and otherwise PUTs it with the hash as payload signature
+func (s *S3Store) Put(r io.Reader) (Blob, error) {
+	tmp, blob, err := spool("", r)
+	if err != nil {
+		return Blob{}, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	head, err := s.do(http.MethodHead, blob.SHA256, nil, 0, emptyPayloadHash)
+	if err != nil {
+		return Blob{}, fmt.Errorf("S3 HEAD failed: %w", err)
+	}
+	head.Body.Close()
+	if head.StatusCode == http.StatusOK {
+		return blob, nil
+	}
+
+	resp, err := s.do(http.MethodPut, blob.SHA256, tmp, blob.Size, blob.SHA256)
+	if err != nil {
+		return Blob{}, fmt.Errorf("S3 PUT failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return Blob{}, s3Error("PUT", resp)
+	}
+	return blob, nil
+}
+
+// Open streams the object body
+func (s *S3Store) Open(ref string) (io.ReadCloser, error) {
+	digest, err := refDigest(ref)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.do(http.MethodGet, digest, nil, 0, emptyPayloadHash)
+	if err != nil {
+		return nil, fmt.Errorf("S3 GET failed: %w", err)
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		resp.Body.Close()
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, ref)
+	case resp.StatusCode/100 != 2:
+		defer resp.Body.Close()
+		return nil, s3Error("GET", resp)
+	}
+	return resp.Body, nil
+}
+
+// Delete removes the object; S3 treats deleting a missing key as success
+func (s *S3Store) Delete(ref string) error {
+	digest, err := refDigest(ref)
+	if err != nil {
+		return err
+	}
+	resp, err := s.do(http.MethodDelete, digest, nil, 0, emptyPayloadHash)
+	if err != nil {
+		return fmt.Errorf("S3 DELETE failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return s3Error("DELETE", resp)
+	}
+	return nil
+}
+
+// signV4 adds AWS Signature Version 4 headers to req, signing the host and
+// every header already set on the request
+func signV4(req *http.Request, payloadHash, accessKey, secretKey, region string, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		canonicalURI(req.URL.Path),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := day + "/" + region + "/s3/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+secretKey), day)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		accessKey, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// canonicalURI percent-encodes each path segment as SigV4 requires for S3
+func canonicalURI(path string) string {
+	if path == "" {
+		return "/"
+	}
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		segments[i] = uriEncode(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var parts []string
+	for _, key := range keys {
+		values := append([]string(nil), query[key]...)
+		sort.Strings(values)
+		for _, value := range values {
+			parts = append(parts, uriEncode(key)+"="+uriEncode(value))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// uriEncode escapes everything except the RFC 3986 unreserved characters
+func uriEncode(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
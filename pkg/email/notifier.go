@@ -0,0 +1,179 @@
+package email
+
+import (
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Message is a rendered email
+type Message struct {
+	To       string
+	Subject  string
+	Body     string
+	Template string
+}
+
+// Notifier delivers rendered messages
+type Notifier interface {
+	Send(msg Message) error
+}
+
+// SMTPNotifier sends through an SMTP server
+type SMTPNotifier struct {
+	Host string
+	Port int
+	User string
+	Pass string
+	From string
+
+	// TLSConfig is used for STARTTLS; nil verifies Host against the system roots
+	TLSConfig *tls.Config
+}
+
+// Send delivers a message over SMTP with STARTTLS
+func (n *SMTPNotifier) Send(msg Message) error {
+	if err := sendSMTP(n.Host, n.Port, n.User, n.Pass, n.From, msg.To, msg.Subject, msg.Body, n.TLSConfig); err != nil {
+		return fmt.Errorf("failed to send email to %s: %w", msg.To, err)
+	}
+	return nil
+}
+
+// FileNotifier writes each message as a file in a maildir (tmp/, new/, cur/)
+type FileNotifier struct {
+	Dir string
+}
+
+// NewFileNotifier creates the maildir layout under dir
+func NewFileNotifier(dir string) (*FileNotifier, error) {
+	for _, sub := range []string{"tmp", "new", "cur"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0700); err != nil {
+			return nil, fmt.Errorf("failed to create maildir: %w", err)
+		}
+	}
+	return &FileNotifier{Dir: dir}, nil
+}
+
+// Send writes the message to tmp/ and moves it into new/ so readers never see partial files
+func (n *FileNotifier) Send(msg Message) error {
+	suffix := make([]byte, 8)
+	if _, err := rand.Read(suffix); err != nil {
+		return fmt.Errorf("failed to name message: %w", err)
+	}
+	name := fmt.Sprintf("%d.%s.portal", time.Now().UnixNano(), hex.EncodeToString(suffix))
+
+	tmpPath := filepath.Join(n.Dir, "tmp", name)
+	if err := os.WriteFile(tmpPath, []byte(formatMessage("", msg)), 0600); err != nil {
+		return fmt.Errorf("failed to write message: %w", err)
+	}
+	if err := os.Rename(tmpPath, filepath.Join(n.Dir, "new", name)); err != nil {
+		return fmt.Errorf("failed to deliver message: %w", err)
+	}
+	return nil
+}
+
+// ConsoleNotifier prints messages instead of sending them
+type ConsoleNotifier struct{}
+
+// Send prints the message to stdout
+func (ConsoleNotifier) Send(msg Message) error {
+	fmt.Println("\n" + strings.Repeat("=", 50))
+	fmt.Println("EMAIL NOTIFICATION (SIMULATED)")
+	fmt.Println(strings.Repeat("=", 50))
+	fmt.Printf("To: %s\n", msg.To)
+	fmt.Printf("Subject: %s\n", msg.Subject)
+	fmt.Println("\nMessage:")
+	fmt.Println(msg.Body)
+	fmt.Println(strings.Repeat("=", 50) + "\n")
+
+	return nil
+}
+
+var (
+	defaultNotifier Notifier
+	defaultMu       sync.Mutex
+)
+
+// NewNotifierFromEnv picks a backend from NOTIFY_BACKEND (smtp, file, console).
+// Without it, SMTP is used when credentials are configured and the console otherwise.
+func NewNotifierFromEnv() (Notifier, error) {
+	backend := strings.ToLower(os.Getenv("NOTIFY_BACKEND"))
+	settings, smtpConfigured := loadSMTPSettings()
+
+	switch backend {
+	case "":
+		if smtpConfigured {
+			return settings.notifier(), nil
+		}
+		return ConsoleNotifier{}, nil
+	case "smtp":
+		if !smtpConfigured {
+			return nil, fmt.Errorf("NOTIFY_BACKEND=smtp but SMTP_USER/SMTP_PASS are not set")
+		}
+		return settings.notifier(), nil
+	case "file", "maildir":
+		dir := os.Getenv("NOTIFY_MAILDIR")
+		if dir == "" {
+			dir = "mail"
+		}
+		return NewFileNotifier(dir)
+	case "console":
+		return ConsoleNotifier{}, nil
+	default:
+		return nil, fmt.Errorf("unknown NOTIFY_BACKEND %q", backend)
+	}
+}
+
+// Default returns the process-wide notifier, configuring it from the environment on first use
+func Default() Notifier {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+
+	if defaultNotifier == nil {
+		notifier, err := NewNotifierFromEnv()
+		if err != nil {
+			fmt.Printf("Warning: %v; printing emails to the console\n", err)
+			notifier = ConsoleNotifier{}
+		}
+		defaultNotifier = notifier
+	}
+	return defaultNotifier
+}
+
+// SetDefault replaces the process-wide notifier
+func SetDefault(n Notifier) {
+	defaultMu.Lock()
+	defaultNotifier = n
+	defaultMu.Unlock()
+}
+
+// Notify renders a template and sends it with the default notifier
+func Notify(to, templateName string, data interface{}) error {
+	msg, err := Render(to, templateName, data)
+	if err != nil {
+		return err
+	}
+	return Default().Send(msg)
+}
+
+// formatMessage builds an RFC 5322 message
+func formatMessage(from string, msg Message) string {
+	message := strings.Builder{}
+	if from != "" {
+		message.WriteString(fmt.Sprintf("From: %s\r\n", from))
+	}
+	message.WriteString(fmt.Sprintf("To: %s\r\n", msg.To))
+	message.WriteString(fmt.Sprintf("Subject: %s\r\n", msg.Subject))
+	message.WriteString(fmt.Sprintf("Date: %s\r\n", time.Now().Format(time.RFC1123Z)))
+	message.WriteString("MIME-Version: 1.0\r\n")
+	message.WriteString("Content-Type: text/plain; charset=\"utf-8\"\r\n")
+	message.WriteString("\r\n")
+	message.WriteString(msg.Body)
+	return message.String()
+}
@@ -0,0 +1,233 @@
+package email
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+)
+
+// Outbox message statuses
+const (
+	OutboxPending = "pending"
+	OutboxSent    = "sent"
+	OutboxDead    = "dead" // gave up after MaxSendAttempts
+)
+
+const (
+	MaxSendAttempts    = 8
+	RetryBaseDelay     = 30 * time.Second
+	MaxRetryDelay      = time.Hour
+	SendLease          = 2 * time.Minute // how long a worker owns a message while sending it
+	OutboxPollInterval = 15 * time.Second
+	OutboxRetention    = 7 * 24 * time.Hour // how long sent and dead rows are kept for the queue view
+	outboxBatchSize    = 20
+)
+
+// Outbox is a Notifier that queues messages in email_outbox for the Worker,
+// so callers never wait on the mail server
+type Outbox struct {
+	DB   *sql.DB
+	wake chan struct{}
+}
+
+// NewOutbox creates an outbox notifier
+func NewOutbox(db *sql.DB) *Outbox {
+	return &Outbox{
+		DB:   db,
+		wake: make(chan struct{}, 1),
+	}
+}
+
+// Send queues a message for delivery
+func (o *Outbox) Send(msg Message) error {
+	now := time.Now()
+	query := `
+        INSERT INTO email_outbox (recipient, subject, body, template, status, attempts, next_attempt_at, created_at)
+        VALUES (?, ?, ?, ?, ?, 0, ?, ?)
+    `
+	_, err := o.DB.Exec(query, msg.To, msg.Subject, msg.Body, msg.Template, OutboxPending, now, now)
+	if err != nil {
+		return fmt.Errorf("failed to queue email: %w", err)
+	}
+
+	// Nudge the worker so OTPs go out immediately rather than at the next poll
+	select {
+	case o.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Worker delivers queued messages through a transport, retrying with exponential back-off
+type Worker struct {
+	DB           *sql.DB
+	Transport    Notifier
+	PollInterval time.Duration
+	wake         <-chan struct{}
+}
+
+// NewWorker creates a worker that delivers an outbox's messages through transport
+func NewWorker(outbox *Outbox, transport Notifier) *Worker {
+	return &Worker{
+		DB:           outbox.DB,
+		Transport:    transport,
+		PollInterval: OutboxPollInterval,
+		wake:         outbox.wake,
+	}
+}
+
+// Run processes the queue until the context is cancelled
+func (w *Worker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := w.ProcessDue(); err != nil {
+			log.Printf("Email worker: %v", err)
+		}
+		if _, err := w.Purge(time.Now().Add(-OutboxRetention)); err != nil {
+			log.Printf("Email worker: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-w.wake:
+		}
+	}
+}
+
+// ProcessDue sends every message whose next attempt is due and returns how many were sent
+func (w *Worker) ProcessDue() (int, error) {
+	now := time.Now()
+	query := `
+        SELECT id, recipient, subject, body, template, attempts
+        FROM email_outbox
+        WHERE status = ? AND next_attempt_at <= ?
+        ORDER BY next_attempt_at ASC, id ASC
+        LIMIT ?
+    `
+	rows, err := w.DB.Query(query, OutboxPending, now, outboxBatchSize)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read outbox: %w", err)
+	}
+
+	type queued struct {
+		id       int
+		attempts int
+		msg      Message
+	}
+	var due []queued
+	for rows.Next() {
+		var q queued
+		var template sql.NullString
+		if err := rows.Scan(&q.id, &q.msg.To, &q.msg.Subject, &q.msg.Body, &template, &q.attempts); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan outbox message: %w", err)
+		}
+		q.msg.Template = template.String
+		due = append(due, q)
+	}
+	rows.Close()
+
+	sent := 0
+	for _, q := range due {
+		claimed, err := w.claim(q.id, now)
+		if err != nil {
+			return sent, err
+		}
+		if !claimed {
+			continue // another worker took it
+		}
+
+		if err := w.Transport.Send(q.msg); err != nil {
+			if err := w.recordFailure(q.id, q.attempts+1, err); err != nil {
+				return sent, err
+			}
+			continue
+		}
+
+		// The body may hold an OTP, so it is cleared as soon as it is no longer needed
+		_, err = w.DB.Exec(`UPDATE email_outbox SET status = ?, attempts = ?, sent_at = ?, last_error = NULL, body = '' WHERE id = ?`,
+			OutboxSent, q.attempts+1, time.Now(), q.id)
+		if err != nil {
+			return sent, fmt.Errorf("failed to mark email as sent: %w", err)
+		}
+		sent++
+	}
+
+	return sent, nil
+}
+
+// claim leases a message by pushing its next attempt forward; only one worker's update succeeds
+func (w *Worker) claim(id int, now time.Time) (bool, error) {
+	query := `UPDATE email_outbox SET next_attempt_at = ? WHERE id = ? AND status = ? AND next_attempt_at <= ?`
+	result, err := w.DB.Exec(query, now.Add(SendLease), id, OutboxPending, now)
+	if err != nil {
+		return false, fmt.Errorf("failed to claim email: %w", err)
+	}
+	affected, _ := result.RowsAffected()
+	return affected == 1, nil
+}
+
+// recordFailure schedules a retry, or dead-letters the message after MaxSendAttempts
+// and clears its body, which is never sent again
+func (w *Worker) recordFailure(id, attempts int, sendErr error) error {
+	status := OutboxPending
+	if attempts >= MaxSendAttempts {
+		status = OutboxDead
+	}
+
+	query := `
+        UPDATE email_outbox
+        SET status = ?, attempts = ?, next_attempt_at = ?, last_error = ?,
+            body = CASE WHEN ? THEN '' ELSE body END
+        WHERE id = ?
+    `
+	_, err := w.DB.Exec(query, status, attempts, time.Now().Add(RetryDelay(attempts)), sendErr.Error(), status == OutboxDead, id)
+	if err != nil {
+		return fmt.Errorf("failed to record email failure: %w", err)
+	}
+	return nil
+}
+
+// Purge deletes sent and dead-lettered messages queued before the cutoff and
+// returns how many were removed
+func (w *Worker) Purge(cutoff time.Time) (int64, error) {
+	query := `DELETE FROM email_outbox WHERE status IN (?, ?) AND created_at < ?`
+	result, err := w.DB.Exec(query, OutboxSent, OutboxDead, cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge outbox: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// RetryDelay is the wait before the next attempt after the given number of failures
+func RetryDelay(attempts int) time.Duration {
+	delay := RetryBaseDelay
+	for i := 1; i < attempts && delay < MaxRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > MaxRetryDelay {
+		delay = MaxRetryDelay
+	}
+	return delay
+}
+
+// StartOutbox routes all notifications through a queue in db and starts a worker that
+// delivers them with the transport configured by the environment
+func StartOutbox(ctx context.Context, db *sql.DB) error {
+	transport, err := NewNotifierFromEnv()
+	if err != nil {
+		return err
+	}
+
+	outbox := NewOutbox(db)
+	SetDefault(outbox)
+	go NewWorker(outbox, transport).Run(ctx)
+
+	return nil
+}
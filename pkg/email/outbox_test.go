@@ -0,0 +1,398 @@
+package email_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"database/sql"
+	"math/big"
+	"net"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/internal/database/dbtest"
+	"github.com/FLASH2332/Secure-Question-Paper-Distribution-Portal/pkg/email"
+)
+
+// smtpStandIn is a minimal SMTP server with STARTTLS that records what it
+// receives and can be told to refuse recipients
+type smtpStandIn struct {
+	ln        net.Listener
+	tlsConfig *tls.Config
+
+	mu        sync.Mutex
+	reject    bool
+	attempts  int
+	delivered []string
+}
+
+// newSMTPStandIn listens on a loopback port and returns the server with a
+// notifier that trusts its certificate
+func newSMTPStandIn(t *testing.T) (*smtpStandIn, *email.SMTPNotifier) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "smtp stand-in"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(cert)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	s := &smtpStandIn{
+		ln:        ln,
+		tlsConfig: &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}},
+	}
+	go s.serve()
+
+	notifier := &email.SMTPNotifier{
+		Host:      "127.0.0.1",
+		Port:      ln.Addr().(*net.TCPAddr).Port,
+		User:      "portal",
+		Pass:      "secret",
+		From:      "portal@example.com",
+		TLSConfig: &tls.Config{RootCAs: roots, ServerName: "127.0.0.1"},
+	}
+	return s, notifier
+}
+
+func (s *smtpStandIn) setReject(reject bool) {
+	s.mu.Lock()
+	s.reject = reject
+	s.mu.Unlock()
+}
+
+// counts returns how many deliveries were attempted and how many succeeded
+func (s *smtpStandIn) counts() (attempts, delivered int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.attempts, len(s.delivered)
+}
+
+func (s *smtpStandIn) lastMessage() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.delivered) == 0 {
+		return ""
+	}
+	return s.delivered[len(s.delivered)-1]
+}
+
+func (s *smtpStandIn) serve() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *smtpStandIn) handle(conn net.Conn) {
+	defer func() { conn.Close() }()
+	tp := textproto.NewConn(conn)
+	secure := false
+
+	tp.PrintfLine("220 127.0.0.1 ESMTP stand-in")
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		verb := strings.ToUpper(strings.Fields(line + " ")[0])
+
+		switch verb {
+		case "EHLO", "HELO":
+			if secure {
+				tp.PrintfLine("250-127.0.0.1")
+			} else {
+				tp.PrintfLine("250-127.0.0.1")
+				tp.PrintfLine("250-STARTTLS")
+			}
+			tp.PrintfLine("250 AUTH PLAIN")
+		case "STARTTLS":
+			tp.PrintfLine("220 Ready to start TLS")
+			tlsConn := tls.Server(conn, s.tlsConfig)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			conn = tlsConn
+			tp = textproto.NewConn(conn)
+			secure = true
+		case "AUTH":
+			tp.PrintfLine("235 Authentication successful")
+		case "MAIL", "RSET", "NOOP":
+			tp.PrintfLine("250 OK")
+		case "RCPT":
+			s.mu.Lock()
+			s.attempts++
+			reject := s.reject
+			s.mu.Unlock()
+			if reject {
+				tp.PrintfLine("451 Mailbox unavailable, try again later")
+			} else {
+				tp.PrintfLine("250 OK")
+			}
+		case "DATA":
+			tp.PrintfLine("354 End data with <CR><LF>.<CR><LF>")
+			data, err := tp.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.delivered = append(s.delivered, string(data))
+			s.mu.Unlock()
+			tp.PrintfLine("250 Queued")
+		case "QUIT":
+			tp.PrintfLine("221 Bye")
+			return
+		default:
+			tp.PrintfLine("502 Command not implemented")
+		}
+	}
+}
+
+// outboxRow is the delivery state of a queued message
+type outboxRow struct {
+	status        string
+	attempts      int
+	nextAttemptAt time.Time
+	lastError     sql.NullString
+	body          string
+}
+
+func readOutbox(t *testing.T, db *sql.DB, id int) outboxRow {
+	t.Helper()
+	var row outboxRow
+	query := `SELECT status, attempts, next_attempt_at, last_error, body FROM email_outbox WHERE id = ?`
+	if err := db.QueryRow(query, id).Scan(&row.status, &row.attempts, &row.nextAttemptAt, &row.lastError, &row.body); err != nil {
+		t.Fatal(err)
+	}
+	return row
+}
+
+// queue puts one message in the outbox and returns its ID
+func queue(t *testing.T, db *sql.DB) (*email.Outbox, int) {
+	t.Helper()
+	outbox := email.NewOutbox(db)
+	err := outbox.Send(email.Message{
+		To:       "examcell@example.com",
+		Subject:  "New question paper submitted: Midterm",
+		Body:     "faculty submitted question paper #1 \"Midterm\" (Maths) for review.",
+		Template: email.TemplatePaperSubmitted,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var id int
+	if err := db.QueryRow(`SELECT MAX(id) FROM email_outbox`).Scan(&id); err != nil {
+		t.Fatal(err)
+	}
+	return outbox, id
+}
+
+// makeDue moves a message's next attempt into the past
+func makeDue(t *testing.T, db *sql.DB, id int) {
+	t.Helper()
+	dbtest.Exec(t, db, `UPDATE email_outbox SET next_attempt_at = ? WHERE id = ?`, time.Now().Add(-time.Second), id)
+}
+
+func TestWorkerDeliversQueuedMail(t *testing.T) {
+	db := dbtest.Open(t)
+	server, transport := newSMTPStandIn(t)
+	outbox, id := queue(t, db)
+
+	sent, err := email.NewWorker(outbox, transport).ProcessDue()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sent != 1 {
+		t.Fatalf("sent %d message(s), want 1", sent)
+	}
+
+	message := server.lastMessage()
+	for _, want := range []string{
+		"To: examcell@example.com",
+		"Subject: New question paper submitted: Midterm",
+		"From: portal@example.com",
+	} {
+		if !strings.Contains(message, want) {
+			t.Errorf("delivered message lacks %q:\n%s", want, message)
+		}
+	}
+
+	row := readOutbox(t, db, id)
+	if row.status != email.OutboxSent || row.attempts != 1 || row.lastError.Valid {
+		t.Fatalf("outbox row = %+v, want sent after one attempt", row)
+	}
+}
+
+func TestWorkerRetriesWithBackoff(t *testing.T) {
+	db := dbtest.Open(t)
+	server, transport := newSMTPStandIn(t)
+	outbox, id := queue(t, db)
+	worker := email.NewWorker(outbox, transport)
+
+	server.setReject(true)
+	before := time.Now()
+	if sent, err := worker.ProcessDue(); err != nil || sent != 0 {
+		t.Fatalf("ProcessDue with a refusing server = %d, %v", sent, err)
+	}
+
+	row := readOutbox(t, db, id)
+	if row.status != email.OutboxPending || row.attempts != 1 {
+		t.Fatalf("outbox row = %+v, want pending after one attempt", row)
+	}
+	if !strings.Contains(row.lastError.String, "451") {
+		t.Fatalf("last_error = %q, want the server's refusal", row.lastError.String)
+	}
+	earliest := before.Add(email.RetryDelay(1))
+	if row.nextAttemptAt.Before(earliest.Add(-time.Second)) || row.nextAttemptAt.After(time.Now().Add(email.RetryDelay(1))) {
+		t.Fatalf("next attempt at %s, want about %s from now", row.nextAttemptAt, email.RetryDelay(1))
+	}
+
+	// Nothing is sent again until the back-off has passed
+	server.setReject(false)
+	if sent, err := worker.ProcessDue(); err != nil || sent != 0 {
+		t.Fatalf("ProcessDue during back-off = %d, %v", sent, err)
+	}
+	if attempts, _ := server.counts(); attempts != 1 {
+		t.Fatalf("server saw %d attempts during back-off, want 1", attempts)
+	}
+
+	makeDue(t, db, id)
+	if sent, err := worker.ProcessDue(); err != nil || sent != 1 {
+		t.Fatalf("ProcessDue after back-off = %d, %v", sent, err)
+	}
+	row = readOutbox(t, db, id)
+	if row.status != email.OutboxSent || row.attempts != 2 || row.lastError.Valid || row.body != "" {
+		t.Fatalf("outbox row = %+v, want sent on the second attempt with its body cleared", row)
+	}
+}
+
+func TestWorkerDeadLettersAndClearsBody(t *testing.T) {
+	db := dbtest.Open(t)
+	server, transport := newSMTPStandIn(t)
+	outbox, id := queue(t, db)
+	worker := email.NewWorker(outbox, transport)
+
+	server.setReject(true)
+	for attempt := 1; attempt <= email.MaxSendAttempts; attempt++ {
+		makeDue(t, db, id)
+		if _, err := worker.ProcessDue(); err != nil {
+			t.Fatal(err)
+		}
+		row := readOutbox(t, db, id)
+		if row.attempts != attempt {
+			t.Fatalf("attempts = %d after attempt %d", row.attempts, attempt)
+		}
+		want := email.OutboxPending
+		if attempt == email.MaxSendAttempts {
+			want = email.OutboxDead
+		}
+		if row.status != want {
+			t.Fatalf("status after attempt %d = %q, want %q", attempt, row.status, want)
+		}
+		if cleared := row.body == ""; cleared != (want == email.OutboxDead) {
+			t.Fatalf("body after attempt %d = %q", attempt, row.body)
+		}
+	}
+
+	// A dead message is never picked up again by itself
+	server.setReject(false)
+	makeDue(t, db, id)
+	if sent, err := worker.ProcessDue(); err != nil || sent != 0 {
+		t.Fatalf("ProcessDue on a dead message = %d, %v", sent, err)
+	}
+	if attempts, delivered := server.counts(); attempts != email.MaxSendAttempts || delivered != 0 {
+		t.Fatalf("server saw %d attempts and %d deliveries, want %d and 0", attempts, delivered, email.MaxSendAttempts)
+	}
+}
+
+func TestWorkerPurgesOldMessages(t *testing.T) {
+	db := dbtest.Open(t)
+	_, transport := newSMTPStandIn(t)
+	outbox, sentID := queue(t, db)
+	_, deadID := queue(t, db)
+	_, pendingID := queue(t, db)
+	worker := email.NewWorker(outbox, transport)
+
+	old := time.Now().Add(-2 * email.OutboxRetention)
+	dbtest.Exec(t, db, `UPDATE email_outbox SET created_at = ?`, old)
+	dbtest.Exec(t, db, `UPDATE email_outbox SET status = ? WHERE id = ?`, email.OutboxSent, sentID)
+	dbtest.Exec(t, db, `UPDATE email_outbox SET status = ? WHERE id = ?`, email.OutboxDead, deadID)
+	_, recentID := queue(t, db)
+	dbtest.Exec(t, db, `UPDATE email_outbox SET status = ? WHERE id = ?`, email.OutboxSent, recentID)
+
+	purged, err := worker.Purge(time.Now().Add(-email.OutboxRetention))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if purged != 2 {
+		t.Fatalf("purged %d message(s), want the old sent and dead ones", purged)
+	}
+
+	var remaining []int
+	rows, err := db.Query(`SELECT id FROM email_outbox ORDER BY id`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			t.Fatal(err)
+		}
+		remaining = append(remaining, id)
+	}
+	if len(remaining) != 2 || remaining[0] != pendingID || remaining[1] != recentID {
+		t.Fatalf("remaining messages = %v, want [%d %d]", remaining, pendingID, recentID)
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, email.RetryBaseDelay},
+		{2, 2 * email.RetryBaseDelay},
+		{3, 4 * email.RetryBaseDelay},
+		{7, 64 * email.RetryBaseDelay},
+		{8, email.MaxRetryDelay},
+		{50, email.MaxRetryDelay},
+	}
+	for _, tt := range tests {
+		if got := email.RetryDelay(tt.attempts); got != tt.want {
+			t.Errorf("RetryDelay(%d) = %s, want %s", tt.attempts, got, tt.want)
+		}
+	}
+}
@@ -9,8 +9,17 @@ import (
 	"strings"
 )
 
-// SendOTP sends OTP via Gmail SMTP, falling back to simulation if not configured.
-func SendOTP(recipientEmail, otp string) error {
+// smtpSettings holds the SMTP configuration read from the environment
+type smtpSettings struct {
+	Host string
+	Port int
+	User string
+	Pass string
+	From string
+}
+
+// loadSMTPSettings reads SMTP settings; ok is false when no credentials are configured
+func loadSMTPSettings() (smtpSettings, bool) {
 	smtpHost := os.Getenv("SMTP_HOST")
 	smtpPortStr := os.Getenv("SMTP_PORT")
 	smtpUser := os.Getenv("SMTP_USER")
@@ -35,7 +44,7 @@ func SendOTP(recipientEmail, otp string) error {
 	}
 
 	if smtpUser == "" || smtpPass == "" {
-		return simulateEmail(recipientEmail, otp)
+		return smtpSettings{}, false
 	}
 	if smtpFrom == "" {
 		smtpFrom = smtpUser
@@ -46,44 +55,18 @@ func SendOTP(recipientEmail, otp string) error {
 		smtpPort = 587
 	}
 
-	body := fmt.Sprintf("Your One-Time Password (OTP) is: %s\n\nThis OTP is valid for 5 minutes.\nDo not share this OTP with anyone.\n\nThis is an automated message from Secure Exam Paper Distribution System.", otp)
-
-	if err := sendSMTP(smtpHost, smtpPort, smtpUser, smtpPass, smtpFrom, recipientEmail, "Your OTP for Secure Exam System", body); err != nil {
-		fmt.Printf("Warning: Failed to send email: %v\n", err)
-		fmt.Println("Falling back to console display...")
-		return simulateEmail(recipientEmail, otp)
-	}
-
-	fmt.Printf("Email sent successfully to: %s\n", recipientEmail)
-	return nil
+	return smtpSettings{Host: smtpHost, Port: smtpPort, User: smtpUser, Pass: smtpPass, From: smtpFrom}, true
 }
 
-func simulateEmail(email, otp string) error {
-	fmt.Println("\n" + strings.Repeat("=", 50))
-	fmt.Println("EMAIL NOTIFICATION (SIMULATED)")
-	fmt.Println(strings.Repeat("=", 50))
-	fmt.Printf("To: %s\n", email)
-	fmt.Println("Subject: Your OTP for Secure Exam System")
-	fmt.Println("\nMessage:")
-	fmt.Printf("Your One-Time Password (OTP) is: %s\n", otp)
-	fmt.Println("This OTP is valid for 5 minutes.")
-	fmt.Println("Do not share this OTP with anyone.")
-	fmt.Println(strings.Repeat("=", 50) + "\n")
-
-	return nil
+// notifier builds an SMTP notifier from the settings
+func (s smtpSettings) notifier() *SMTPNotifier {
+	return &SMTPNotifier{Host: s.Host, Port: s.Port, User: s.User, Pass: s.Pass, From: s.From}
 }
 
-func sendSMTP(host string, port int, user, pass, from, to, subject, body string) error {
+func sendSMTP(host string, port int, user, pass, from, to, subject, body string, tlsConfig *tls.Config) error {
 	addr := fmt.Sprintf("%s:%d", host, port)
 
-	message := strings.Builder{}
-	message.WriteString(fmt.Sprintf("From: %s\r\n", from))
-	message.WriteString(fmt.Sprintf("To: %s\r\n", to))
-	message.WriteString(fmt.Sprintf("Subject: %s\r\n", subject))
-	message.WriteString("MIME-Version: 1.0\r\n")
-	message.WriteString("Content-Type: text/plain; charset=\"utf-8\"\r\n")
-	message.WriteString("\r\n")
-	message.WriteString(body)
+	message := formatMessage(from, Message{To: to, Subject: subject, Body: body})
 
 	c, err := smtp.Dial(addr)
 	if err != nil {
@@ -92,7 +75,9 @@ func sendSMTP(host string, port int, user, pass, from, to, subject, body string)
 	defer c.Close()
 
 	if ok, _ := c.Extension("STARTTLS"); ok {
-		tlsConfig := &tls.Config{ServerName: host}
+		if tlsConfig == nil {
+			tlsConfig = &tls.Config{ServerName: host}
+		}
 		if err := c.StartTLS(tlsConfig); err != nil {
 			return err
 		}
@@ -114,7 +99,7 @@ func sendSMTP(host string, port int, user, pass, from, to, subject, body string)
 	if err != nil {
 		return err
 	}
-	_, err = wc.Write([]byte(message.String()))
+	_, err = wc.Write([]byte(message))
 	if err != nil {
 		_ = wc.Close()
 		return err
@@ -0,0 +1,132 @@
+package email
+
+import (
+	"bytes"
+	"fmt"
+	"text/template"
+	"time"
+)
+
+// Template names
+const (
+	TemplateOTP              = "otp"
+	TemplatePaperSubmitted   = "paper_submitted"
+	TemplatePaperReviewed    = "paper_reviewed"
+	TemplateSessionScheduled = "session_scheduled"
+	TemplateDecryption       = "decryption_performed"
+	TemplateAccountLocked    = "account_locked"
+	TemplateRecoveryCodesLow = "recovery_codes_low"
+)
+
+const footer = "\n\nThis is an automated message from Secure Exam Paper Distribution System."
+
+// OTPData fills TemplateOTP
+type OTPData struct {
+	Code         string
+	ValidityMins int
+}
+
+// PaperData fills TemplatePaperSubmitted, TemplatePaperReviewed and TemplateDecryption
+type PaperData struct {
+	PaperID  int
+	Title    string
+	Subject  string
+	ExamDate time.Time
+	Actor    string // who submitted, reviewed or decrypted the paper
+	Status   string
+	Comments string
+	At       time.Time
+}
+
+// SessionData fills TemplateSessionScheduled
+type SessionData struct {
+	SessionID       int
+	SessionName     string
+	PaperTitle      string
+	ScheduledTime   time.Time
+	DurationMinutes int
+	ScheduledBy     string
+}
+
+// AccountData fills TemplateAccountLocked and TemplateRecoveryCodesLow
+type AccountData struct {
+	Username    string
+	Source      string
+	LockedUntil time.Time
+	Remaining   int
+}
+
+type messageTemplate struct {
+	subject *template.Template
+	body    *template.Template
+}
+
+var templateFuncs = template.FuncMap{
+	"date":     func(t time.Time) string { return t.Local().Format("2006-01-02") },
+	"datetime": func(t time.Time) string { return t.Local().Format("2006-01-02 15:04") },
+}
+
+var templates = map[string]messageTemplate{
+	TemplateOTP: newTemplate(
+		"Your OTP for Secure Exam System",
+		"Your One-Time Password (OTP) is: {{.Code}}\n\n"+
+			"This OTP is valid for {{.ValidityMins}} minutes.\n"+
+			"Do not share this OTP with anyone."),
+	TemplatePaperSubmitted: newTemplate(
+		"New question paper submitted: {{.Title}}",
+		"{{.Actor}} submitted question paper #{{.PaperID}} \"{{.Title}}\" ({{.Subject}}) for review.\n"+
+			"Exam date: {{date .ExamDate}}"),
+	TemplatePaperReviewed: newTemplate(
+		"Question paper {{.Status}}: {{.Title}}",
+		"Your question paper #{{.PaperID}} \"{{.Title}}\" was {{.Status}} by {{.Actor}}."+
+			"{{if .Comments}}\n\nReviewer comments:\n{{.Comments}}{{end}}"),
+	TemplateSessionScheduled: newTemplate(
+		"Exam session scheduled: {{.SessionName}}",
+		"{{.ScheduledBy}} scheduled exam session \"{{.SessionName}}\" for paper \"{{.PaperTitle}}\".\n"+
+			"Starts: {{datetime .ScheduledTime}}\n"+
+			"Duration: {{.DurationMinutes}} minutes"),
+	TemplateDecryption: newTemplate(
+		"Question paper decrypted: {{.Title}}",
+		"Question paper #{{.PaperID}} \"{{.Title}}\" was decrypted by {{.Actor}} at {{datetime .At}}.\n"+
+			"If this was not expected, contact the Exam Cell immediately."),
+	TemplateAccountLocked: newTemplate(
+		"Your account has been locked",
+		"Your account ({{.Username}}) was locked until {{datetime .LockedUntil}} after too many failed "+
+			"login attempts from {{.Source}}.\n"+
+			"If this was not you, contact the Exam Cell."),
+	TemplateRecoveryCodesLow: newTemplate(
+		"Recovery codes running low",
+		"A recovery code was just used to sign in to your account ({{.Username}}).\n"+
+			"You have {{.Remaining}} recovery codes left. Generate a new set from your dashboard.\n"+
+			"If this was not you, contact the Exam Cell immediately."),
+}
+
+func newTemplate(subject, body string) messageTemplate {
+	return messageTemplate{
+		subject: template.Must(template.New("subject").Funcs(templateFuncs).Parse(subject)),
+		body:    template.Must(template.New("body").Funcs(templateFuncs).Parse(body)),
+	}
+}
+
+// Render builds a message for one recipient from a named template
+func Render(to, name string, data interface{}) (Message, error) {
+	tmpl, ok := templates[name]
+	if !ok {
+		return Message{}, fmt.Errorf("unknown email template %q", name)
+	}
+
+	var subject, body bytes.Buffer
+	if err := tmpl.subject.Execute(&subject, data); err != nil {
+		return Message{}, fmt.Errorf("failed to render %s subject: %w", name, err)
+	}
+	if err := tmpl.body.Execute(&body, data); err != nil {
+		return Message{}, fmt.Errorf("failed to render %s body: %w", name, err)
+	}
+
+	return Message{
+		To:       to,
+		Subject:  subject.String(),
+		Body:     body.String() + footer,
+		Template: name,
+	}, nil
+}